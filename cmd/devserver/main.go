@@ -0,0 +1,241 @@
+// Command devserver serves the functions locally behind emulated API Gateway routes.
+//
+// Usage:
+//
+//	go run ./cmd/devserver [-addr 127.0.0.1:3000] [-template template.yaml] [-functions functions]
+//	    [-parameter-overrides "Key=Value ..."] [-poll 500ms] [-no-reload]
+//
+// It must be run from the module root. Every directory under functions/ with a main.go
+// is built and run against an emulated Lambda Runtime API, so requests go through the
+// same main, middleware stack and apigw adapter as in the deployed function. Routes,
+// timeouts, memory sizes and environment variables come from the Api and HttpApi events
+// and properties of the matching resources in template.yaml (GreeterFunction serves
+// functions/greeter); template parameters resolve to their defaults unless overridden.
+// Variables already set in the environment take precedence over the template, so
+// MONGODB_URI and REDIS_URI can point at local databases.
+//
+// Functions are rebuilt and restarted when their sources, shared/ or go.mod change, and
+// routes are reloaded when the template changes.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xarunoba/mlgmr/cmd/internal/emulator"
+)
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.addr, "addr", "127.0.0.1:3000", "address to listen on")
+	flag.StringVar(&cfg.template, "template", "template.yaml", "SAM template defining the routes and environment")
+	flag.StringVar(&cfg.functions, "functions", "functions", "directory containing the functions")
+	flag.StringVar(&cfg.overrides, "parameter-overrides", "", "template parameter values as space-separated Key=Value pairs")
+	flag.DurationVar(&cfg.poll, "poll", 500*time.Millisecond, "interval between checks for changed files")
+	noReload := flag.Bool("no-reload", false, "do not rebuild functions when their sources change")
+	flag.Parse()
+	cfg.reload = !*noReload
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "devserver:", err)
+		os.Exit(1)
+	}
+}
+
+// config holds the command line flags.
+type config struct {
+	addr      string
+	template  string
+	functions string
+	overrides string
+	poll      time.Duration
+	reload    bool
+}
+
+func run(cfg config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return errors.New("go.mod not found, run the dev server from the module root")
+	}
+
+	overrides, err := parseOverrides(cfg.overrides)
+	if err != nil {
+		return err
+	}
+
+	binDir, err := os.MkdirTemp("", "mlgmr-devserver-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(binDir)
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	dev := &devServer{
+		cfg:       cfg,
+		root:      root,
+		binDir:    binDir,
+		overrides: overrides,
+		logger:    logger,
+		server:    &server{logger: logger},
+		functions: map[string]*emulator.Function{},
+	}
+	defer dev.close()
+
+	if err := dev.load(ctx, nil); err != nil {
+		return err
+	}
+
+	for _, rt := range dev.server.routes {
+		logger.Info(fmt.Sprintf("Mounted %s %s", rt.Method, rt.Path), slog.String("function", rt.Function))
+	}
+
+	listener, err := net.Listen("tcp", cfg.addr)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{Handler: dev.server}
+
+	if cfg.reload {
+		w := &watcher{
+			root:     root,
+			dirs:     []string{cfg.functions, "shared"},
+			files:    []string{cfg.template, "go.mod", "go.sum"},
+			interval: cfg.poll,
+		}
+		go w.watch(ctx, func(changed []string) {
+			if err := dev.load(ctx, changed); err != nil {
+				logger.Error("Reload failed, still serving the previous build", slog.Any("error", err))
+			}
+		})
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.Serve(listener) }()
+
+	logger.Info("Listening on http://" + listener.Addr().String())
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// devServer keeps the functions and the routes in sync with the sources and the template.
+type devServer struct {
+	cfg       config
+	root      string
+	binDir    string
+	overrides map[string]string
+	logger    *slog.Logger
+	server    *server
+
+	// mu serializes reloads.
+	mu        sync.Mutex
+	functions map[string]*emulator.Function
+}
+
+// load reads the template, then builds and restarts the functions affected by the
+// changed paths, or all of them on the first load. A function whose build fails keeps
+// running its previous binary.
+func (d *devServer) load(ctx context.Context, changed []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	specs, err := loadFunctions(filepath.Join(d.root, d.cfg.functions), filepath.Join(d.root, d.cfg.template), d.overrides)
+	if err != nil {
+		return err
+	}
+
+	functions := map[string]*emulator.Function{}
+	invokers := map[string]invoker{}
+	var routes []route
+	var errs []error
+	for _, spec := range specs {
+		fn, existing := d.functions[spec.Name]
+		if !existing {
+			pkg := "./" + filepath.ToSlash(filepath.Join(d.cfg.functions, spec.Name))
+			logger := d.logger.With(slog.String("function", spec.Name))
+			if fn, err = emulator.NewFunction(d.root, pkg, filepath.Join(d.binDir, spec.Name), spec.config(), logger); err != nil {
+				return err
+			}
+		}
+		functions[spec.Name] = fn
+		invokers[spec.Name] = fn
+		routes = append(routes, spec.Routes...)
+
+		if existing && !d.affects(spec.Name, changed) {
+			continue
+		}
+
+		if changed != nil {
+			d.logger.Info("Rebuilding", slog.String("function", spec.Name))
+		}
+		if err := fn.Build(ctx); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := fn.Restart(spec.config()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Stop the functions that were removed
+	for name, fn := range d.functions {
+		if _, ok := functions[name]; !ok {
+			fn.Close()
+		}
+	}
+
+	d.functions = functions
+	d.server.setRoutes(routes, invokers)
+	return errors.Join(errs...)
+}
+
+// affects reports whether a change to the paths requires rebuilding the function.
+// Changes within another function's directory do not; any other change does, since
+// the template, shared/ and go.mod are used by every function.
+func (d *devServer) affects(name string, changed []string) bool {
+	functionsDir := filepath.Join(d.root, d.cfg.functions) + string(filepath.Separator)
+	return slices.ContainsFunc(changed, func(path string) bool {
+		rel, ok := strings.CutPrefix(path, functionsDir)
+		if !ok {
+			return true
+		}
+		dir, _, _ := strings.Cut(rel, string(filepath.Separator))
+		return dir == name
+	})
+}
+
+// close stops every function.
+func (d *devServer) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, fn := range d.functions {
+		fn.Close()
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// route maps an API Gateway method and path template to a function.
+type route struct {
+	// Method is an upper-case HTTP method, or "ANY".
+	Method string
+	// Path is the path template, e.g. "/items/{id}" or "/{proxy+}".
+	Path     string
+	Function string
+	// PayloadV2 selects the HTTP API (payload format 2.0) event instead of the REST API one.
+	PayloadV2 bool
+
+	segments []string
+}
+
+func newRoute(method, path, function string, payloadV2 bool) route {
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = "ANY"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return route{
+		Method:    method,
+		Path:      path,
+		Function:  function,
+		PayloadV2: payloadV2,
+		segments:  splitPath(path),
+	}
+}
+
+// match reports whether the route matches the request and returns its path parameters.
+// "{name}" matches one segment and a trailing "{name+}" matches the rest of the path.
+func (r route) match(method, path string) (map[string]string, bool) {
+	if r.Method != "ANY" && r.Method != method {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	segments := splitPath(path)
+	for i, pattern := range r.segments {
+		name, greedy, isParam := segmentParam(pattern)
+		if i >= len(segments) {
+			return nil, false
+		}
+
+		switch {
+		case greedy:
+			params[name] = strings.Join(segments[i:], "/")
+			return params, true
+		case isParam:
+			params[name] = segments[i]
+		case pattern != segments[i]:
+			return nil, false
+		}
+	}
+
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// segmentParam parses a "{name}" or "{name+}" path segment.
+func segmentParam(segment string) (name string, greedy, ok bool) {
+	inner, ok := strings.CutPrefix(segment, "{")
+	if !ok {
+		return "", false, false
+	}
+	inner, ok = strings.CutSuffix(inner, "}")
+	if !ok {
+		return "", false, false
+	}
+	name, greedy = strings.CutSuffix(inner, "+")
+	return name, greedy, true
+}
+
+// greedy reports whether the route ends with a "{name+}" segment.
+func (r route) greedy() bool {
+	if len(r.segments) == 0 {
+		return false
+	}
+	_, greedy, _ := segmentParam(r.segments[len(r.segments)-1])
+	return greedy
+}
+
+// literals counts the segments without parameters.
+func (r route) literals() int {
+	n := 0
+	for _, segment := range r.segments {
+		if _, _, isParam := segmentParam(segment); !isParam {
+			n++
+		}
+	}
+	return n
+}
+
+// sortRoutes orders routes like API Gateway picks them: exact paths before
+// parameterized ones, and greedy paths last.
+func sortRoutes(routes []route) {
+	slices.SortStableFunc(routes, func(a, b route) int {
+		if a.greedy() != b.greedy() {
+			if a.greedy() {
+				return 1
+			}
+			return -1
+		}
+		if c := cmp.Compare(b.literals(), a.literals()); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(b.segments), len(a.segments)); c != 0 {
+			return c
+		}
+		// A specific method wins over ANY
+		return cmp.Compare(boolInt(a.Method == "ANY"), boolInt(b.Method == "ANY"))
+	})
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// splitPath returns the non-empty segments of path.
+func splitPath(path string) []string {
+	var segments []string
+	for segment := range strings.SplitSeq(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
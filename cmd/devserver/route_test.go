@@ -0,0 +1,58 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestRoute_Match(t *testing.T) {
+	tests := []struct {
+		name         string
+		route        route
+		method       string
+		path         string
+		expectMatch  bool
+		expectParams map[string]string
+	}{
+		{name: "root", route: newRoute("POST", "/", "greeter", false), method: "POST", path: "/", expectMatch: true, expectParams: map[string]string{}},
+		{name: "other method", route: newRoute("POST", "/", "greeter", false), method: "GET", path: "/", expectMatch: false},
+		{name: "any method", route: newRoute("ANY", "/items", "items", false), method: "DELETE", path: "/items", expectMatch: true, expectParams: map[string]string{}},
+		{name: "lower-case method", route: newRoute("get", "/items", "items", false), method: "GET", path: "/items", expectMatch: true, expectParams: map[string]string{}},
+		{name: "trailing slash", route: newRoute("GET", "/items", "items", false), method: "GET", path: "/items/", expectMatch: true, expectParams: map[string]string{}},
+		{name: "path parameter", route: newRoute("GET", "/items/{id}", "items", false), method: "GET", path: "/items/42", expectMatch: true, expectParams: map[string]string{"id": "42"}},
+		{name: "missing parameter", route: newRoute("GET", "/items/{id}", "items", false), method: "GET", path: "/items", expectMatch: false},
+		{name: "extra segment", route: newRoute("GET", "/items/{id}", "items", false), method: "GET", path: "/items/42/tags", expectMatch: false},
+		{name: "greedy parameter", route: newRoute("ANY", "/files/{proxy+}", "files", false), method: "GET", path: "/files/a/b.txt", expectMatch: true, expectParams: map[string]string{"proxy": "a/b.txt"}},
+		{name: "empty greedy parameter", route: newRoute("ANY", "/files/{proxy+}", "files", false), method: "GET", path: "/files", expectMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, ok := tt.route.match(tt.method, tt.path)
+			if ok != tt.expectMatch {
+				t.Fatalf("Expected match %v, got %v", tt.expectMatch, ok)
+			}
+			if ok && !maps.Equal(params, tt.expectParams) {
+				t.Errorf("Expected parameters %v, got %v", tt.expectParams, params)
+			}
+		})
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	routes := []route{
+		newRoute("ANY", "/{proxy+}", "fallback", true),
+		newRoute("ANY", "/items/{id}", "any-item", false),
+		newRoute("GET", "/items/{id}", "get-item", false),
+		newRoute("GET", "/items/new", "new-item", false),
+		newRoute("GET", "/items", "items", false),
+	}
+	sortRoutes(routes)
+
+	expected := []string{"new-item", "get-item", "any-item", "items", "fallback"}
+	for i, function := range expected {
+		if routes[i].Function != function {
+			t.Errorf("Expected route %d to be '%s', got '%s'", i, function, routes[i].Function)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/xarunoba/mlgmr/cmd/internal/emulator"
+	"github.com/xarunoba/mlgmr/shared/invoke"
+)
+
+// stage is the API Gateway stage reported in the events.
+const stage = "local"
+
+// invoker invokes a function; it is implemented by *emulator.Function.
+type invoker interface {
+	Invoke(ctx context.Context, inv *emulator.Invocation) (emulator.Result, error)
+}
+
+// server routes HTTP requests to the functions like API Gateway: it converts each
+// request to a proxy event, invokes the function and converts its proxy response back.
+type server struct {
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	routes    []route
+	functions map[string]invoker
+}
+
+// setRoutes replaces the routes and the functions they point to.
+func (s *server) setRoutes(routes []route, functions map[string]invoker) {
+	routes = append([]route(nil), routes...)
+	sortRoutes(routes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = routes
+	s.functions = functions
+}
+
+// lookup returns the first route matching the request and its function.
+func (s *server) lookup(r *http.Request) (route, map[string]string, invoker, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rt := range s.routes {
+		if params, ok := rt.match(r.Method, r.URL.Path); ok {
+			return rt, params, s.functions[rt.Function], true
+		}
+	}
+	return route{}, nil, nil, false
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	rt, params, fn, ok := s.lookup(r)
+	if !ok {
+		writeMessage(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	inv := &emulator.Invocation{RequestID: invoke.NewRequestID(), TraceHeader: invoke.NewTraceHeader(start)}
+	if rt.PayloadV2 {
+		inv.Payload, err = json.Marshal(httpEvent(r, rt, params, body, inv.RequestID, start))
+	} else {
+		inv.Payload, err = json.Marshal(proxyEvent(r, rt, params, body, inv.RequestID, start))
+	}
+	if err != nil {
+		writeMessage(w, http.StatusInternalServerError, "Failed to encode event")
+		return
+	}
+
+	result, err := fn.Invoke(r.Context(), inv)
+	if err == nil && result.Error != nil {
+		err = result.Error
+	}
+	if err != nil {
+		// API Gateway hides function failures from the caller
+		s.logger.Error("Invocation failed", slog.String("function", rt.Function), slog.String("requestId", inv.RequestID), slog.Any("error", err))
+		writeMessage(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	var status int
+	if rt.PayloadV2 {
+		status, err = writeHTTPResponse(w, result.Payload)
+	} else {
+		status, err = writeProxyResponse(w, result.Payload)
+	}
+	if err != nil {
+		s.logger.Error("Malformed function response", slog.String("function", rt.Function), slog.String("requestId", inv.RequestID), slog.Any("error", err))
+		writeMessage(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	s.logger.Info(fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+		slog.String("function", rt.Function),
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
+// proxyEvent converts the request to a REST API (payload format 1.0) event.
+func proxyEvent(r *http.Request, rt route, params map[string]string, body []byte, requestID string, now time.Time) events.APIGatewayProxyRequest {
+	headers, multiHeaders := map[string]string{}, map[string][]string{}
+	for name, values := range requestHeaders(r) {
+		headers[name] = values[len(values)-1]
+		multiHeaders[name] = values
+	}
+
+	query, multiQuery := map[string]string{}, map[string][]string{}
+	for name, values := range r.URL.Query() {
+		query[name] = values[len(values)-1]
+		multiQuery[name] = values
+	}
+
+	encoded, isBase64 := encodeBody(body)
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        rt.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiHeaders,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: multiQuery,
+		PathParameters:                  params,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:        "000000000000",
+			ResourcePath:     rt.Path,
+			Stage:            stage,
+			RequestID:        requestID,
+			Identity:         events.APIGatewayRequestIdentity{SourceIP: sourceIP(r), UserAgent: r.UserAgent()},
+			HTTPMethod:       r.Method,
+			RequestTime:      now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+			RequestTimeEpoch: now.UnixMilli(),
+			Protocol:         r.Proto,
+		},
+		Body:            encoded,
+		IsBase64Encoded: isBase64,
+	}
+}
+
+// httpEvent converts the request to an HTTP API (payload format 2.0) event.
+func httpEvent(r *http.Request, rt route, params map[string]string, body []byte, requestID string, now time.Time) events.APIGatewayV2HTTPRequest {
+	// Payload format 2.0 joins repeated headers and query parameters with commas
+	// and moves cookies to their own field
+	headers := map[string]string{}
+	for name, values := range requestHeaders(r) {
+		if name != "cookie" {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+
+	query := map[string]string{}
+	for name, values := range r.URL.Query() {
+		query[name] = strings.Join(values, ",")
+	}
+
+	var cookies []string
+	for _, cookie := range r.Cookies() {
+		cookies = append(cookies, cookie.String())
+	}
+
+	routeKey := rt.Method + " " + rt.Path
+	if rt.Method == "ANY" && rt.Path == "/{proxy+}" {
+		routeKey = "$default"
+	}
+
+	encoded, isBase64 := encodeBody(body)
+
+	return events.APIGatewayV2HTTPRequest{
+		Version:               "2.0",
+		RouteKey:              routeKey,
+		RawPath:               r.URL.Path,
+		RawQueryString:        r.URL.RawQuery,
+		Cookies:               cookies,
+		Headers:               headers,
+		QueryStringParameters: query,
+		PathParameters:        params,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RouteKey:  routeKey,
+			AccountID: "000000000000",
+			Stage:     "$default",
+			RequestID: requestID,
+			Time:      now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+			TimeEpoch: now.UnixMilli(),
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Protocol:  r.Proto,
+				SourceIP:  sourceIP(r),
+				UserAgent: r.UserAgent(),
+			},
+		},
+		Body:            encoded,
+		IsBase64Encoded: isBase64,
+	}
+}
+
+// writeProxyResponse writes a REST API (payload format 1.0) response and returns its status.
+func writeProxyResponse(w http.ResponseWriter, payload []byte) (int, error) {
+	var resp events.APIGatewayProxyResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return 0, err
+	}
+
+	for name, value := range resp.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range resp.MultiValueHeaders {
+		w.Header()[http.CanonicalHeaderKey(name)] = values
+	}
+
+	return writeResponse(w, resp.StatusCode, resp.Body, resp.IsBase64Encoded)
+}
+
+// writeHTTPResponse writes an HTTP API (payload format 2.0) response and returns its status.
+func writeHTTPResponse(w http.ResponseWriter, payload []byte) (int, error) {
+	var resp events.APIGatewayV2HTTPResponse
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return 0, err
+	}
+
+	for name, value := range resp.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range resp.MultiValueHeaders {
+		w.Header()[http.CanonicalHeaderKey(name)] = values
+	}
+	for _, cookie := range resp.Cookies {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+
+	return writeResponse(w, resp.StatusCode, resp.Body, resp.IsBase64Encoded)
+}
+
+func writeResponse(w http.ResponseWriter, status int, body string, isBase64 bool) (int, error) {
+	raw := []byte(body)
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode base64 body: %w", err)
+		}
+		raw = decoded
+	}
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(raw)
+	return status, nil
+}
+
+// writeMessage writes an error in the format of API Gateway.
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// requestHeaders returns the headers of the request with lower-cased names,
+// including Host, which net/http moves out of the header map.
+func requestHeaders(r *http.Request) map[string][]string {
+	headers := map[string][]string{"host": {r.Host}}
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = values
+	}
+	return headers
+}
+
+// encodeBody returns the body as sent by API Gateway: as is if it is text,
+// base64-encoded otherwise.
+func encodeBody(body []byte) (string, bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+// sourceIP returns the IP address of the client.
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/xarunoba/mlgmr/cmd/internal/emulator"
+)
+
+// fakeFunction answers invocations in-process, standing in for a function process.
+type fakeFunction func(event []byte) emulator.Result
+
+func (f fakeFunction) Invoke(ctx context.Context, inv *emulator.Invocation) (emulator.Result, error) {
+	return f(inv.Payload), nil
+}
+
+// newTestServer serves routes with fn.
+func newTestServer(t *testing.T, routes []route, fn fakeFunction) *httptest.Server {
+	t.Helper()
+
+	srv := &server{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	srv.setRoutes(routes, map[string]invoker{"test": fn})
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestServer_ProxyRoundTrip(t *testing.T) {
+	sent := make(chan []byte, 1)
+	ts := newTestServer(t, []route{newRoute("POST", "/items/{id}", "test", false)}, func(event []byte) emulator.Result {
+		sent <- event
+		body, _ := json.Marshal(events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       `{"id":"42"}`,
+		})
+		return emulator.Result{Payload: body}
+	})
+
+	req, _ := http.NewRequest("POST", ts.URL+"/items/42?tag=a&tag=b", strings.NewReader(`{"name":"Ada"}`))
+	req.Header.Set("Idempotency-Key", "abc")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", resp.StatusCode)
+	}
+	if string(body) != `{"id":"42"}` {
+		t.Errorf("Expected the function's body, got '%s'", body)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the function's headers, got %v", resp.Header)
+	}
+
+	var received events.APIGatewayProxyRequest
+	json.Unmarshal(<-sent, &received)
+
+	if received.HTTPMethod != "POST" || received.Path != "/items/42" || received.Resource != "/items/{id}" {
+		t.Errorf("Expected the request line in the event, got %s %s (%s)", received.HTTPMethod, received.Path, received.Resource)
+	}
+	if received.Body != `{"name":"Ada"}` || received.IsBase64Encoded {
+		t.Errorf("Expected the JSON body as is, got '%s'", received.Body)
+	}
+	if received.PathParameters["id"] != "42" {
+		t.Errorf("Expected path parameter id '42', got '%s'", received.PathParameters["id"])
+	}
+	if tags := received.MultiValueQueryStringParameters["tag"]; len(tags) != 2 || received.QueryStringParameters["tag"] != "b" {
+		t.Errorf("Expected repeated query parameters, got %v and %v", tags, received.QueryStringParameters)
+	}
+	if received.Headers["idempotency-key"] != "abc" {
+		t.Errorf("Expected lower-cased headers, got %v", received.Headers)
+	}
+	if received.RequestContext.RequestID == "" || received.RequestContext.Identity.SourceIP != "127.0.0.1" {
+		t.Errorf("Expected the request ID and source IP in the request context, got %+v", received.RequestContext)
+	}
+}
+
+func TestServer_HTTPRoundTrip(t *testing.T) {
+	sent := make(chan []byte, 1)
+	ts := newTestServer(t, []route{newRoute("ANY", "/{proxy+}", "test", true)}, func(event []byte) emulator.Result {
+		sent <- event
+		body, _ := json.Marshal(events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Body: "aGk=", IsBase64Encoded: true})
+		return emulator.Result{Payload: body}
+	})
+
+	resp, err := http.Get(ts.URL + "/a/b?tag=a&tag=b")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if string(body) != "hi" {
+		t.Errorf("Expected the decoded base64 body, got '%s'", body)
+	}
+	var received events.APIGatewayV2HTTPRequest
+	json.Unmarshal(<-sent, &received)
+
+	if received.RouteKey != "$default" || received.RequestContext.HTTP.Method != "GET" || received.RawPath != "/a/b" {
+		t.Errorf("Expected a $default GET /a/b event, got %s %s %s", received.RouteKey, received.RequestContext.HTTP.Method, received.RawPath)
+	}
+	if received.PathParameters["proxy"] != "a/b" {
+		t.Errorf("Expected path parameter proxy 'a/b', got '%s'", received.PathParameters["proxy"])
+	}
+	if received.QueryStringParameters["tag"] != "a,b" {
+		t.Errorf("Expected repeated query parameters joined with commas, got '%s'", received.QueryStringParameters["tag"])
+	}
+}
+
+func TestServer_FunctionError(t *testing.T) {
+	ts := newTestServer(t, []route{newRoute("POST", "/", "test", false)}, func(event []byte) emulator.Result {
+		return emulator.Result{Error: &emulator.FunctionError{Message: "boom", Type: "errorString"}}
+	})
+
+	resp, err := http.Post(ts.URL+"/", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "Internal server error") {
+		t.Errorf("Expected the API Gateway error message, got '%s'", body)
+	}
+}
+
+func TestServer_NoRoute(t *testing.T) {
+	ts := newTestServer(t, []route{newRoute("POST", "/", "test", false)}, func(event []byte) emulator.Result {
+		t.Error("Expected the function not to be invoked")
+		return emulator.Result{}
+	})
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestEncodeBody(t *testing.T) {
+	if body, isBase64 := encodeBody([]byte(`{"name":"Ada"}`)); isBase64 || body != `{"name":"Ada"}` {
+		t.Errorf("Expected text bodies to be sent as is, got '%s' (base64 %v)", body, isBase64)
+	}
+	if body, isBase64 := encodeBody([]byte{0xff, 0xfe}); !isBase64 || body != "//4=" {
+		t.Errorf("Expected binary bodies to be base64-encoded, got '%s' (base64 %v)", body, isBase64)
+	}
+}
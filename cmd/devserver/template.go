@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xarunoba/mlgmr/cmd/internal/emulator"
+	"gopkg.in/yaml.v3"
+)
+
+// Lambda defaults applied when the template does not set them.
+const (
+	defaultTimeout    = 3 * time.Second
+	defaultMemorySize = 128
+)
+
+// samTemplate is the subset of a SAM template the dev server understands.
+type samTemplate struct {
+	Parameters map[string]struct {
+		Default yaml.Node `yaml:"Default"`
+	} `yaml:"Parameters"`
+	Globals struct {
+		Function samFunctionProperties `yaml:"Function"`
+	} `yaml:"Globals"`
+	Resources map[string]struct {
+		Type       string                `yaml:"Type"`
+		Properties samFunctionProperties `yaml:"Properties"`
+	} `yaml:"Resources"`
+}
+
+type samFunctionProperties struct {
+	Timeout     int `yaml:"Timeout"`
+	MemorySize  int `yaml:"MemorySize"`
+	Environment struct {
+		Variables map[string]yaml.Node `yaml:"Variables"`
+	} `yaml:"Environment"`
+	Events map[string]struct {
+		Type       string `yaml:"Type"`
+		Properties struct {
+			Path   string `yaml:"Path"`
+			Method string `yaml:"Method"`
+		} `yaml:"Properties"`
+	} `yaml:"Events"`
+}
+
+// functionSpec describes a function directory and its settings from the template.
+type functionSpec struct {
+	// Name is the directory name under functions/, e.g. "greeter".
+	Name string
+	// Resource is the logical ID of the template resource, e.g. "GreeterFunction".
+	// It is empty for functions missing from the template.
+	Resource   string
+	Timeout    time.Duration
+	MemorySize int
+	// Env holds the template's environment variables that could be resolved.
+	Env    map[string]string
+	Routes []route
+}
+
+// FunctionName returns the name reported to the function as AWS_LAMBDA_FUNCTION_NAME.
+func (s functionSpec) FunctionName() string {
+	if s.Resource != "" {
+		return s.Resource
+	}
+	return s.Name
+}
+
+// config returns the settings of the function for the emulator.
+func (s functionSpec) config() emulator.Config {
+	return emulator.Config{
+		Name:       s.FunctionName(),
+		Timeout:    s.Timeout,
+		MemorySize: s.MemorySize,
+		Env:        s.Env,
+	}
+}
+
+// loadFunctions discovers the functions under dir (every subdirectory with a main.go)
+// and applies the settings of their template resources. A resource belongs to the
+// directory whose name matches its logical ID without the "Function" suffix,
+// case-insensitively (GreeterFunction -> functions/greeter). Functions missing from
+// the template are mounted at POST /<name>.
+func loadFunctions(dir, templatePath string, overrides map[string]string) ([]functionSpec, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read functions directory: %w", err)
+	}
+
+	var tmpl samTemplate
+	source, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	if err := yaml.Unmarshal(source, &tmpl); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	params := resolveParameters(tmpl, overrides)
+
+	var specs []functionSpec
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, entry.Name(), "main.go")); err != nil {
+			continue
+		}
+
+		spec := functionSpec{
+			Name:       entry.Name(),
+			Timeout:    defaultTimeout,
+			MemorySize: defaultMemorySize,
+			Env:        map[string]string{},
+		}
+		spec.apply(tmpl.Globals.Function, params)
+
+		for _, id := range slices.Sorted(maps.Keys(tmpl.Resources)) {
+			resource := tmpl.Resources[id]
+			if resource.Type != "AWS::Serverless::Function" || !strings.EqualFold(strings.TrimSuffix(id, "Function"), entry.Name()) {
+				continue
+			}
+
+			spec.Resource = id
+			spec.apply(resource.Properties, params)
+			spec.Routes = eventRoutes(resource.Properties, entry.Name())
+			break
+		}
+
+		if spec.Resource == "" {
+			spec.Routes = []route{newRoute("POST", "/"+entry.Name(), entry.Name(), false)}
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// apply overlays the settings of props on the spec.
+func (s *functionSpec) apply(props samFunctionProperties, params map[string]string) {
+	if props.Timeout > 0 {
+		s.Timeout = time.Duration(props.Timeout) * time.Second
+	}
+	if props.MemorySize > 0 {
+		s.MemorySize = props.MemorySize
+	}
+	for name, node := range props.Environment.Variables {
+		if value, ok := resolveValue(node, params); ok {
+			s.Env[name] = value
+		}
+	}
+}
+
+// eventRoutes returns the routes of the Api (REST, payload 1.0) and HttpApi
+// (payload 2.0) events of a function.
+func eventRoutes(props samFunctionProperties, function string) []route {
+	var routes []route
+	for _, name := range slices.Sorted(maps.Keys(props.Events)) {
+		event := props.Events[name]
+		switch event.Type {
+		case "Api":
+			routes = append(routes, newRoute(event.Properties.Method, event.Properties.Path, function, false))
+		case "HttpApi":
+			method, path := event.Properties.Method, event.Properties.Path
+			if method == "" {
+				method = "ANY"
+			}
+			if path == "" {
+				// An HttpApi event without a path is the $default route
+				path = "/{proxy+}"
+			}
+			routes = append(routes, newRoute(method, path, function, true))
+		}
+	}
+	return routes
+}
+
+// resolveParameters returns the template parameter values: the defaults, replaced by
+// overrides.
+func resolveParameters(tmpl samTemplate, overrides map[string]string) map[string]string {
+	params := map[string]string{}
+	for name, param := range tmpl.Parameters {
+		if param.Default.Kind == yaml.ScalarNode {
+			params[name] = param.Default.Value
+		}
+	}
+	for name, value := range overrides {
+		params[name] = value
+	}
+	return params
+}
+
+// resolveValue resolves a literal or a !Ref to a parameter. Other intrinsic functions
+// and parameters without a value are not supported; such variables are left unset.
+func resolveValue(node yaml.Node, params map[string]string) (string, bool) {
+	if node.Kind != yaml.ScalarNode {
+		return "", false
+	}
+
+	switch node.Tag {
+	case "!Ref":
+		value, ok := params[node.Value]
+		return value, ok
+	case "!!str", "!!int", "!!bool", "!!float":
+		return node.Value, true
+	default:
+		return "", false
+	}
+}
+
+// parseOverrides parses parameter overrides in the format of
+// `sam --parameter-overrides`: space-separated Key=Value pairs.
+func parseOverrides(s string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for field := range strings.FieldsSeq(s) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid parameter override %q, expected Key=Value", field)
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		overrides[name] = value
+	}
+	return overrides, nil
+}
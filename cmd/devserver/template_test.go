@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testTemplate = `
+Parameters:
+  MongoDBUri:
+    Type: String
+    Default: mongodb://localhost:27017/app
+  RedisUri:
+    Type: String
+    NoEcho: true
+Globals:
+  Function:
+    Timeout: 5
+    MemorySize: 256
+Resources:
+  GreeterFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      MemorySize: 512
+      Environment:
+        Variables:
+          MONGODB_URI: !Ref MongoDBUri
+          REDIS_URI: !Ref RedisUri
+          LOG_LEVEL: debug
+          REGION: !Sub "${AWS::Region}"
+      Events:
+        Api:
+          Type: Api
+          Properties:
+            Path: /
+            Method: POST
+        Items:
+          Type: HttpApi
+  GreeterTable:
+    Type: AWS::DynamoDB::Table
+`
+
+// writeProject creates a template and function directories with a main.go.
+func writeProject(t *testing.T, functions ...string) (dir, templatePath string) {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, name := range append(functions, "notafunction") {
+		if err := os.MkdirAll(filepath.Join(root, "functions", name), 0o755); err != nil {
+			t.Fatalf("Failed to create function directory: %v", err)
+		}
+	}
+	for _, name := range functions {
+		if err := os.WriteFile(filepath.Join(root, "functions", name, "main.go"), []byte("package main\n"), 0o644); err != nil {
+			t.Fatalf("Failed to write main.go: %v", err)
+		}
+	}
+
+	templatePath = filepath.Join(root, "template.yaml")
+	if err := os.WriteFile(templatePath, []byte(testTemplate), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	return filepath.Join(root, "functions"), templatePath
+}
+
+func TestLoadFunctions(t *testing.T) {
+	dir, templatePath := writeProject(t, "greeter", "billing")
+
+	specs, err := loadFunctions(dir, templatePath, map[string]string{"RedisUri": "redis://localhost:6379"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(specs) != 2 {
+		t.Fatalf("Expected 2 functions, got %d", len(specs))
+	}
+	billing, greeter := specs[0], specs[1]
+
+	if greeter.Resource != "GreeterFunction" || greeter.FunctionName() != "GreeterFunction" {
+		t.Errorf("Expected greeter to match GreeterFunction, got '%s'", greeter.Resource)
+	}
+	if greeter.Timeout != 5*time.Second {
+		t.Errorf("Expected the global timeout of 5s, got %v", greeter.Timeout)
+	}
+	if greeter.MemorySize != 512 {
+		t.Errorf("Expected the function's memory size of 512, got %d", greeter.MemorySize)
+	}
+
+	expectedEnv := map[string]string{
+		"MONGODB_URI": "mongodb://localhost:27017/app",
+		"REDIS_URI":   "redis://localhost:6379",
+		"LOG_LEVEL":   "debug",
+	}
+	for name, value := range expectedEnv {
+		if greeter.Env[name] != value {
+			t.Errorf("Expected %s '%s', got '%s'", name, value, greeter.Env[name])
+		}
+	}
+	if _, ok := greeter.Env["REGION"]; ok {
+		t.Error("Expected variables using unsupported intrinsic functions to be left unset")
+	}
+
+	if len(greeter.Routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %d", len(greeter.Routes))
+	}
+	if rt := greeter.Routes[0]; rt.Method != "POST" || rt.Path != "/" || rt.PayloadV2 {
+		t.Errorf("Expected the Api event to be a REST route POST /, got %+v", rt)
+	}
+	if rt := greeter.Routes[1]; rt.Method != "ANY" || rt.Path != "/{proxy+}" || !rt.PayloadV2 {
+		t.Errorf("Expected the HttpApi event without path to be the $default route, got %+v", rt)
+	}
+
+	if billing.Resource != "" || billing.Timeout != 5*time.Second {
+		t.Errorf("Expected billing to use the globals, got %+v", billing)
+	}
+	if len(billing.Routes) != 1 || billing.Routes[0].Method != "POST" || billing.Routes[0].Path != "/billing" {
+		t.Errorf("Expected billing to be mounted at POST /billing, got %+v", billing.Routes)
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := parseOverrides(`LogLevel=debug MongoDBUri="mongodb://localhost:27017/app"`)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if overrides["LogLevel"] != "debug" {
+		t.Errorf("Expected LogLevel 'debug', got '%s'", overrides["LogLevel"])
+	}
+	if overrides["MongoDBUri"] != "mongodb://localhost:27017/app" {
+		t.Errorf("Expected quotes to be removed, got '%s'", overrides["MongoDBUri"])
+	}
+
+	if _, err := parseOverrides("LogLevel"); err == nil {
+		t.Error("Expected an error for an override without a value")
+	}
+}
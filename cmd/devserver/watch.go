@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshot maps the watched files to their modification times.
+type snapshot map[string]time.Time
+
+// watcher polls the source files of the functions for changes. Polling is less
+// efficient than file system notifications, but works the same everywhere, including
+// in containers and on network file systems.
+type watcher struct {
+	root     string
+	dirs     []string
+	files    []string
+	interval time.Duration
+}
+
+// scan returns the modification times of the Go sources (excluding tests) under the
+// watched directories and of the watched files.
+func (w *watcher) scan() snapshot {
+	snap := snapshot{}
+
+	for _, dir := range w.dirs {
+		filepath.WalkDir(filepath.Join(w.root, dir), func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if entry.IsDir() {
+				if path != filepath.Join(w.root, dir) && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+
+			if info, err := entry.Info(); err == nil {
+				snap[path] = info.ModTime()
+			}
+			return nil
+		})
+	}
+
+	for _, file := range w.files {
+		if info, err := os.Stat(filepath.Join(w.root, file)); err == nil {
+			snap[filepath.Join(w.root, file)] = info.ModTime()
+		}
+	}
+
+	return snap
+}
+
+// watch calls onChange with the paths that were added, modified or removed, until
+// ctx is done. Changes are reported once they settle for an interval, so saving
+// several files at once triggers one reload.
+func (w *watcher) watch(ctx context.Context, onChange func(changed []string)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	last := w.scan()
+	var pending []string
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := w.scan()
+		changed := diff(last, current)
+		last = current
+
+		if len(changed) > 0 {
+			pending = append(pending, changed...)
+			continue
+		}
+		if len(pending) > 0 {
+			onChange(pending)
+			pending = nil
+		}
+	}
+}
+
+// diff returns the paths that differ between two snapshots.
+func diff(before, after snapshot) []string {
+	var changed []string
+	for path, modTime := range after {
+		if previous, ok := before[path]; !ok || !previous.Equal(modTime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,229 @@
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/invoke"
+)
+
+// stopGracePeriod is how long a function process may run its SIGTERM handlers
+// (such as flushing traces) before it is killed, as Lambda allows functions with
+// internal extensions only.
+const stopGracePeriod = 500 * time.Millisecond
+
+// ErrTimeout is returned for invocations that exceed the timeout of the function.
+var ErrTimeout = errors.New("task timed out")
+
+// Config holds the settings of a function.
+type Config struct {
+	// Name is reported to the function as AWS_LAMBDA_FUNCTION_NAME.
+	Name       string
+	Timeout    time.Duration
+	MemorySize int
+	// Env holds variables set for the function unless they are already set in the
+	// environment.
+	Env map[string]string
+}
+
+// Function runs the binary of a function against its own Runtime API emulator,
+// the way Lambda runs one instance of it.
+type Function struct {
+	// Stdout and Stderr receive the output of the process, i.e. its logs.
+	// They default to os.Stdout and os.Stderr.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	root   string
+	pkg    string
+	binary string
+	api    *RuntimeAPI
+	logger *slog.Logger
+
+	// mu serializes invocations and restarts; like a Lambda instance, a process
+	// handles one invocation at a time.
+	mu   sync.Mutex
+	cfg  Config
+	proc *process
+}
+
+// process is a running function binary.
+type process struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// NewFunction prepares the function of the main package pkg (e.g. "./functions/greeter")
+// of the module at root. Build compiles it to binary.
+func NewFunction(root, pkg, binary string, cfg Config, logger *slog.Logger) (*Function, error) {
+	api, err := NewRuntimeAPI(invoke.FunctionARN(cfg.Name), logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Function{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		root:   root,
+		pkg:    pkg,
+		binary: binary,
+		api:    api,
+		logger: logger,
+		cfg:    cfg,
+	}, nil
+}
+
+// Build compiles the function. The running process keeps its binary if the build fails.
+func (f *Function) Build(ctx context.Context) error {
+	tmp := f.binary + ".new"
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", tmp, f.pkg)
+	cmd.Dir = f.root
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to build %s: %w\n%s", f.pkg, err, bytes.TrimSpace(output.Bytes()))
+	}
+
+	return os.Rename(tmp, f.binary)
+}
+
+// Restart stops the running process, if any, and starts the current binary with cfg.
+func (f *Function) Restart(cfg Config) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stop()
+	f.cfg = cfg
+	return f.start()
+}
+
+// Invoke sends an event to the function and waits for its result. It starts the
+// process if it is not running, e.g. because it crashed, and kills it if the
+// invocation exceeds the function's timeout.
+func (f *Function) Invoke(ctx context.Context, inv *Invocation) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.running() {
+		if err := f.start(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	inv.Deadline = time.Now().Add(f.cfg.Timeout)
+	ctx, cancel := context.WithDeadline(ctx, inv.Deadline)
+	defer cancel()
+
+	result, err := f.api.invoke(ctx, inv, f.proc.exited)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		// Lambda discards an instance whose invocation timed out
+		f.stop()
+		return Result{}, fmt.Errorf("%w after %.2f seconds", ErrTimeout, f.cfg.Timeout.Seconds())
+	case errors.Is(err, context.Canceled):
+		// The caller went away; the process may still be busy with the event
+		f.stop()
+		return Result{}, err
+	case err != nil:
+		f.proc = nil
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+// Close stops the process and the Runtime API emulator.
+func (f *Function) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stop()
+	return f.api.Close()
+}
+
+// running reports whether the process is alive. f.mu must be held.
+func (f *Function) running() bool {
+	if f.proc == nil {
+		return false
+	}
+	select {
+	case <-f.proc.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// start launches the binary. f.mu must be held.
+func (f *Function) start() error {
+	cmd := exec.Command(f.binary)
+	cmd.Dir = f.root
+	cmd.Env = f.environ()
+	cmd.Stdout = f.Stdout
+	cmd.Stderr = f.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", f.pkg, err)
+	}
+
+	proc := &process{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		close(proc.exited)
+		f.logger.Debug("Function process exited", slog.Any("error", err))
+	}()
+
+	f.proc = proc
+	return nil
+}
+
+// stop sends SIGTERM to the process and kills it if it has not exited after
+// stopGracePeriod. f.mu must be held.
+func (f *Function) stop() {
+	if !f.running() {
+		f.proc = nil
+		return
+	}
+
+	f.proc.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-f.proc.exited:
+	case <-time.After(stopGracePeriod):
+		f.proc.cmd.Process.Kill()
+		<-f.proc.exited
+	}
+	f.proc = nil
+}
+
+// environ returns the environment of the process: the current environment, the
+// configured variables unless already set, and the variables of the Lambda runtime.
+// f.mu must be held.
+func (f *Function) environ() []string {
+	env := os.Environ()
+	for name, value := range f.cfg.Env {
+		if _, ok := os.LookupEnv(name); !ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	return append(env,
+		"AWS_LAMBDA_RUNTIME_API="+f.api.Addr(),
+		"AWS_LAMBDA_FUNCTION_NAME="+f.cfg.Name,
+		"AWS_LAMBDA_FUNCTION_VERSION=$LATEST",
+		"AWS_LAMBDA_FUNCTION_MEMORY_SIZE="+strconv.Itoa(f.cfg.MemorySize),
+	)
+}
@@ -0,0 +1,217 @@
+// Package emulator runs Lambda function binaries locally against an emulated Lambda
+// Runtime API, so the commands under cmd/ exercise a function's own main, middleware
+// stack and adapters exactly as they run when deployed.
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrProcessExited is returned for invocations interrupted by the function process exiting.
+var ErrProcessExited = errors.New("function process exited")
+
+// Invocation is an event sent to the function process.
+type Invocation struct {
+	RequestID string
+	Payload   []byte
+	// Deadline is set by Function.Invoke from the timeout of the function.
+	Deadline    time.Time
+	TraceHeader string
+
+	result chan Result
+}
+
+// Result is the outcome reported by the function process.
+type Result struct {
+	Payload []byte
+	// Error is set if the handler failed or panicked.
+	Error *FunctionError
+}
+
+// FunctionError is the error document a Lambda runtime reports for a failed invocation.
+type FunctionError struct {
+	Message string `json:"errorMessage"`
+	Type    string `json:"errorType"`
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// RuntimeAPI emulates the parts of the Lambda Runtime API and Extensions API that
+// aws-lambda-go uses, so a function binary runs unmodified with AWS_LAMBDA_RUNTIME_API
+// pointing at it. Like Lambda, it hands out one invocation at a time.
+type RuntimeAPI struct {
+	functionARN string
+	listener    net.Listener
+	server      *http.Server
+	invocations chan *Invocation
+	logger      *slog.Logger
+
+	mu      sync.Mutex
+	pending map[string]*Invocation
+}
+
+// NewRuntimeAPI starts a Runtime API server on a random local port.
+func NewRuntimeAPI(functionARN string, logger *slog.Logger) (*RuntimeAPI, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the runtime API: %w", err)
+	}
+
+	api := &RuntimeAPI{
+		functionARN: functionARN,
+		listener:    listener,
+		invocations: make(chan *Invocation),
+		logger:      logger,
+		pending:     map[string]*Invocation{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /2018-06-01/runtime/invocation/next", api.next)
+	mux.HandleFunc("POST /2018-06-01/runtime/invocation/{id}/response", api.response)
+	mux.HandleFunc("POST /2018-06-01/runtime/invocation/{id}/error", api.invocationError)
+	mux.HandleFunc("POST /2018-06-01/runtime/init/error", api.initError)
+	mux.HandleFunc("POST /2020-01-01/extension/register", api.registerExtension)
+	mux.HandleFunc("GET /2020-01-01/extension/event/next", api.nextExtensionEvent)
+
+	api.server = &http.Server{Handler: mux}
+	go api.server.Serve(listener)
+
+	return api, nil
+}
+
+// Addr returns the host:port to pass to the function as AWS_LAMBDA_RUNTIME_API.
+func (api *RuntimeAPI) Addr() string {
+	return api.listener.Addr().String()
+}
+
+// Close stops the server.
+func (api *RuntimeAPI) Close() error {
+	return api.server.Close()
+}
+
+// invoke hands inv to the function process and waits for its result. It gives up
+// when ctx is done or exited is closed, since the process will never answer then.
+func (api *RuntimeAPI) invoke(ctx context.Context, inv *Invocation, exited <-chan struct{}) (Result, error) {
+	inv.result = make(chan Result, 1)
+
+	select {
+	case api.invocations <- inv:
+	case <-exited:
+		return Result{}, ErrProcessExited
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+
+	defer func() {
+		api.mu.Lock()
+		delete(api.pending, inv.RequestID)
+		api.mu.Unlock()
+	}()
+
+	select {
+	case result := <-inv.result:
+		return result, nil
+	case <-exited:
+		return Result{}, ErrProcessExited
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// next blocks until an invocation is queued and sends it to the function.
+func (api *RuntimeAPI) next(w http.ResponseWriter, r *http.Request) {
+	var inv *Invocation
+	select {
+	case inv = <-api.invocations:
+	case <-r.Context().Done():
+		return
+	}
+
+	api.mu.Lock()
+	api.pending[inv.RequestID] = inv
+	api.mu.Unlock()
+
+	w.Header().Set("Lambda-Runtime-Aws-Request-Id", inv.RequestID)
+	w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(inv.Deadline.UnixMilli(), 10))
+	w.Header().Set("Lambda-Runtime-Invoked-Function-Arn", api.functionARN)
+	w.Header().Set("Lambda-Runtime-Trace-Id", inv.TraceHeader)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(inv.Payload)
+}
+
+// response receives the output of a successful invocation.
+func (api *RuntimeAPI) response(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// aws-lambda-go reports errors while streaming the response in trailers
+	result := Result{Payload: payload}
+	if errorType := r.Trailer.Get("Lambda-Runtime-Function-Error-Type"); errorType != "" {
+		result = Result{Error: &FunctionError{Type: errorType, Message: r.Trailer.Get("Lambda-Runtime-Function-Error-Body")}}
+	}
+
+	api.complete(w, r.PathValue("id"), result)
+}
+
+// invocationError receives the error of a failed invocation.
+func (api *RuntimeAPI) invocationError(w http.ResponseWriter, r *http.Request) {
+	fnErr := &FunctionError{}
+	if err := json.NewDecoder(r.Body).Decode(fnErr); err != nil {
+		fnErr = &FunctionError{Type: "Unknown", Message: "function reported an unreadable error"}
+	}
+
+	api.complete(w, r.PathValue("id"), Result{Error: fnErr})
+}
+
+// complete delivers the result of the invocation with the given request ID.
+func (api *RuntimeAPI) complete(w http.ResponseWriter, requestID string, result Result) {
+	api.mu.Lock()
+	inv, ok := api.pending[requestID]
+	delete(api.pending, requestID)
+	api.mu.Unlock()
+
+	if !ok {
+		// The invocation timed out or its caller went away
+		http.Error(w, "unknown request ID", http.StatusBadRequest)
+		return
+	}
+
+	inv.result <- result
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// initError logs a failure of the function to initialize; the process exits afterwards.
+func (api *RuntimeAPI) initError(w http.ResponseWriter, r *http.Request) {
+	var fnErr FunctionError
+	json.NewDecoder(r.Body).Decode(&fnErr)
+	api.logger.Error("Function failed to initialize", slog.String("errorType", fnErr.Type), slog.String("error", fnErr.Message))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// registerExtension accepts the internal extension aws-lambda-go registers to receive
+// SIGTERM before shutdown (see lambda.WithEnableSIGTERM).
+func (api *RuntimeAPI) registerExtension(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Lambda-Extension-Identifier", r.Header.Get("Lambda-Extension-Name"))
+	w.WriteHeader(http.StatusOK)
+}
+
+// nextExtensionEvent blocks until the process exits; the dev server sends no events
+// to extensions.
+func (api *RuntimeAPI) nextExtensionEvent(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+}
@@ -0,0 +1,125 @@
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeProcess plays the function process: it polls the Runtime API for invocations and
+// answers each with respond, like aws-lambda-go does. Answers are posted to
+// ".../response" or ".../error" depending on the returned path.
+func fakeProcess(t *testing.T, api *RuntimeAPI, respond func(event []byte, header http.Header) (path string, body []byte)) {
+	t.Helper()
+
+	base := "http://" + api.Addr() + "/2018-06-01/runtime/invocation/"
+	go func() {
+		for {
+			resp, err := http.Get(base + "next")
+			if err != nil {
+				return
+			}
+			event, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			path, body := respond(event, resp.Header)
+			reply, err := http.Post(base+resp.Header.Get("Lambda-Runtime-Aws-Request-Id")+"/"+path, "application/json", bytes.NewReader(body))
+			if err != nil {
+				return
+			}
+			reply.Body.Close()
+		}
+	}()
+}
+
+func newTestRuntimeAPI(t *testing.T) *RuntimeAPI {
+	t.Helper()
+
+	api, err := NewRuntimeAPI("arn:aws:lambda:us-east-1:000000000000:function:test", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Failed to start runtime API: %v", err)
+	}
+	t.Cleanup(func() { api.Close() })
+	return api
+}
+
+func TestRuntimeAPI_Response(t *testing.T) {
+	api := newTestRuntimeAPI(t)
+	headers := make(chan http.Header, 1)
+	fakeProcess(t, api, func(event []byte, header http.Header) (string, []byte) {
+		headers <- header
+		return "response", append([]byte("echo:"), event...)
+	})
+
+	deadline := time.Now().Add(time.Minute)
+	inv := &Invocation{RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef", Payload: []byte(`{}`), Deadline: deadline, TraceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793"}
+	result, err := api.invoke(context.Background(), inv, make(chan struct{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if result.Error != nil || string(result.Payload) != "echo:{}" {
+		t.Errorf("Expected the function's response, got '%s' (error %v)", result.Payload, result.Error)
+	}
+
+	header := <-headers
+	expected := map[string]string{
+		"Lambda-Runtime-Aws-Request-Id":       inv.RequestID,
+		"Lambda-Runtime-Deadline-Ms":          strconv.FormatInt(deadline.UnixMilli(), 10),
+		"Lambda-Runtime-Invoked-Function-Arn": "arn:aws:lambda:us-east-1:000000000000:function:test",
+		"Lambda-Runtime-Trace-Id":             inv.TraceHeader,
+	}
+	for name, value := range expected {
+		if header.Get(name) != value {
+			t.Errorf("Expected %s '%s', got '%s'", name, value, header.Get(name))
+		}
+	}
+}
+
+func TestRuntimeAPI_Error(t *testing.T) {
+	api := newTestRuntimeAPI(t)
+	fakeProcess(t, api, func(event []byte, header http.Header) (string, []byte) {
+		return "error", []byte(`{"errorMessage":"boom","errorType":"errorString"}`)
+	})
+
+	result, err := api.invoke(context.Background(), &Invocation{RequestID: "1", Payload: []byte(`{}`)}, make(chan struct{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if result.Error == nil || result.Error.Message != "boom" || result.Error.Type != "errorString" {
+		t.Errorf("Expected the function's error, got %v", result.Error)
+	}
+}
+
+func TestRuntimeAPI_ProcessExited(t *testing.T) {
+	api := newTestRuntimeAPI(t)
+
+	exited := make(chan struct{})
+	close(exited)
+
+	_, err := api.invoke(context.Background(), &Invocation{RequestID: "1", Payload: []byte(`{}`)}, exited)
+	if !errors.Is(err, ErrProcessExited) {
+		t.Errorf("Expected ErrProcessExited, got '%v'", err)
+	}
+}
+
+func TestRuntimeAPI_Timeout(t *testing.T) {
+	api := newTestRuntimeAPI(t)
+	fakeProcess(t, api, func(event []byte, header http.Header) (string, []byte) {
+		time.Sleep(100 * time.Millisecond)
+		return "response", event
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := api.invoke(ctx, &Invocation{RequestID: "1", Payload: []byte(`{}`)}, make(chan struct{}))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the invocation to time out, got '%v'", err)
+	}
+}
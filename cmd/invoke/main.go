@@ -0,0 +1,221 @@
+// Command invoke replays event files against a function and prints its logs and output.
+//
+// Usage:
+//
+//	go run ./cmd/invoke [-timeout 3s] [-golden] [-update] <function> [event.json|dir ...]
+//
+// It must be run from the module root. Without event paths, every .json file in
+// functions/<function>/events/ is replayed, in name order, by the same process, so the
+// first event is a cold start and the others are warm.
+//
+// Functions are main packages and cannot be imported, so invoke builds the function and
+// runs it against an emulated Lambda Runtime API (like cmd/devserver): events go through
+// the function's own main, middleware stack and adapters, with a synthetic Lambda
+// context (request ID, deadline, X-Ray trace header). The function reads its
+// configuration, such as MONGODB_URI, from the environment.
+//
+// With -golden, each payload (the output, or the error document of a failed invocation)
+// is compared with the golden file next to its event (event.json -> event.golden), and
+// -update rewrites the golden files. Events without a golden file are not compared: their
+// output usually depends on the data and clock of the environment. To replay fixtures in
+// `go test` instead, invoke the handler in-process with the shared/invoke package.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xarunoba/mlgmr/cmd/internal/emulator"
+	"github.com/xarunoba/mlgmr/shared/invoke"
+)
+
+// logSettleDelay gives the copy of the function's output a moment to catch up
+// before the logs of an invocation are printed.
+const logSettleDelay = 20 * time.Millisecond
+
+func main() {
+	timeout := flag.Duration("timeout", invoke.DefaultTimeout, "function timeout")
+	golden := flag.Bool("golden", false, "compare payloads with the golden files next to the events")
+	update := flag.Bool("update", false, "rewrite the golden files next to the events")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: invoke [flags] <function> [event.json|dir ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Args()[1:], *timeout, *golden, *update); err != nil {
+		fmt.Fprintln(os.Stderr, "invoke:", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, paths []string, timeout time.Duration, golden, update bool) error {
+	ctx := context.Background()
+
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return errors.New("go.mod not found, run invoke from the module root")
+	}
+
+	if len(paths) == 0 {
+		paths = []string{filepath.Join("functions", name, "events")}
+	}
+	var fixtures []invoke.Event
+	for _, path := range paths {
+		events, err := invoke.LoadEvents(path)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, events...)
+	}
+	if len(fixtures) == 0 {
+		return fmt.Errorf("no events found in %v", paths)
+	}
+
+	binDir, err := os.MkdirTemp("", "mlgmr-invoke-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(binDir)
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	fn, err := emulator.NewFunction(root, "./functions/"+name, filepath.Join(binDir, name), emulator.Config{
+		Name:       name,
+		Timeout:    timeout,
+		MemorySize: 128,
+	}, logger)
+	if err != nil {
+		return err
+	}
+	defer fn.Close()
+
+	logs := &syncBuffer{}
+	fn.Stdout, fn.Stderr = logs, logs
+
+	if err := fn.Build(ctx); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, event := range fixtures {
+		start := time.Now()
+		payload, invokeErr := invokeEvent(ctx, fn, event)
+		duration := time.Since(start)
+
+		time.Sleep(logSettleDelay)
+		fmt.Printf("=== %s (%s)\n", event.Path, duration.Round(time.Millisecond))
+		os.Stdout.Write(logs.Take())
+
+		if invokeErr != nil {
+			fmt.Println("--- error")
+		} else {
+			fmt.Println("--- output")
+		}
+		fmt.Println(indent(payload))
+
+		_, statErr := os.Stat(event.GoldenPath())
+		switch {
+		case golden && !update && errors.Is(statErr, os.ErrNotExist):
+			fmt.Println("--- golden: none")
+			if invokeErr != nil {
+				failed++
+			}
+		case golden || update:
+			if err := invoke.CompareGolden(event.GoldenPath(), payload, update); err != nil {
+				fmt.Println("--- golden:", err)
+				failed++
+			} else if update {
+				fmt.Println("--- golden: updated", event.GoldenPath())
+			} else {
+				fmt.Println("--- golden: ok")
+			}
+		case invokeErr != nil:
+			failed++
+		}
+		fmt.Println()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events failed", failed, len(fixtures))
+	}
+	return nil
+}
+
+// invokeEvent sends event to the function and returns its payload: the output, or the
+// error document of a failed invocation along with the error.
+func invokeEvent(ctx context.Context, fn *emulator.Function, event invoke.Event) ([]byte, error) {
+	inv := &emulator.Invocation{
+		RequestID:   invoke.NewRequestID(),
+		Payload:     event.Payload,
+		TraceHeader: invoke.NewTraceHeader(time.Now()),
+	}
+
+	result, err := fn.Invoke(ctx, inv)
+	switch {
+	case err != nil:
+		// Lambda reports timeouts and crashes with these error types
+		doc := invoke.ErrorDocument{Message: err.Error(), Type: "Runtime.ExitError"}
+		if errors.Is(err, emulator.ErrTimeout) {
+			doc.Type = "Sandbox.Timedout"
+		}
+		payload, _ := json.Marshal(doc)
+		return payload, err
+	case result.Error != nil:
+		payload, _ := json.Marshal(invoke.ErrorDocument{Message: result.Error.Message, Type: result.Error.Type})
+		return payload, result.Error
+	default:
+		return result.Payload, nil
+	}
+}
+
+// indent returns payload indented if it is JSON, as is otherwise.
+func indent(payload []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		return string(payload)
+	}
+	return buf.String()
+}
+
+// syncBuffer collects the output of the function process, which is copied from
+// another goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+var _ io.Writer = (*syncBuffer)(nil)
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Take returns the collected output and empties the buffer.
+func (b *syncBuffer) Take() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	output := bytes.Clone(b.buf.Bytes())
+	b.buf.Reset()
+	return output
+}
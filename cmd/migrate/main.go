@@ -0,0 +1,69 @@
+// Command migrate applies the registered MongoDB migrations of every function.
+//
+// Usage:
+//
+//	go run ./cmd/migrate [-dry-run] [-scope greeter] [-timeout 5m]
+//
+// It reads MONGODB_URI (and optionally MONGODB_DATABASE) like the functions do.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/db/migrate"
+
+	// Register the migrations of each function
+	_ "github.com/xarunoba/mlgmr/functions/greeter/migrations"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "list pending migrations without applying them")
+	scope := flag.String("scope", "", "comma-separated list of scopes to migrate (default: all)")
+	timeout := flag.Duration("timeout", 5*time.Minute, "maximum time to wait for the lock and apply migrations")
+	flag.Parse()
+
+	if err := run(*dryRun, *scope, *timeout); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dryRun bool, scope string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	database, err := db.DefaultProvider().Database(ctx)
+	if err != nil {
+		return err
+	}
+	defer database.Client().Disconnect(context.Background())
+
+	var opts []migrate.Option
+	if scope != "" {
+		opts = append(opts, migrate.WithScope(strings.Split(scope, ",")...))
+	}
+	migrator := migrate.New(database, opts...)
+
+	if dryRun {
+		return migrator.DryRun(ctx, os.Stdout)
+	}
+
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		fmt.Printf("applied %s  %s\n", migration.ID(), migration.Description)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("No pending migrations.")
+	}
+	return nil
+}
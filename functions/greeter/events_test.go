@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/db/dbtest"
+	"github.com/xarunoba/mlgmr/shared/invoke"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the event fixtures")
+
+// TestEvents replays the fixtures in events/ through the middleware stack and compares
+// the responses with their golden files in testdata/. They depend on the fakes and the
+// fixed clock, so they are kept apart from the golden files next to the events, which
+// cmd/invoke compares against the real databases. After an intended change, rewrite them
+// with `go test ./functions/greeter -run TestEvents -update`.
+func TestEvents(t *testing.T) {
+	// The fakes are installed as the default provider so the Redis-backed middleware
+	// (rate limiting, idempotency) uses them too
+	dbtest.Install(t)
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	handler := newHandler((&Handler{Now: func() time.Time { return now }}).Handle)
+
+	fixtures, err := invoke.LoadEvents("events")
+	if err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	for _, event := range fixtures {
+		t.Run(event.Name, func(t *testing.T) {
+			result := invoke.Invoke(context.Background(), handler, event.Payload, invoke.WithFunctionName("GreeterFunction"))
+			if result.Err != nil {
+				t.Fatalf("Expected the invocation to succeed, got '%v'\n%s", result.Err, result.Logs)
+			}
+
+			golden := filepath.Join("testdata", event.Name+".golden")
+			if err := invoke.CompareGolden(golden, result.Payload(), *update); err != nil {
+				t.Errorf("%v\n%s", err, result.Logs)
+			}
+		})
+	}
+}
@@ -3,20 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/xarunoba/mlgmr/shared"
 	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-// Compile-time check to ensure LambdaFunction implements HandlerFunc
-var _ shared.HandlerFunc[Input, *Output] = LambdaFunction
+// Compile-time checks to ensure LambdaFunction and Handler.Handle implement HandlerFunc
+var (
+	_ shared.HandlerFunc[Input, *Output] = LambdaFunction
+	_ shared.HandlerFunc[Input, *Output] = (*Handler)(nil).Handle
+)
 
 // Input represents the input structure for the Lambda function. (The Event)
 type Input struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=64"`
 }
 
 // Output represents the output structure for the Lambda function.
@@ -31,49 +37,112 @@ type nameDocument struct {
 	CreatedAt int64  `bson:"createdAt"`
 }
 
+// nameIndex is the unique index on "name" that findOrCreateName relies on. The greeter
+// migrations create it; the handler ensures it as well (see Handler.ensureIndex).
+var nameIndex = db.Index{Keys: bson.D{{Key: "name", Value: 1}}, Unique: true}
+
+// indexRetryInterval spaces out the handler's attempts to build nameIndex after one failed.
+const indexRetryInterval = time.Minute
+
+// Handler greets people, keeping track of them in MongoDB and counting greetings in Redis.
+type Handler struct {
+	// DB provides the database clients. It defaults to db.DefaultProvider(), whose
+	// connections persist across invocations. Tests inject fakes here (see package dbtest).
+	DB db.Clients
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	// indexed records that nameIndex exists, so it is checked once per instance
+	indexed atomic.Bool
+	// indexFailedAt is the Unix millisecond time of the last failed index build
+	indexFailedAt atomic.Int64
+}
+
+var defaultHandler = &Handler{}
+
 // LambdaFunction is the main handler function for the AWS Lambda.
 func LambdaFunction(ctx context.Context, input Input) (*Output, error) {
-	mongoClient, err := db.GetMongoClient()
+	return defaultHandler.Handle(ctx, input)
+}
+
+// Handle greets input.Name.
+func (h *Handler) Handle(ctx context.Context, input Input) (*Output, error) {
+	clients := h.DB
+	if clients == nil {
+		clients = db.DefaultProvider()
+	}
+	now := h.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	names, err := db.CollectionFrom[nameDocument](ctx, clients, "name")
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Wrap(err, apperrors.CodeUnavailable, "database unavailable")
 	}
-	// Use (or create) the "mlgmr" database and "name" collection
-	mongoCollection := mongoClient.Database("mlgmr").Collection("name")
+	h.ensureIndex(ctx, names, now())
 
-	redisClient, err := db.GetRedisClient()
+	redisClient, err := clients.Redis(ctx)
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Wrap(err, apperrors.CodeUnavailable, "cache unavailable")
 	}
 
-	var doc nameDocument
-	if check := mongoCollection.FindOne(ctx, bson.M{
-		"name": input.Name,
-	}); check.Err() == mongo.ErrNoDocuments {
-		_, err := mongoCollection.InsertOne(ctx, nameDocument{
-			Name:      input.Name,
-			CreatedAt: time.Now().UnixMilli(),
-		})
-		if err != nil {
-			return nil, err
-		}
-	} else if check.Err() != nil {
-		return nil, check.Err()
-	} else {
-		if err := check.Decode(&doc); err != nil {
-			return nil, err
-		}
+	doc, err := findOrCreateName(ctx, names, input.Name, now())
+	if err != nil {
+		return nil, err
 	}
-	createdAt := time.UnixMilli(doc.CreatedAt).Format("January 2, 2006 at 3:04 PM MST")
+	// Lambda runs in UTC; formatting in UTC keeps messages independent of the local time zone
+	createdAt := time.UnixMilli(doc.CreatedAt).UTC().Format("January 2, 2006 at 3:04 PM MST")
 
 	// Increment the counter in Redis for the given name
 	counterKey := fmt.Sprintf("counter:%s", input.Name)
 	counter, err := redisClient.Incr(ctx, counterKey).Result()
 	if err != nil {
-		return nil, err
+		return nil, apperrors.FromRedis(err)
 	}
+	middleware.MetricsFrom(ctx).Count("Greetings")
 
 	return &Output{
 		Success: true,
 		Message: fmt.Sprintf("Hello, %s! You have been greeted %d times since %s.", input.Name, counter, createdAt),
 	}, nil
 }
+
+// ensureIndex creates nameIndex unless this instance already did, since the cold-start
+// migration may have failed or been disabled. Creating an existing index is a no-op.
+// Failures are logged rather than returned: greetings still work without the index,
+// they are merely not protected against concurrent first greetings. The build fails for
+// as long as duplicate names remain (see the migrations package), so it is retried at
+// most once per indexRetryInterval.
+func (h *Handler) ensureIndex(ctx context.Context, names db.Repository[nameDocument], now time.Time) {
+	if h.indexed.Load() || now.UnixMilli()-h.indexFailedAt.Load() < indexRetryInterval.Milliseconds() {
+		return
+	}
+
+	if err := names.EnsureIndex(ctx, nameIndex); err != nil {
+		h.indexFailedAt.Store(now.UnixMilli())
+		middleware.LoggerFrom(ctx).WarnContext(ctx, "Failed to ensure the unique name index",
+			slog.String("code", string(apperrors.Classify(err).Code)),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	h.indexed.Store(true)
+}
+
+// findOrCreateName atomically returns the document for name, creating it with the given
+// creation time on the first greeting. Together with the unique index on "name"
+// (see the migrations package), concurrent first greetings resolve to a single document.
+func findOrCreateName(ctx context.Context, names db.Repository[nameDocument], name string, now time.Time) (*nameDocument, error) {
+	filter := bson.M{"name": name}
+	update := bson.M{"$setOnInsert": bson.M{"createdAt": now.UnixMilli()}}
+
+	doc, err := names.FindOneAndUpdate(ctx, filter, update, true)
+	if apperrors.IsConflict(err) {
+		// Another invocation inserted the document between our match and insert; it exists now
+		doc, err = names.FindOneAndUpdate(ctx, filter, update, true)
+	}
+
+	return doc, err
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/db/dbtest"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestFindOrCreateName_FirstGreeting(t *testing.T) {
+	ctx := context.Background()
+	names := db.NewMemoryRepository[nameDocument]()
+	if err := names.EnsureIndex(ctx, nameIndex); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	doc, err := findOrCreateName(ctx, names, "World", now)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if doc.Name != "World" {
+		t.Errorf("Expected name 'World', got '%s'", doc.Name)
+	}
+	if doc.CreatedAt != now.UnixMilli() {
+		t.Errorf("Expected createdAt %d on first greeting, got %d", now.UnixMilli(), doc.CreatedAt)
+	}
+
+	later := now.Add(time.Hour)
+	again, err := findOrCreateName(ctx, names, "World", later)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if again.CreatedAt != now.UnixMilli() {
+		t.Errorf("Expected createdAt to keep the first greeting time, got %d", again.CreatedAt)
+	}
+}
+
+// racingNames is a repository whose first upsert loses a race: another invocation
+// inserts the document first, so the upsert fails with a duplicate-key error the way
+// MongoDB reports it.
+type racingNames struct {
+	db.Repository[nameDocument]
+	winner  time.Time
+	upserts int
+}
+
+func (r *racingNames) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, upsert bool) (*nameDocument, error) {
+	r.upserts++
+	if r.upserts == 1 {
+		if _, err := r.Repository.Insert(ctx, &nameDocument{Name: filter["name"].(string), CreatedAt: r.winner.UnixMilli()}); err != nil {
+			return nil, err
+		}
+		return nil, apperrors.FromMongo(mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}})
+	}
+	return r.Repository.FindOneAndUpdate(ctx, filter, update, upsert)
+}
+
+func TestFindOrCreateName_RetriesAfterDuplicateKey(t *testing.T) {
+	ctx := context.Background()
+	winner := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	names := &racingNames{Repository: db.NewMemoryRepository[nameDocument](), winner: winner}
+
+	doc, err := findOrCreateName(ctx, names, "World", winner.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got '%v'", err)
+	}
+	if names.upserts != 2 {
+		t.Errorf("Expected exactly one retry, got %d upserts", names.upserts)
+	}
+	if doc.Name != "World" || doc.CreatedAt != winner.UnixMilli() {
+		t.Errorf("Expected the document inserted by the other invocation, got %+v", doc)
+	}
+}
+
+func TestFindOrCreateName_ConcurrentFirstGreetings(t *testing.T) {
+	ctx := context.Background()
+	names := db.NewMemoryRepository[nameDocument]()
+	if err := names.EnsureIndex(ctx, nameIndex); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	const numGoroutines = 50
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	docs := make(chan *nameDocument, numGoroutines)
+	errs := make(chan error, numGoroutines)
+
+	wg.Add(numGoroutines)
+	for i := range numGoroutines {
+		go func() {
+			defer wg.Done()
+			doc, err := findOrCreateName(ctx, names, "World", start.Add(time.Duration(i)*time.Second))
+			docs <- doc
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(docs)
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	count, err := names.Count(ctx, bson.M{"name": "World"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected exactly one document, got %d", count)
+	}
+
+	stored, err := names.FindOne(ctx, bson.M{"name": "World"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if stored.CreatedAt < start.UnixMilli() || stored.CreatedAt > start.Add(numGoroutines*time.Second).UnixMilli() {
+		t.Errorf("Expected a creation time from one of the greetings, got %d", stored.CreatedAt)
+	}
+
+	for doc := range docs {
+		if doc.CreatedAt != stored.CreatedAt {
+			t.Errorf("Expected every greeting to see createdAt %d, got %d", stored.CreatedAt, doc.CreatedAt)
+		}
+	}
+}
+
+// failingClients is a db.Clients whose databases are all unreachable.
+type failingClients struct {
+	err error
+}
+
+func (c failingClients) Mongo(context.Context) (*mongo.Client, error)         { return nil, c.err }
+func (c failingClients) Database(context.Context) (*mongo.Database, error)    { return nil, c.err }
+func (c failingClients) Redis(context.Context) (redis.UniversalClient, error) { return nil, c.err }
+
+func TestHandler_DatabaseUnavailable(t *testing.T) {
+	h := &Handler{DB: failingClients{err: errors.New("connection refused")}}
+
+	output, err := h.Handle(context.Background(), Input{Name: "World"})
+	if output != nil {
+		t.Errorf("Expected nil output, got '%v'", output)
+	}
+	if !apperrors.IsUnavailable(err) {
+		t.Errorf("Expected an unavailable error, got '%v'", err)
+	}
+}
+
+// greetedSince formats t like the greeting message.
+func greetedSince(t time.Time) string {
+	return t.UTC().Format("January 2, 2006 at 3:04 PM MST")
+}
+
+func TestHandler_FirstGreeting(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	h := &Handler{DB: fakes.Provider, Now: func() time.Time { return now }}
+
+	output, err := h.Handle(ctx, Input{Name: "World"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := "Hello, World! You have been greeted 1 times since " + greetedSince(now) + "."
+	if !output.Success || output.Message != expected {
+		t.Errorf("Expected message '%s', got '%v'", expected, output)
+	}
+
+	doc, err := db.MemoryCollection[nameDocument](fakes.Mongo, "name").FindOne(ctx, bson.M{"name": "World"})
+	if err != nil {
+		t.Fatalf("Expected the name to be stored, got '%v'", err)
+	}
+	if doc.CreatedAt != now.UnixMilli() {
+		t.Errorf("Expected createdAt %d, got %d", now.UnixMilli(), doc.CreatedAt)
+	}
+	if counter, _ := fakes.Redis.Get("counter:World"); counter != "1" {
+		t.Errorf("Expected counter '1', got '%s'", counter)
+	}
+}
+
+func TestHandler_RepeatGreeting(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+	first := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	now := first
+	h := &Handler{DB: fakes.Provider, Now: func() time.Time { return now }}
+
+	if _, err := h.Handle(ctx, Input{Name: "World"}); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if _, err := h.Handle(ctx, Input{Name: "Gopher"}); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	now = first.Add(48 * time.Hour)
+	output, err := h.Handle(ctx, Input{Name: "World"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := "Hello, World! You have been greeted 2 times since " + greetedSince(first) + "."
+	if output.Message != expected {
+		t.Errorf("Expected message '%s', got '%s'", expected, output.Message)
+	}
+
+	count, err := db.MemoryCollection[nameDocument](fakes.Mongo, "name").Count(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected one document per name, got %d", count)
+	}
+	if counter, _ := fakes.Redis.Get("counter:Gopher"); counter != "1" {
+		t.Errorf("Expected Gopher's counter to stay '1', got '%s'", counter)
+	}
+}
+
+func TestHandler_DatabaseFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		fail func(fakes *dbtest.Fakes)
+	}{
+		{
+			name: "mongodb unreachable",
+			fail: func(fakes *dbtest.Fakes) { fakes.Mongo.SetError(errors.New("connection refused")) },
+		},
+		{
+			name: "redis unreachable",
+			fail: func(fakes *dbtest.Fakes) { fakes.Redis.Close() },
+		},
+		{
+			name: "redis failing",
+			fail: func(fakes *dbtest.Fakes) { fakes.Redis.SetError("LOADING Redis is loading the dataset in memory") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakes := dbtest.New(t)
+			tt.fail(fakes)
+			h := &Handler{DB: fakes.Provider}
+
+			output, err := h.Handle(context.Background(), Input{Name: "World"})
+			if output != nil {
+				t.Errorf("Expected nil output, got '%v'", output)
+			}
+			if !apperrors.IsUnavailable(err) {
+				t.Errorf("Expected an unavailable error, got '%v'", err)
+			}
+		})
+	}
+}
+
+func TestLambdaFunction_DefaultProvider(t *testing.T) {
+	fakes := dbtest.Install(t)
+
+	output, err := LambdaFunction(context.Background(), Input{Name: "World"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if !output.Success {
+		t.Errorf("Expected a successful greeting, got '%v'", output)
+	}
+	if counter, _ := fakes.Redis.Get("counter:World"); counter != "1" {
+		t.Errorf("Expected counter '1', got '%s'", counter)
+	}
+}
+
+func TestHandler_EnsuresNameIndex(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+	h := &Handler{DB: fakes.Provider}
+
+	if _, err := h.Handle(ctx, Input{Name: "World"}); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	// Without the cold-start migration, the handler must have created the unique index
+	names := db.MemoryCollection[nameDocument](fakes.Mongo, "name")
+	if _, err := names.Insert(ctx, &nameDocument{Name: "World"}); !apperrors.IsConflict(err) {
+		t.Errorf("Expected a duplicate name to conflict, got '%v'", err)
+	}
+}
+
+func TestHandler_IndexFailure(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// Duplicates stored before the index existed make creating it fail
+	names := db.MemoryCollection[nameDocument](fakes.Mongo, "name")
+	for range 2 {
+		if _, err := names.Insert(ctx, &nameDocument{Name: "World", CreatedAt: now.UnixMilli()}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	h := &Handler{DB: fakes.Provider, Now: func() time.Time { return now }}
+	for _, name := range []string{"Gopher", "World"} {
+		output, err := h.Handle(ctx, Input{Name: name})
+		if err != nil {
+			t.Fatalf("Expected greetings to succeed without the index, got '%v'", err)
+		}
+		if want := "Hello, " + name + "! You have been greeted 1 times since " + greetedSince(now) + "."; output.Message != want {
+			t.Errorf("Expected message '%s', got '%s'", want, output.Message)
+		}
+	}
+
+	// Once the duplicates are merged, the next attempt after the retry interval builds the index
+	if _, err := names.Delete(ctx, bson.M{"name": "World"}); err != nil {
+		t.Fatalf("Failed to delete duplicates: %v", err)
+	}
+	now = now.Add(indexRetryInterval)
+	if _, err := h.Handle(ctx, Input{Name: "Gopher"}); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if _, err := names.Insert(ctx, &nameDocument{Name: "Gopher"}); !apperrors.IsConflict(err) {
+		t.Errorf("Expected the index to exist after the retry, got '%v'", err)
+	}
+}
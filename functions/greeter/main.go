@@ -1,14 +1,105 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/xarunoba/mlgmr/functions/greeter/migrations"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	"github.com/xarunoba/mlgmr/shared/db/migrate"
 	"github.com/xarunoba/mlgmr/shared/middleware"
+	"github.com/xarunoba/mlgmr/shared/tracing"
 )
 
+// migrationTimeout bounds the cold-start migrations, including connecting to MongoDB,
+// well within the 10s Lambda init phase.
+// A run that times out, e.g. while a crashed runner's lock has not expired yet, is retried
+// on the next cold start; the handler ensures the index it depends on meanwhile.
+const migrationTimeout = 3 * time.Second
+
 func main() {
-	// Wrap the lambdaFn with the Logger middleware
-	wrappedHandler := middleware.Logger(LambdaFunction)
+	// Apply pending migrations on cold start unless disabled
+	if os.Getenv("MIGRATE_ON_COLD_START") != "false" {
+		runMigrations()
+	}
+
+	// Install the tracer provider configured by OTEL_TRACES_EXPORTER
+	shutdownTracing := setupTracing()
+
+	// Start the Lambda with the wrapped handler adapted to API Gateway proxy events.
+	// SIGTERM is only delivered when an extension (such as the ADOT layer) is registered.
+	lambda.StartWithOptions(newHandler(LambdaFunction), lambda.WithEnableSIGTERM(shutdownTracing))
+}
+
+// newHandler wraps handler with the middleware stack and adapts it to API Gateway proxy
+// events. Tests use it to run event fixtures through the same stack as the deployed function.
+func newHandler(handler shared.HandlerFunc[Input, *Output]) shared.HandlerFunc[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse] {
+	// Wrap the handler with the middleware stack (outermost first)
+	wrappedHandler := shared.Compose(handler,
+		middleware.Logger[Input, *Output],
+		middleware.Tracing[Input, *Output](middleware.TracingConfig{}),
+		middleware.Metrics[Input, *Output](middleware.MetricsConfig{}),
+		middleware.Recover[Input, *Output],
+		middleware.RateLimit[Input, *Output](middleware.RateLimitConfig[Input]{
+			Limit:    60,
+			Window:   time.Minute,
+			Key:      middleware.SourceIPKey[Input],
+			FailOpen: true,
+		}),
+		middleware.Validate[Input, *Output],
+		middleware.Deadline[Input, *Output](middleware.DefaultDeadlineMargin),
+		// Repeated greetings are legitimate, so only requests with an Idempotency-Key are
+		// deduplicated. Inside Deadline, the key stays claimed until a handler that overran
+		// its budget really returns.
+		middleware.Idempotency[Input, *Output](middleware.IdempotencyConfig[Input]{
+			Key: middleware.HeaderIdempotencyKey[Input],
+		}),
+	)
+
+	return apigw.Proxy(wrappedHandler)
+}
+
+// setupTracing installs the global tracer provider and returns a function flushing it.
+// Failures are logged rather than fatal, so the function keeps serving untraced requests.
+func setupTracing() func() {
+	ctx := context.Background()
+
+	shutdown, err := tracing.Setup(ctx)
+	if err != nil {
+		middleware.GetLogger().ErrorContext(ctx, "Failed to set up tracing", slog.Any("error", err))
+		return func() {}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		if err := shutdown(ctx); err != nil {
+			middleware.GetLogger().ErrorContext(ctx, "Failed to shut down tracing", slog.Any("error", err))
+		}
+	}
+}
+
+// runMigrations applies the greeter migrations. Failures are logged rather than fatal,
+// so the function keeps serving requests and retries on the next cold start; meanwhile
+// Handle keeps trying to create the unique index on "name" (see Handler.ensureIndex).
+func runMigrations() {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
 
-	// Start the Lambda with the wrapped handler
-	lambda.Start(wrappedHandler)
+	// The lock is extended while migrations run, so its TTL only bounds how long a
+	// crashed cold start blocks the next one
+	_, err := migrate.Run(ctx,
+		migrate.WithScope(migrations.Scope),
+		migrate.WithLockTTL(migrationTimeout),
+		migrate.WithLogger(middleware.GetLogger()),
+	)
+	if err != nil {
+		middleware.GetLogger().ErrorContext(ctx, "Failed to apply migrations", slog.Any("error", err))
+	}
 }
@@ -0,0 +1,82 @@
+// Package migrations registers the database migrations of the greeter function.
+// It is imported by the function itself (applied on cold start) and by cmd/migrate.
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/db/migrate"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Scope is the migration scope of the greeter function.
+const Scope = "greeter"
+
+func init() {
+	migrate.Register(
+		migrate.Migration{
+			Scope:       Scope,
+			Version:     1,
+			Description: "merge duplicate names and create a unique index on name.name",
+			Up: migrate.Steps(
+				mergeDuplicateNames,
+				migrate.CreateIndex("name", db.Index{
+					Keys:   bson.D{{Key: "name", Value: 1}},
+					Unique: true,
+				}),
+			),
+		},
+	)
+}
+
+// mergeDuplicateNames keeps one document per name, so the unique index can be built on
+// collections filled by the earlier find-then-insert greeter, which raced and stored some
+// names twice. The oldest document is kept with the earliest recorded creation time
+// (that code also stored zero times). Greeting counters live in Redis under the name,
+// so they need no merging. Re-running the step finds no duplicates and does nothing.
+func mergeDuplicateNames(ctx context.Context, database *mongo.Database) error {
+	names := database.Collection("name")
+
+	cursor, err := names.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$name"},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			// $min ignores the removed zero times
+			{Key: "createdAt", Value: bson.D{{Key: "$min", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gt", Value: bson.A{"$createdAt", 0}}}, "$createdAt", "$$REMOVE",
+			}}}}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "ids.1", Value: bson.D{{Key: "$exists", Value: true}}}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate names: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			Name      string `bson:"_id"`
+			IDs       []any  `bson:"ids"`
+			CreatedAt *int64 `bson:"createdAt"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return fmt.Errorf("failed to decode duplicate names: %w", err)
+		}
+
+		if group.CreatedAt != nil {
+			_, err := names.UpdateOne(ctx, bson.M{"_id": group.IDs[0]}, bson.M{"$set": bson.M{"createdAt": *group.CreatedAt}})
+			if err != nil {
+				return fmt.Errorf("failed to merge name %q: %w", group.Name, err)
+			}
+		}
+		if _, err := names.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}}); err != nil {
+			return fmt.Errorf("failed to remove duplicates of name %q: %w", group.Name, err)
+		}
+	}
+
+	return cursor.Err()
+}
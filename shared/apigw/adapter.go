@@ -0,0 +1,151 @@
+package apigw
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/xarunoba/mlgmr/shared"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// StatusCoder can be implemented by handler outputs to choose the HTTP status code
+// of successful responses. Outputs that do not implement it are returned with 200 OK,
+// or 204 No Content when the output is nil.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// response is the payload-format independent result of an invocation.
+type response struct {
+	status  int
+	headers map[string]string
+	body    string
+}
+
+// Proxy adapts handler to API Gateway REST API (payload format 1.0) proxy events.
+// The request body is JSON-decoded into TIn, then fields tagged with `path` or `query`
+// are filled from the path and query string parameters. The output is encoded as JSON;
+// errors are converted to the apperrors envelope with the matching status code.
+// The normalized request is available to the handler and its middlewares via FromContext.
+func Proxy[TIn, TOut any](handler shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse] {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		req, err := fromProxyRequest(event)
+		return invoke(ctx, handler, req, err).proxy(), nil
+	}
+}
+
+// HTTP adapts handler to API Gateway HTTP API (payload format 2.0) events.
+// It behaves like Proxy; repeated query parameters, which payload format 2.0 joins
+// with commas, are split before binding.
+func HTTP[TIn, TOut any](handler shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[events.APIGatewayV2HTTPRequest, events.APIGatewayV2HTTPResponse] {
+	return func(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		req, err := fromHTTPRequest(event)
+		resp := invoke(ctx, handler, req, err)
+
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode: resp.status,
+			Headers:    resp.headers,
+			Body:       resp.body,
+		}, nil
+	}
+}
+
+// invoke decodes the request into TIn, calls the handler and encodes the result.
+func invoke[TIn, TOut any](ctx context.Context, handler shared.HandlerFunc[TIn, TOut], req *Request, err error) response {
+	if err != nil {
+		return errorResponse(apperrors.Wrap(err, apperrors.CodeValidation, "invalid request body"))
+	}
+
+	var input TIn
+	if len(req.Body) > 0 {
+		if err := json.Unmarshal(req.Body, &input); err != nil {
+			return errorResponse(apperrors.Wrap(err, apperrors.CodeValidation, "invalid JSON body"))
+		}
+	}
+
+	if err := bindParameters(&input, req); err != nil {
+		return errorResponse(apperrors.Wrap(err, apperrors.CodeValidation, err.Error()))
+	}
+
+	output, err := handler(NewContext(ctx, req), input)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return outputResponse(output)
+}
+
+// outputResponse encodes a successful handler output.
+func outputResponse(output any) response {
+	status := http.StatusOK
+	if coder, ok := output.(StatusCoder); ok && !isNil(output) {
+		status = coder.StatusCode()
+	}
+
+	if isNil(output) {
+		return response{status: http.StatusNoContent, headers: map[string]string{}}
+	}
+
+	body, err := json.Marshal(output)
+	if err != nil {
+		return errorResponse(apperrors.Wrap(err, apperrors.CodeInternal, "failed to encode response"))
+	}
+
+	return response{
+		status:  status,
+		headers: map[string]string{"Content-Type": "application/json"},
+		body:    string(body),
+	}
+}
+
+// ErrorProxyResponse converts err to a REST API (payload format 1.0) response carrying
+// the apperrors envelope, exactly as Proxy does for handler errors.
+func ErrorProxyResponse(err error) events.APIGatewayProxyResponse {
+	return errorResponse(err).proxy()
+}
+
+// proxy converts the response to a REST API (payload format 1.0) response.
+func (r response) proxy() events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode: r.status,
+		Headers:    r.headers,
+		Body:       r.body,
+	}
+}
+
+// errorResponse encodes err as an apperrors envelope. It is the only mapping from
+// errors to API responses; ErrorProxyResponse and middleware.ErrorResponse use it too.
+// Errors carrying a retry delay also set the Retry-After header.
+func errorResponse(err error) response {
+	status, body := apperrors.MarshalEnvelope(err)
+
+	headers := map[string]string{"Content-Type": "application/json"}
+	if retryAfter, ok := apperrors.RetryAfter(err); ok {
+		headers["Retry-After"] = strconv.FormatInt(int64(retryAfter.Seconds()), 10)
+	}
+
+	return response{
+		status:  status,
+		headers: headers,
+		body:    string(body),
+	}
+}
+
+// isNil reports whether v is nil or a nil pointer, map, slice or interface.
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
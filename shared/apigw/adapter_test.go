@@ -0,0 +1,286 @@
+package apigw_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+type itemInput struct {
+	ID      int      `json:"id" path:"id"`
+	Name    string   `json:"name"`
+	Verbose bool     `json:"verbose" query:"verbose"`
+	Tags    []string `json:"tags" query:"tag"`
+	Limit   *int     `json:"limit" query:"limit"`
+}
+
+type itemOutput struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Verbose bool     `json:"verbose"`
+	Tags    []string `json:"tags"`
+	Limit   int      `json:"limit"`
+}
+
+type createdOutput struct {
+	ID int `json:"id"`
+}
+
+func (createdOutput) StatusCode() int {
+	return http.StatusCreated
+}
+
+func echoHandler(ctx context.Context, input itemInput) (*itemOutput, error) {
+	output := &itemOutput{ID: input.ID, Name: input.Name, Verbose: input.Verbose, Tags: input.Tags}
+	if input.Limit != nil {
+		output.Limit = *input.Limit
+	}
+	return output, nil
+}
+
+func decodeOutput(t *testing.T, body string) itemOutput {
+	t.Helper()
+
+	var output itemOutput
+	if err := json.Unmarshal([]byte(body), &output); err != nil {
+		t.Fatalf("Expected JSON body, got '%s'", body)
+	}
+	return output
+}
+
+func TestProxy_DecodesBodyAndParameters(t *testing.T) {
+	handler := apigw.Proxy(echoHandler)
+
+	resp, err := handler(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:     http.MethodPost,
+		Body:           `{"id": 1, "name": "widget", "verbose": false}`,
+		PathParameters: map[string]string{"id": "42"},
+		QueryStringParameters: map[string]string{
+			"verbose": "true",
+			"limit":   "10",
+		},
+		MultiValueQueryStringParameters: map[string][]string{
+			"tag": {"a", "b"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected JSON content type, got '%s'", resp.Headers["Content-Type"])
+	}
+
+	output := decodeOutput(t, resp.Body)
+	expected := itemOutput{ID: 42, Name: "widget", Verbose: true, Tags: []string{"a", "b"}, Limit: 10}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, output)
+	}
+}
+
+func TestProxy_Base64Body(t *testing.T) {
+	handler := apigw.Proxy(echoHandler)
+
+	resp, _ := handler(context.Background(), events.APIGatewayProxyRequest{
+		Body:            base64.StdEncoding.EncodeToString([]byte(`{"name": "encoded"}`)),
+		IsBase64Encoded: true,
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if output := decodeOutput(t, resp.Body); output.Name != "encoded" {
+		t.Errorf("Expected name 'encoded', got '%s'", output.Name)
+	}
+}
+
+func TestProxy_RequestErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		event events.APIGatewayProxyRequest
+	}{
+		{"invalid JSON", events.APIGatewayProxyRequest{Body: `{"name":`}},
+		{"invalid base64", events.APIGatewayProxyRequest{Body: "%%%", IsBase64Encoded: true}},
+		{"invalid path parameter", events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "abc"}}},
+		{"invalid query parameter", events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"verbose": "maybe"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := apigw.Proxy(func(ctx context.Context, input itemInput) (*itemOutput, error) {
+				called = true
+				return nil, nil
+			})
+
+			resp, err := handler(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("Expected error to be returned as a response, got '%v'", err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", resp.StatusCode)
+			}
+			if called {
+				t.Error("Expected handler not to be called")
+			}
+		})
+	}
+}
+
+func TestProxy_HandlerError(t *testing.T) {
+	handler := apigw.Proxy(func(ctx context.Context, input itemInput) (*itemOutput, error) {
+		return nil, apperrors.NotFound("item not found")
+	})
+
+	resp, _ := handler(context.Background(), events.APIGatewayProxyRequest{})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resp.StatusCode)
+	}
+
+	var envelope apperrors.Envelope
+	if err := json.Unmarshal([]byte(resp.Body), &envelope); err != nil {
+		t.Fatalf("Expected JSON envelope, got '%s'", resp.Body)
+	}
+	if envelope.Error.Code != apperrors.CodeNotFound || envelope.Error.Message != "item not found" {
+		t.Errorf("Unexpected envelope %+v", envelope)
+	}
+}
+
+func TestErrorProxyResponse_MatchesProxy(t *testing.T) {
+	err := apperrors.TooManyRequests("slow down", 3*time.Second)
+	handler := apigw.Proxy(func(ctx context.Context, input itemInput) (*itemOutput, error) {
+		return nil, err
+	})
+
+	want, _ := handler(context.Background(), events.APIGatewayProxyRequest{})
+	got := apigw.ErrorProxyResponse(err)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got.Headers["Retry-After"] != "3" {
+		t.Errorf("Expected Retry-After '3', got '%s'", got.Headers["Retry-After"])
+	}
+}
+
+func TestProxy_StatusCodes(t *testing.T) {
+	created := apigw.Proxy(func(ctx context.Context, input itemInput) (createdOutput, error) {
+		return createdOutput{ID: 7}, nil
+	})
+	if resp, _ := created(context.Background(), events.APIGatewayProxyRequest{}); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", resp.StatusCode)
+	}
+
+	empty := apigw.Proxy(func(ctx context.Context, input itemInput) (*itemOutput, error) {
+		return nil, nil
+	})
+	resp, _ := empty(context.Background(), events.APIGatewayProxyRequest{})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", resp.StatusCode)
+	}
+	if resp.Body != "" {
+		t.Errorf("Expected empty body, got '%s'", resp.Body)
+	}
+}
+
+func TestProxy_RequestInContext(t *testing.T) {
+	handler := apigw.Proxy(func(ctx context.Context, input itemInput) (*itemOutput, error) {
+		req, ok := apigw.FromContext(ctx)
+		if !ok {
+			t.Fatal("Expected request in context")
+		}
+		if req.Header("X-Api-Key") != "secret" {
+			t.Errorf("Expected header lookup to be case-insensitive, got '%s'", req.Header("X-Api-Key"))
+		}
+		if req.SourceIP != "203.0.113.1" || req.RequestID != "req-1" || req.Method != http.MethodPost {
+			t.Errorf("Unexpected request %+v", req)
+		}
+		return &itemOutput{}, nil
+	})
+
+	event := events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Headers:    map[string]string{"x-api-key": "secret"},
+	}
+	event.RequestContext.RequestID = "req-1"
+	event.RequestContext.Identity.SourceIP = "203.0.113.1"
+
+	if resp, _ := handler(context.Background(), event); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTP_DecodesBodyAndParameters(t *testing.T) {
+	handler := apigw.HTTP(echoHandler)
+
+	event := events.APIGatewayV2HTTPRequest{
+		RawPath:               "/items/5",
+		Body:                  `{"name": "gadget"}`,
+		PathParameters:        map[string]string{"id": "5"},
+		QueryStringParameters: map[string]string{"tag": "x,y", "verbose": "1"},
+		Headers:               map[string]string{"content-type": "application/json"},
+	}
+	event.RequestContext.HTTP.Method = http.MethodGet
+	event.RequestContext.HTTP.SourceIP = "198.51.100.7"
+
+	resp, err := handler(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	output := decodeOutput(t, resp.Body)
+	expected := itemOutput{ID: 5, Name: "gadget", Verbose: true, Tags: []string{"x", "y"}}
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, output)
+	}
+}
+
+func TestHTTP_CommaInScalarQueryParameter(t *testing.T) {
+	type searchInput struct {
+		Query string   `query:"q"`
+		Tags  []string `query:"tag"`
+	}
+
+	var got searchInput
+	handler := apigw.HTTP(func(ctx context.Context, input searchInput) (*itemOutput, error) {
+		got = input
+		return &itemOutput{}, nil
+	})
+
+	event := events.APIGatewayV2HTTPRequest{
+		QueryStringParameters: map[string]string{"q": "hello,world", "tag": "x,y"},
+	}
+	event.RequestContext.HTTP.Method = http.MethodGet
+
+	if resp, _ := handler(context.Background(), event); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if got.Query != "hello,world" {
+		t.Errorf("Expected the scalar field to keep the whole value, got '%s'", got.Query)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"x", "y"}) {
+		t.Errorf("Expected the slice field to be split, got %v", got.Tags)
+	}
+}
+
+func TestHTTP_HandlerError(t *testing.T) {
+	handler := apigw.HTTP(func(ctx context.Context, input itemInput) (*itemOutput, error) {
+		return nil, apperrors.Conflict("duplicate")
+	})
+
+	resp, _ := handler(context.Background(), events.APIGatewayV2HTTPRequest{})
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,126 @@
+package apigw
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// bindParameters copies path and query parameters into the struct fields of input
+// tagged with `path:"name"` or `query:"name"`. Path parameters take precedence over
+// query parameters, and both take precedence over values decoded from the body.
+// Comma-joined HTTP API query values are split for slice fields only, so scalar
+// fields receive them unchanged.
+func bindParameters(input any, req *Request) error {
+	v := reflect.ValueOf(input)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil
+	}
+
+	v = v.Elem()
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if name := field.Tag.Get("query"); name != "" {
+			if values, ok := req.Query[name]; ok && len(values) > 0 {
+				if req.commaJoinedQuery && isSlice(field.Type) {
+					values = strings.Split(values[0], ",")
+				}
+				if err := setField(v.Field(i), values); err != nil {
+					return fmt.Errorf("invalid query parameter %q: %w", name, err)
+				}
+			}
+		}
+
+		if name := field.Tag.Get("path"); name != "" {
+			if value, ok := req.PathParameters[name]; ok {
+				if err := setField(v.Field(i), []string{value}); err != nil {
+					return fmt.Errorf("invalid path parameter %q: %w", name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSlice reports whether t is a slice or a pointer to one.
+func isSlice(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice
+}
+
+// setField converts values into the type of field and assigns it.
+// Slices receive every value; other kinds receive the first one.
+func setField(field reflect.Value, values []string) error {
+	switch field.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setField(slice.Index(i), []string{value}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	value := values[0]
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package apigw adapts typed shared.HandlerFunc handlers to API Gateway proxy events.
+// It supports both the REST API (payload format 1.0) and HTTP API (payload format 2.0)
+// integrations, decoding the JSON body and path/query parameters into the handler input
+// and encoding the handler output or error as an HTTP response.
+package apigw
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Request is a payload-format independent view of an API Gateway request.
+// Header names are lower-cased. Query holds the values as API Gateway delivered them:
+// HTTP API (payload format 2.0) requests join repeated parameters with commas into a
+// single value, which is only split when bound to a slice field.
+type Request struct {
+	Method         string
+	Path           string
+	Headers        map[string]string
+	PathParameters map[string]string
+	Query          map[string][]string
+	SourceIP       string
+	RequestID      string
+	Body           []byte
+
+	// commaJoinedQuery reports that repeated query parameters are joined with commas
+	commaJoinedQuery bool
+}
+
+// Header returns the value of the named header, matched case-insensitively.
+func (r *Request) Header(name string) string {
+	return r.Headers[strings.ToLower(name)]
+}
+
+type requestContextKey struct{}
+
+// NewContext returns a copy of ctx carrying the request.
+func NewContext(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, req)
+}
+
+// FromContext returns the API Gateway request stored in ctx by the adapters, if any.
+// Middlewares use it to read headers or the caller's source IP.
+func FromContext(ctx context.Context) (*Request, bool) {
+	req, ok := ctx.Value(requestContextKey{}).(*Request)
+	return req, ok
+}
+
+// fromProxyRequest normalizes a REST API (v1) request.
+func fromProxyRequest(event events.APIGatewayProxyRequest) (*Request, error) {
+	body, err := decodeBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	query := make(map[string][]string, len(event.QueryStringParameters))
+	for key, value := range event.QueryStringParameters {
+		query[key] = []string{value}
+	}
+	for key, values := range event.MultiValueQueryStringParameters {
+		query[key] = values
+	}
+
+	return &Request{
+		Method:         event.HTTPMethod,
+		Path:           event.Path,
+		Headers:        lowerKeys(event.Headers),
+		PathParameters: event.PathParameters,
+		Query:          query,
+		SourceIP:       event.RequestContext.Identity.SourceIP,
+		RequestID:      event.RequestContext.RequestID,
+		Body:           body,
+	}, nil
+}
+
+// fromHTTPRequest normalizes an HTTP API (v2) request.
+func fromHTTPRequest(event events.APIGatewayV2HTTPRequest) (*Request, error) {
+	body, err := decodeBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	// Payload format 2.0 joins repeated query parameters with commas; a comma may also be
+	// part of a single value, so splitting is left to slice fields (see bindParameters)
+	query := make(map[string][]string, len(event.QueryStringParameters))
+	for key, value := range event.QueryStringParameters {
+		query[key] = []string{value}
+	}
+
+	return &Request{
+		Method:         event.RequestContext.HTTP.Method,
+		Path:           event.RawPath,
+		Headers:        lowerKeys(event.Headers),
+		PathParameters: event.PathParameters,
+		Query:          query,
+		SourceIP:       event.RequestContext.HTTP.SourceIP,
+		RequestID:      event.RequestContext.RequestID,
+		Body:           body,
+
+		commaJoinedQuery: true,
+	}, nil
+}
+
+// decodeBody returns the raw request body, decoding it if API Gateway base64-encoded it.
+func decodeBody(body string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		return []byte(body), nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 body: %w", err)
+	}
+	return decoded, nil
+}
+
+// lowerKeys returns a copy of headers with lower-cased keys.
+func lowerKeys(headers map[string]string) map[string]string {
+	lowered := make(map[string]string, len(headers))
+	for key, value := range headers {
+		lowered[strings.ToLower(key)] = value
+	}
+	return lowered
+}
@@ -0,0 +1,22 @@
+package shared
+
+// Chain combines multiple middlewares into a single MiddlewareFunc.
+// Middlewares are applied in the order they are given: the first middleware is the
+// outermost layer and sees the input first and the output last.
+// Calling Chain with no middlewares returns a middleware that leaves the handler unchanged.
+func Chain[TIn, TOut any](middlewares ...MiddlewareFunc[TIn, TOut]) MiddlewareFunc[TIn, TOut] {
+	return func(next HandlerFunc[TIn, TOut]) HandlerFunc[TIn, TOut] {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			if middlewares[i] != nil {
+				next = middlewares[i](next)
+			}
+		}
+		return next
+	}
+}
+
+// Compose wraps the handler with the given middlewares and returns the resulting HandlerFunc.
+// It is equivalent to Chain(middlewares...)(handler), so the first middleware is the outermost layer.
+func Compose[TIn, TOut any](handler HandlerFunc[TIn, TOut], middlewares ...MiddlewareFunc[TIn, TOut]) HandlerFunc[TIn, TOut] {
+	return Chain(middlewares...)(handler)
+}
@@ -0,0 +1,175 @@
+package shared_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xarunoba/mlgmr/shared"
+)
+
+// recorder returns a middleware that appends "<name>:before" and "<name>:after" to the trace.
+func recorder(name string, trace *[]string) shared.MiddlewareFunc[string, string] {
+	return func(next shared.HandlerFunc[string, string]) shared.HandlerFunc[string, string] {
+		return func(ctx context.Context, input string) (string, error) {
+			*trace = append(*trace, name+":before")
+			output, err := next(ctx, input)
+			*trace = append(*trace, name+":after")
+			return output, err
+		}
+	}
+}
+
+func TestCompose_Ordering(t *testing.T) {
+	var trace []string
+
+	handler := func(ctx context.Context, input string) (string, error) {
+		trace = append(trace, "handler")
+		return input, nil
+	}
+
+	composed := shared.Compose(handler,
+		recorder("first", &trace),
+		recorder("second", &trace),
+		recorder("third", &trace),
+	)
+
+	output, err := composed(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if output != "hello" {
+		t.Errorf("Expected output 'hello', got '%s'", output)
+	}
+
+	expected := []string{
+		"first:before",
+		"second:before",
+		"third:before",
+		"handler",
+		"third:after",
+		"second:after",
+		"first:after",
+	}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected trace %v, got %v", expected, trace)
+	}
+}
+
+func TestCompose_NoMiddlewares(t *testing.T) {
+	handler := func(ctx context.Context, input string) (string, error) {
+		return input + "!", nil
+	}
+
+	output, err := shared.Compose(handler)(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if output != "hello!" {
+		t.Errorf("Expected output 'hello!', got '%s'", output)
+	}
+}
+
+func TestCompose_NilMiddlewareIsSkipped(t *testing.T) {
+	var trace []string
+
+	handler := func(ctx context.Context, input string) (string, error) {
+		trace = append(trace, "handler")
+		return input, nil
+	}
+
+	composed := shared.Compose(handler, recorder("first", &trace), nil, recorder("second", &trace))
+	if _, err := composed(context.Background(), "hello"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := []string{"first:before", "second:before", "handler", "second:after", "first:after"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected trace %v, got %v", expected, trace)
+	}
+}
+
+func TestCompose_ShortCircuit(t *testing.T) {
+	var trace []string
+	errDenied := errors.New("denied")
+
+	deny := func(next shared.HandlerFunc[string, string]) shared.HandlerFunc[string, string] {
+		return func(ctx context.Context, input string) (string, error) {
+			trace = append(trace, "deny")
+			return "", errDenied
+		}
+	}
+
+	handler := func(ctx context.Context, input string) (string, error) {
+		trace = append(trace, "handler")
+		return input, nil
+	}
+
+	composed := shared.Compose(handler, recorder("outer", &trace), deny, recorder("inner", &trace))
+
+	_, err := composed(context.Background(), "hello")
+	if !errors.Is(err, errDenied) {
+		t.Errorf("Expected error '%v', got '%v'", errDenied, err)
+	}
+
+	expected := []string{"outer:before", "deny", "outer:after"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected trace %v, got %v", expected, trace)
+	}
+}
+
+func TestCompose_ErrorPropagation(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	var seen []error
+
+	observe := func(next shared.HandlerFunc[string, string]) shared.HandlerFunc[string, string] {
+		return func(ctx context.Context, input string) (string, error) {
+			output, err := next(ctx, input)
+			seen = append(seen, err)
+			return output, err
+		}
+	}
+
+	handler := func(ctx context.Context, input string) (string, error) {
+		return "", errHandler
+	}
+
+	_, err := shared.Compose(handler, observe, observe)(context.Background(), "hello")
+	if !errors.Is(err, errHandler) {
+		t.Errorf("Expected error '%v', got '%v'", errHandler, err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("Expected both middlewares to observe the error, got %d observations", len(seen))
+	}
+	for i, e := range seen {
+		if !errors.Is(e, errHandler) {
+			t.Errorf("Expected middleware %d to observe '%v', got '%v'", i, errHandler, e)
+		}
+	}
+}
+
+func TestChain_Reusable(t *testing.T) {
+	var trace []string
+	stack := shared.Chain(recorder("a", &trace), recorder("b", &trace))
+
+	upper := stack(func(ctx context.Context, input string) (string, error) {
+		return input + "-upper", nil
+	})
+	lower := stack(func(ctx context.Context, input string) (string, error) {
+		return input + "-lower", nil
+	})
+
+	if out, _ := upper(context.Background(), "x"); out != "x-upper" {
+		t.Errorf("Expected output 'x-upper', got '%s'", out)
+	}
+	if out, _ := lower(context.Background(), "x"); out != "x-lower" {
+		t.Errorf("Expected output 'x-lower', got '%s'", out)
+	}
+
+	expected := []string{"a:before", "b:before", "b:after", "a:after", "a:before", "b:before", "b:after", "a:after"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Expected trace %v, got %v", expected, trace)
+	}
+}
@@ -352,7 +352,7 @@ func TestGetRedisClient_ConcurrentAccess(t *testing.T) {
 	const numGoroutines = 10
 	var wg sync.WaitGroup
 	errors := make(chan error, numGoroutines)
-	clients := make(chan *redis.Client, numGoroutines)
+	clients := make(chan redis.UniversalClient, numGoroutines)
 
 	wg.Add(numGoroutines)
 	for range numGoroutines {
@@ -0,0 +1,62 @@
+// Package dbtest provides in-process stand-ins for MongoDB and Redis so handlers can be
+// unit-tested without running databases.
+//
+// Redis is served by miniredis, which speaks the Redis protocol and supports strings
+// (GET, SET, INCR, ...), expiry (EXPIRE, TTL; advance time with FastForward), hashes,
+// lists, sets, sorted sets, transactions and Lua scripts (EVAL, EVALSHA). MongoDB is
+// replaced by a db.MemoryDatabase, so db.Collection and db.CollectionFrom return
+// db.MemoryRepositories; code that needs a *mongo.Client gets db.ErrMemoryDatabase.
+//
+//	fakes := dbtest.New(t)
+//	h := &Handler{DB: fakes.Provider}
+//
+// Install additionally makes the fakes the default provider for the rest of the test,
+// which is what GetMongoClient, GetRedisClient and every component without explicit
+// clients use.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+)
+
+// Fakes holds the in-process databases of a test.
+type Fakes struct {
+	// Provider hands out the fakes. Inject it wherever a db.Clients is expected.
+	Provider *db.Provider
+	// Redis is the Redis server, e.g. to inspect keys, FastForward expiry or
+	// simulate failures with SetError.
+	Redis *miniredis.Miniredis
+	// Mongo holds the collections. Simulate failures with SetError.
+	Mongo *db.MemoryDatabase
+}
+
+// New starts empty fakes and stops them when the test ends.
+func New(t testing.TB) *Fakes {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	mongo := db.NewMemoryDatabase()
+
+	return &Fakes{
+		Provider: db.NewProvider(db.WithRedisClient(client), db.WithMemoryDatabase(mongo)),
+		Redis:    server,
+		Mongo:    mongo,
+	}
+}
+
+// Install starts empty fakes like New and makes them the default provider (see
+// db.SwapDefaultProvider) until the test ends. Tests using it must not run in parallel.
+func Install(t testing.TB) *Fakes {
+	t.Helper()
+
+	fakes := New(t)
+	t.Cleanup(db.SwapDefaultProvider(fakes.Provider))
+	return fakes
+}
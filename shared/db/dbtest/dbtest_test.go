@@ -0,0 +1,141 @@
+package dbtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/db/dbtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type user struct {
+	Name   string `bson:"name"`
+	Visits int64  `bson:"visits"`
+}
+
+func TestFakes_Redis(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+
+	client, err := fakes.Provider.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	if err := client.Set(ctx, "greeting", "hello", 0).Err(); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if got, _ := client.Get(ctx, "greeting").Result(); got != "hello" {
+		t.Errorf("Expected 'hello', got '%s'", got)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if n, err := client.Incr(ctx, "counter").Result(); err != nil || n != i {
+			t.Fatalf("Expected INCR to return %d, got %d (error %v)", i, n, err)
+		}
+	}
+
+	if err := client.Expire(ctx, "counter", time.Minute).Err(); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	fakes.Redis.FastForward(time.Minute)
+	if _, err := client.Get(ctx, "counter").Result(); !errors.Is(err, redis.Nil) {
+		t.Errorf("Expected the counter to expire, got '%v'", err)
+	}
+
+	script := redis.NewScript(`return redis.call("INCRBY", KEYS[1], ARGV[1])`)
+	if n, err := script.Run(ctx, client, []string{"scripted"}, 5).Int64(); err != nil || n != 5 {
+		t.Errorf("Expected the script to return 5, got %d (error %v)", n, err)
+	}
+}
+
+func TestFakes_Mongo(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+
+	users, err := db.CollectionFrom[user](ctx, fakes.Provider, "users")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if _, err := users.Insert(ctx, &user{Name: "Ada"}); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	// Repositories opened later see the same collection, whatever their document type
+	again, err := db.CollectionFrom[bson.M](ctx, fakes.Provider, "users")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if count, _ := again.Count(ctx, bson.M{"name": "Ada"}); count != 1 {
+		t.Errorf("Expected the inserted document, got %d documents", count)
+	}
+	if count, _ := db.MemoryCollection[user](fakes.Mongo, "other").Count(ctx, bson.M{}); count != 0 {
+		t.Errorf("Expected other collections to be empty, got %d documents", count)
+	}
+
+	if _, err := fakes.Provider.Mongo(ctx); !errors.Is(err, db.ErrMemoryDatabase) {
+		t.Errorf("Expected ErrMemoryDatabase from Mongo, got '%v'", err)
+	}
+}
+
+func TestFakes_Errors(t *testing.T) {
+	fakes := dbtest.New(t)
+	ctx := context.Background()
+
+	fakes.Mongo.SetError(errors.New("connection refused"))
+	if _, err := db.CollectionFrom[user](ctx, fakes.Provider, "users"); err == nil || err.Error() != "connection refused" {
+		t.Errorf("Expected 'connection refused', got '%v'", err)
+	}
+
+	fakes.Mongo.SetError(nil)
+	if _, err := db.CollectionFrom[user](ctx, fakes.Provider, "users"); err != nil {
+		t.Errorf("Expected no error after clearing it, got '%v'", err)
+	}
+
+	fakes.Redis.SetError("LOADING Redis is loading the dataset in memory")
+	if _, err := fakes.Provider.Redis(ctx); err == nil {
+		t.Error("Expected an error while Redis fails")
+	}
+}
+
+func TestInstall(t *testing.T) {
+	previous := db.DefaultProvider()
+
+	t.Run("installed", func(t *testing.T) {
+		fakes := dbtest.Install(t)
+		if db.DefaultProvider() != fakes.Provider {
+			t.Fatal("Expected the fakes to be the default provider")
+		}
+
+		client, err := db.GetRedisClient()
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if err := client.Set(context.Background(), "key", "value", 0).Err(); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if got, _ := fakes.Redis.Get("key"); got != "value" {
+			t.Errorf("Expected 'value', got '%s'", got)
+		}
+
+		users, err := db.Collection[user]("users")
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		if _, ok := users.(*db.MemoryRepository[user]); !ok {
+			t.Errorf("Expected an in-memory repository, got %T", users)
+		}
+
+		if _, err := db.GetMongoClient(); !errors.Is(err, db.ErrMemoryDatabase) {
+			t.Errorf("Expected ErrMemoryDatabase from GetMongoClient, got '%v'", err)
+		}
+	})
+
+	if db.DefaultProvider() != previous {
+		t.Error("Expected the default provider to be restored after the test")
+	}
+}
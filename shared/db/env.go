@@ -0,0 +1,33 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// parseEnv parses the environment variable name into dst if it is set,
+// appending a descriptive error to errs if parsing fails.
+func parseEnv[T any](errs *[]error, name string, dst *T, parse func(string) (T, error)) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("invalid %s %q: %w", name, value, err))
+		return
+	}
+	*dst = parsed
+}
+
+// parseUint parses a base-10 unsigned integer.
+func parseUint(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// parseInt parses a base-10 integer.
+func parseInt(s string) (int, error) {
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+// ClientStats counts the health checks and connections of one client.
+type ClientStats struct {
+	// Pings is the number of health check pings sent.
+	Pings uint64
+	// PingFailures is the number of health check pings that failed.
+	PingFailures uint64
+	// Connects is the number of clients created, including reconnects.
+	Connects uint64
+	// Reconnects is the number of clients created to replace an unhealthy one.
+	Reconnects uint64
+}
+
+// ProviderStats reports the ClientStats of a Provider's clients.
+type ProviderStats struct {
+	Mongo ClientStats
+	Redis ClientStats
+}
+
+// health tracks whether a client needs a health check. Pings are skipped while the
+// last successful check is younger than the configured interval, unless a driver
+// error marked the client unhealthy in the meantime.
+type health struct {
+	checkedAt time.Time
+	unhealthy atomic.Bool
+
+	pings        atomic.Uint64
+	pingFailures atomic.Uint64
+	connects     atomic.Uint64
+	reconnects   atomic.Uint64
+}
+
+// due reports whether the client must be pinged before it is handed out.
+func (h *health) due(interval time.Duration) bool {
+	return h.unhealthy.Load() || interval <= 0 || time.Since(h.checkedAt) >= interval
+}
+
+// ping runs a health check and records its outcome.
+func (h *health) ping(fn func() error) error {
+	h.pings.Add(1)
+	if err := fn(); err != nil {
+		h.pingFailures.Add(1)
+		return err
+	}
+
+	h.checkedAt = time.Now()
+	h.unhealthy.Store(false)
+	return nil
+}
+
+// markUnhealthy forces a health check on the next use of the client.
+func (h *health) markUnhealthy() {
+	h.unhealthy.Store(true)
+}
+
+// connected records a newly created client. Every client after the first replaces an unhealthy one.
+func (h *health) connected() {
+	if h.connects.Add(1) > 1 {
+		h.reconnects.Add(1)
+	}
+}
+
+// stats returns a snapshot of the counters.
+func (h *health) stats() ClientStats {
+	return ClientStats{
+		Pings:        h.pings.Load(),
+		PingFailures: h.pingFailures.Load(),
+		Connects:     h.connects.Load(),
+		Reconnects:   h.reconnects.Load(),
+	}
+}
+
+// Stats returns the health check and connection counters of the provider's clients.
+func (p *Provider) Stats() ProviderStats {
+	return ProviderStats{
+		Mongo: p.mongoHealth.stats(),
+		Redis: p.redisHealth.stats(),
+	}
+}
+
+// MarkMongoUnhealthy forces a health check on the next call to Mongo.
+// Clients created by the provider are marked automatically when the driver clears
+// its connection pool after a network error.
+func (p *Provider) MarkMongoUnhealthy() {
+	p.mongoHealth.markUnhealthy()
+}
+
+// MarkRedisUnhealthy forces a health check on the next call to Redis.
+// Clients created by the provider are marked automatically when a command fails
+// with a connection error.
+func (p *Provider) MarkRedisUnhealthy() {
+	p.redisHealth.markUnhealthy()
+}
+
+// mongoPoolMonitor marks the client unhealthy when the driver clears a connection pool,
+// which it does after network errors and server state changes.
+func (h *health) mongoPoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			if e.Type == event.ConnectionPoolCleared {
+				h.markUnhealthy()
+			}
+		},
+	}
+}
+
+// redisHealthHook marks the client unhealthy when a command fails with a connection error.
+type redisHealthHook struct {
+	health *health
+}
+
+// Compile-time check to ensure redisHealthHook implements redis.Hook
+var _ redis.Hook = redisHealthHook{}
+
+func (h redisHealthHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		h.observe(ctx, err)
+		return conn, err
+	}
+}
+
+func (h redisHealthHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.observe(ctx, err)
+		return err
+	}
+}
+
+func (h redisHealthHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		h.observe(ctx, err)
+		return err
+	}
+}
+
+// observe marks the client unhealthy for connection errors. Command errors (including
+// redis.Nil) and errors caused by the caller's context say nothing about the connection.
+func (h redisHealthHook) observe(ctx context.Context, err error) {
+	if err == nil || ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	if errors.Is(err, io.EOF) || apperrors.IsUnavailable(apperrors.FromRedis(err)) {
+		h.health.markUnhealthy()
+	}
+}
@@ -0,0 +1,765 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Compile-time check to ensure MemoryRepository implements Repository
+var _ Repository[bson.M] = (*MemoryRepository[bson.M])(nil)
+
+// MemoryRepository is an in-memory Repository intended for unit tests.
+// Documents are stored as BSON round-trips of T, so bson struct tags apply just like
+// with MongoDB. Filters support equality on (dotted) field paths, the comparison
+// operators $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin and $exists, and the logical
+// operators $and and $or. Updates support $set, $unset, $inc and $setOnInsert.
+// Unique indexes created with EnsureIndex are enforced on every write.
+// It is safe for concurrent use by multiple goroutines, and every operation is atomic.
+type MemoryRepository[T any] struct {
+	*memoryCollection
+}
+
+// memoryCollection holds the documents of an in-memory collection. Repositories of
+// different document types can share it, like MongoRepositories of one collection.
+type memoryCollection struct {
+	mu      sync.Mutex
+	docs    []bson.M
+	indexes []Index
+}
+
+// NewMemoryRepository returns an empty in-memory Repository.
+func NewMemoryRepository[T any]() *MemoryRepository[T] {
+	return &MemoryRepository[T]{memoryCollection: &memoryCollection{}}
+}
+
+// MemoryDatabase is an in-memory stand-in for a MongoDB database: a set of named
+// collections that outlive the repositories opened on them. A Provider configured with
+// WithMemoryDatabase makes Collection and CollectionFrom return MemoryRepositories.
+// It is safe for concurrent use by multiple goroutines.
+type MemoryDatabase struct {
+	mu          sync.Mutex
+	collections map[string]*memoryCollection
+	err         error
+}
+
+// NewMemoryDatabase returns an empty in-memory database.
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{collections: map[string]*memoryCollection{}}
+}
+
+// MemoryCollection returns a Repository for the named collection of database, creating
+// the collection on first use. Repositories of the same name share their documents.
+func MemoryCollection[T any](database *MemoryDatabase, name string) *MemoryRepository[T] {
+	database.mu.Lock()
+	defer database.mu.Unlock()
+
+	collection, ok := database.collections[name]
+	if !ok {
+		collection = &memoryCollection{}
+		database.collections[name] = collection
+	}
+
+	return &MemoryRepository[T]{memoryCollection: collection}
+}
+
+// SetError makes opening collections fail with err, as if MongoDB were unreachable,
+// until it is called again with nil.
+func (d *MemoryDatabase) SetError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+// open returns the error set with SetError.
+func (d *MemoryDatabase) open() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// FindByID returns the document whose _id equals id.
+func (r *MemoryRepository[T]) FindByID(ctx context.Context, id any) (*T, error) {
+	return r.FindOne(ctx, bson.M{"_id": id})
+}
+
+// FindOne returns the first document matching filter.
+func (r *MemoryRepository[T]) FindOne(ctx context.Context, filter bson.M) (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, doc := range r.docs {
+		if matchesFilter(doc, filter) {
+			return decodeDocument[T](doc)
+		}
+	}
+
+	return nil, apperrors.FromMongo(mongo.ErrNoDocuments)
+}
+
+// Insert inserts doc and returns its _id. A new ObjectID is generated if doc has no _id.
+func (r *MemoryRepository[T]) Insert(ctx context.Context, doc *T) (any, error) {
+	m, err := encodeDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.insertLocked(m)
+}
+
+// Upsert replaces the first document matching filter with doc, or inserts doc if none matches.
+func (r *MemoryRepository[T]) Upsert(ctx context.Context, filter bson.M, doc *T) error {
+	m, err := encodeDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.docs {
+		if matchesFilter(existing, filter) {
+			m["_id"] = existing["_id"]
+			if err := r.checkUniqueLocked(m, i); err != nil {
+				return err
+			}
+			r.docs[i] = m
+			return nil
+		}
+	}
+
+	// Like MongoDB, equality conditions of the filter become fields of the new document
+	for key, value := range filter {
+		if _, isOperator := normalizeValue(value).(bson.M); !strings.HasPrefix(key, "$") && !isOperator && !hasPath(m, key) {
+			setPath(m, key, normalizeValue(value))
+		}
+	}
+
+	_, err = r.insertLocked(m)
+	return err
+}
+
+// Update applies the update operators to every document matching filter and returns the number matched.
+func (r *MemoryRepository[T]) Update(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched int64
+	for i, doc := range r.docs {
+		if !matchesFilter(doc, filter) {
+			continue
+		}
+		if err := r.updateLocked(i, update); err != nil {
+			return matched, err
+		}
+		matched++
+	}
+
+	return matched, nil
+}
+
+// FindOneAndUpdate atomically applies the update to the first document matching filter
+// and returns the document after the update, optionally inserting it if none matches.
+func (r *MemoryRepository[T]) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, upsert bool) (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, doc := range r.docs {
+		if matchesFilter(doc, filter) {
+			if err := r.updateLocked(i, update); err != nil {
+				return nil, err
+			}
+			return decodeDocument[T](r.docs[i])
+		}
+	}
+
+	if !upsert {
+		return nil, apperrors.FromMongo(mongo.ErrNoDocuments)
+	}
+
+	doc := bson.M{}
+	for key, value := range filter {
+		if _, isOperator := normalizeValue(value).(bson.M); !strings.HasPrefix(key, "$") && !isOperator {
+			setPath(doc, key, normalizeValue(value))
+		}
+	}
+	if err := applyUpdate(doc, update, true); err != nil {
+		return nil, err
+	}
+	if _, err := r.insertLocked(doc); err != nil {
+		return nil, err
+	}
+
+	return decodeDocument[T](doc)
+}
+
+// Delete removes every document matching filter and returns the number deleted.
+func (r *MemoryRepository[T]) Delete(ctx context.Context, filter bson.M) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := len(r.docs)
+	r.docs = slices.DeleteFunc(r.docs, func(doc bson.M) bool {
+		return matchesFilter(doc, filter)
+	})
+
+	return int64(before - len(r.docs)), nil
+}
+
+// List returns the documents matching filter within the given page.
+func (r *MemoryRepository[T]) List(ctx context.Context, filter bson.M, page Page) ([]T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matches []bson.M
+	for _, doc := range r.docs {
+		if matchesFilter(doc, filter) {
+			matches = append(matches, doc)
+		}
+	}
+
+	if len(page.Sort) > 0 {
+		slices.SortStableFunc(matches, func(a, b bson.M) int {
+			for _, e := range page.Sort {
+				c, _ := compareValues(lookupPath(a, e.Key), lookupPath(b, e.Key))
+				if c != 0 {
+					if direction, _ := toFloat(e.Value); direction < 0 {
+						return -c
+					}
+					return c
+				}
+			}
+			return 0
+		})
+	}
+
+	if page.Skip > 0 {
+		matches = matches[min(int(page.Skip), len(matches)):]
+	}
+	if page.Limit > 0 {
+		matches = matches[:min(int(page.Limit), len(matches))]
+	}
+
+	docs := make([]T, 0, len(matches))
+	for _, m := range matches {
+		doc, err := decodeDocument[T](m)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+
+	return docs, nil
+}
+
+// Count returns the number of documents matching filter.
+func (r *MemoryRepository[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, doc := range r.docs {
+		if matchesFilter(doc, filter) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// EnsureIndex records the index so unique constraints are enforced on later writes.
+// It fails with a conflict if existing documents already violate a unique index.
+func (r *MemoryRepository[T]) EnsureIndex(ctx context.Context, index Index) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.indexes {
+		if reflect.DeepEqual(existing.Keys, index.Keys) {
+			return nil
+		}
+	}
+
+	r.indexes = append(r.indexes, index)
+	for i, doc := range r.docs {
+		if err := r.checkUniqueLocked(doc, i); err != nil {
+			r.indexes = r.indexes[:len(r.indexes)-1]
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertLocked stores m, generating an _id if needed. The caller must hold r.mu.
+func (r *MemoryRepository[T]) insertLocked(m bson.M) (any, error) {
+	if id, ok := m["_id"]; !ok || isZeroValue(id) {
+		m["_id"] = bson.NewObjectID()
+	}
+
+	if err := r.checkUniqueLocked(m, -1); err != nil {
+		return nil, err
+	}
+
+	r.docs = append(r.docs, m)
+	return m["_id"], nil
+}
+
+// updateLocked applies update to the document at index i, leaving it unchanged if the
+// update fails or violates a unique index. The caller must hold r.mu.
+func (r *MemoryRepository[T]) updateLocked(i int, update bson.M) error {
+	updated, err := encodeDocument(r.docs[i])
+	if err != nil {
+		return err
+	}
+	if err := applyUpdate(updated, update, false); err != nil {
+		return err
+	}
+	if err := r.checkUniqueLocked(updated, i); err != nil {
+		return err
+	}
+
+	r.docs[i] = updated
+	return nil
+}
+
+// checkUniqueLocked returns a duplicate key error if m collides with another document on
+// _id or any unique index. The document at index skip is ignored. The caller must hold r.mu.
+func (r *MemoryRepository[T]) checkUniqueLocked(m bson.M, skip int) error {
+	for i, existing := range r.docs {
+		if i == skip {
+			continue
+		}
+
+		if valuesEqual(existing["_id"], m["_id"]) {
+			return apperrors.FromMongo(duplicateKeyError("_id_"))
+		}
+
+		for _, index := range r.indexes {
+			if !index.Unique {
+				continue
+			}
+
+			duplicate := true
+			for _, key := range index.Keys {
+				if !valuesEqual(lookupPath(existing, key.Key), lookupPath(m, key.Key)) {
+					duplicate = false
+					break
+				}
+			}
+			if duplicate {
+				return apperrors.FromMongo(duplicateKeyError(indexName(index)))
+			}
+		}
+	}
+
+	return nil
+}
+
+// indexName returns the default MongoDB name of an index, e.g. "name_1".
+func indexName(index Index) string {
+	parts := make([]string, 0, len(index.Keys))
+	for _, key := range index.Keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// duplicateKeyError builds the error MongoDB returns for unique index violations.
+func duplicateKeyError(index string) error {
+	return mongo.WriteException{
+		WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: fmt.Sprintf("E11000 duplicate key error index: %s", index),
+		}},
+	}
+}
+
+// encodeDocument converts doc into a bson.M with nested documents as bson.M.
+func encodeDocument(doc any) (bson.M, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "failed to encode document")
+	}
+
+	decoder := bson.NewDecoder(bson.NewDocumentReader(bytes.NewReader(raw)))
+	decoder.DefaultDocumentM()
+
+	var m bson.M
+	if err := decoder.Decode(&m); err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "failed to encode document")
+	}
+	return m, nil
+}
+
+// decodeDocument converts a stored document into a new T.
+func decodeDocument[T any](m bson.M) (*T, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "failed to decode document")
+	}
+
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "failed to decode document")
+	}
+	return &doc, nil
+}
+
+// matchesFilter reports whether doc matches the query filter.
+func matchesFilter(doc bson.M, filter bson.M) bool {
+	for key, condition := range filter {
+		switch key {
+		case "$and":
+			for _, sub := range subFilters(condition) {
+				if !matchesFilter(doc, sub) {
+					return false
+				}
+			}
+		case "$or":
+			matched := false
+			for _, sub := range subFilters(condition) {
+				if matchesFilter(doc, sub) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		default:
+			if !matchesCondition(lookupPath(doc, key), hasPath(doc, key), condition) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchesCondition evaluates a single field condition, which is either a value
+// for equality or a document of comparison operators.
+func matchesCondition(value any, exists bool, condition any) bool {
+	operators, ok := normalizeValue(condition).(bson.M)
+	if !ok || !hasOperators(operators) {
+		return valuesEqual(value, condition)
+	}
+
+	for op, arg := range operators {
+		c, comparable := compareValues(value, arg)
+
+		switch op {
+		case "$eq":
+			if !valuesEqual(value, arg) {
+				return false
+			}
+		case "$ne":
+			if valuesEqual(value, arg) {
+				return false
+			}
+		case "$gt":
+			if !exists || !comparable || c <= 0 {
+				return false
+			}
+		case "$gte":
+			if !exists || !comparable || c < 0 {
+				return false
+			}
+		case "$lt":
+			if !exists || !comparable || c >= 0 {
+				return false
+			}
+		case "$lte":
+			if !exists || !comparable || c > 0 {
+				return false
+			}
+		case "$in":
+			if !slices.ContainsFunc(toSlice(arg), func(v any) bool { return valuesEqual(value, v) }) {
+				return false
+			}
+		case "$nin":
+			if slices.ContainsFunc(toSlice(arg), func(v any) bool { return valuesEqual(value, v) }) {
+				return false
+			}
+		case "$exists":
+			if want, _ := arg.(bool); want != exists {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyUpdate applies the update operators to doc. $setOnInsert is only applied when inserting.
+func applyUpdate(doc bson.M, update bson.M, inserting bool) error {
+	for op, arg := range update {
+		fields, ok := normalizeValue(arg).(bson.M)
+		if !ok {
+			return apperrors.Validation(fmt.Sprintf("update operator %s requires a document", op))
+		}
+
+		for path, value := range fields {
+			switch op {
+			case "$set":
+				setPath(doc, path, normalizeValue(value))
+			case "$setOnInsert":
+				if inserting {
+					setPath(doc, path, normalizeValue(value))
+				}
+			case "$unset":
+				unsetPath(doc, path)
+			case "$inc":
+				current, _ := toFloat(lookupPath(doc, path))
+				delta, ok := toFloat(value)
+				if !ok {
+					return apperrors.Validation(fmt.Sprintf("cannot increment %s by a non-numeric value", path))
+				}
+				if isInteger(lookupPath(doc, path)) && isInteger(value) {
+					setPath(doc, path, int64(current+delta))
+				} else {
+					setPath(doc, path, current+delta)
+				}
+			default:
+				return apperrors.Validation(fmt.Sprintf("unsupported update operator %s", op))
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupPath returns the value at a dotted path, or nil if it does not exist.
+func lookupPath(doc bson.M, path string) any {
+	var current any = doc
+	for part := range strings.SplitSeq(path, ".") {
+		m, ok := current.(bson.M)
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// hasPath reports whether a dotted path exists in doc.
+func hasPath(doc bson.M, path string) bool {
+	parts := strings.Split(path, ".")
+	current := doc
+	for i, part := range parts {
+		value, ok := current[part]
+		if !ok {
+			return false
+		}
+		if i == len(parts)-1 {
+			return true
+		}
+		if current, ok = value.(bson.M); !ok {
+			return false
+		}
+	}
+	return false
+}
+
+// setPath sets the value at a dotted path, creating intermediate documents.
+func setPath(doc bson.M, path string, value any) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(bson.M)
+		if !ok {
+			next = bson.M{}
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// unsetPath removes the value at a dotted path.
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(bson.M)
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+}
+
+// hasOperators reports whether every key of m is a query operator.
+func hasOperators(m bson.M) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for key := range m {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// subFilters converts the argument of $and/$or into filters.
+func subFilters(v any) []bson.M {
+	var filters []bson.M
+	for _, item := range toSlice(v) {
+		if m, ok := normalizeValue(item).(bson.M); ok {
+			filters = append(filters, m)
+		}
+	}
+	return filters
+}
+
+// toSlice converts any slice or array into []any.
+func toSlice(v any) []any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items
+}
+
+// valuesEqual compares two values with MongoDB-like semantics:
+// numbers compare by value regardless of type and time.Time equals its bson.DateTime.
+func valuesEqual(a, b any) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(normalizeValue(a), normalizeValue(b))
+}
+
+// compareValues orders two values of the same kind. The boolean is false if the
+// values cannot be ordered against each other.
+func compareValues(a, b any) (int, bool) {
+	a, b = normalizeValue(a), normalizeValue(b)
+
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+		return 0, false
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, true
+			case !av:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case bson.ObjectID:
+		if bv, ok := b.(bson.ObjectID); ok {
+			return bytes.Compare(av[:], bv[:]), true
+		}
+	case bson.DateTime:
+		if bv, ok := b.(bson.DateTime); ok {
+			switch {
+			case av < bv:
+				return -1, true
+			case av > bv:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// normalizeValue converts Go values into the types stored after a BSON round-trip.
+func normalizeValue(v any) any {
+	switch value := v.(type) {
+	case time.Time:
+		return bson.NewDateTimeFromTime(value)
+	case map[string]any:
+		return bson.M(value)
+	default:
+		return v
+	}
+}
+
+// toFloat converts numeric values to float64.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// isInteger reports whether v is nil or an integer, so $inc keeps integer fields integral.
+func isInteger(v any) bool {
+	switch v.(type) {
+	case nil, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isZeroValue reports whether v is nil or the zero value of its type.
+func isZeroValue(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
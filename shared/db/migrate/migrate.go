@@ -0,0 +1,85 @@
+// Package migrate applies versioned MongoDB migrations, such as index creation and
+// data backfills, exactly once per database.
+//
+// Functions register their migrations from an importable package (e.g.
+// functions/greeter/migrations) so they can be applied both on a Lambda cold start
+// and from the standalone cmd/migrate binary. Applied migrations are tracked in the
+// "_migrations" collection, and a lock document in "_migrations_lock" ensures only one
+// runner applies migrations at a time.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Func performs a migration step against the database.
+// Steps must be idempotent, since a runner may crash after applying a step but
+// before recording it.
+type Func func(ctx context.Context, database *mongo.Database) error
+
+// Migration is a single versioned change owned by a scope (usually the function name).
+// Versions are ordered per scope; migrations of different scopes are independent.
+type Migration struct {
+	Scope       string
+	Version     int
+	Description string
+	Up          Func
+}
+
+// ID returns the identifier under which the migration is recorded, e.g. "greeter/0001".
+func (m Migration) ID() string {
+	return fmt.Sprintf("%s/%04d", m.Scope, m.Version)
+}
+
+var (
+	registry   []Migration
+	registryMu sync.Mutex
+)
+
+// Register adds migrations to the global registry used by Run and New.
+// It is meant to be called from init functions and panics if a migration is
+// incomplete or its ID is already registered.
+func Register(migrations ...Migration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, m := range migrations {
+		if m.Scope == "" || m.Version <= 0 || m.Up == nil {
+			panic(fmt.Sprintf("migrate: invalid migration %q: scope, positive version and Up are required", m.ID()))
+		}
+		for _, existing := range registry {
+			if existing.ID() == m.ID() {
+				panic(fmt.Sprintf("migrate: migration %s registered twice", m.ID()))
+			}
+		}
+		registry = append(registry, m)
+	}
+}
+
+// Registered returns the registered migrations sorted by scope and version.
+func Registered() []Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	return sortMigrations(registry)
+}
+
+// sortMigrations returns a sorted copy of migrations.
+func sortMigrations(migrations []Migration) []Migration {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int {
+		if a.Scope != b.Scope {
+			if a.Scope < b.Scope {
+				return -1
+			}
+			return 1
+		}
+		return a.Version - b.Version
+	})
+	return sorted
+}
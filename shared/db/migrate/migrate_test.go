@@ -0,0 +1,311 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/db/migrate"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// recordingStep returns a step that appends id to calls.
+func recordingStep(id string, calls *[]string, mu *sync.Mutex) migrate.Func {
+	return func(ctx context.Context, database *mongo.Database) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*calls = append(*calls, id)
+		return nil
+	}
+}
+
+func newMigrator(records *db.MemoryRepository[migrate.Record], locks *db.MemoryRepository[migrate.Lock], migrations []migrate.Migration, opts ...migrate.Option) *migrate.Migrator {
+	opts = append([]migrate.Option{
+		migrate.WithMigrations(migrations...),
+		migrate.WithRepositories(records, locks),
+		migrate.WithPollInterval(5 * time.Millisecond),
+	}, opts...)
+	return migrate.New(nil, opts...)
+}
+
+func TestMigrator_AppliesPendingInOrder(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 2, Description: "backfill", Up: recordingStep("greeter/2", &calls, &mu)},
+		{Scope: "billing", Version: 1, Description: "index", Up: recordingStep("billing/1", &calls, &mu)},
+		{Scope: "greeter", Version: 1, Description: "index", Up: recordingStep("greeter/1", &calls, &mu)},
+	}
+
+	records := db.NewMemoryRepository[migrate.Record]()
+	locks := db.NewMemoryRepository[migrate.Lock]()
+	migrator := newMigrator(records, locks, migrations)
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(applied) != 3 {
+		t.Errorf("Expected 3 applied migrations, got %d", len(applied))
+	}
+
+	expected := []string{"billing/1", "greeter/1", "greeter/2"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+
+	record, err := records.FindByID(context.Background(), "greeter/0002")
+	if err != nil {
+		t.Fatalf("Expected migration to be recorded, got '%v'", err)
+	}
+	if record.Scope != "greeter" || record.Version != 2 || record.AppliedAt.IsZero() {
+		t.Errorf("Unexpected record %+v", record)
+	}
+
+	if count, _ := locks.Count(context.Background(), bson.M{}); count != 0 {
+		t.Errorf("Expected lock to be released, found %d lock documents", count)
+	}
+
+	// A second run is a no-op
+	applied, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(applied) != 0 || len(calls) != 3 {
+		t.Errorf("Expected no migrations to be re-applied, got %d applied and calls %v", len(applied), calls)
+	}
+}
+
+func TestMigrator_StopsAtFailure(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+	errStep := errors.New("index build failed")
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Up: recordingStep("greeter/1", &calls, &mu)},
+		{Scope: "greeter", Version: 2, Up: func(ctx context.Context, database *mongo.Database) error { return errStep }},
+		{Scope: "greeter", Version: 3, Up: recordingStep("greeter/3", &calls, &mu)},
+	}
+
+	records := db.NewMemoryRepository[migrate.Record]()
+	locks := db.NewMemoryRepository[migrate.Lock]()
+	migrator := newMigrator(records, locks, migrations)
+
+	applied, err := migrator.Up(context.Background())
+	if !errors.Is(err, errStep) {
+		t.Fatalf("Expected step error, got '%v'", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Errorf("Expected only the first migration to be applied, got %v", applied)
+	}
+
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(pending) != 2 || pending[0].Version != 2 {
+		t.Errorf("Expected migrations 2 and 3 to be pending, got %v", pending)
+	}
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Description: "unique index on name", Up: recordingStep("greeter/1", &calls, &mu)},
+		{Scope: "greeter", Version: 2, Description: "backfill createdAt", Up: recordingStep("greeter/2", &calls, &mu)},
+	}
+
+	records := db.NewMemoryRepository[migrate.Record]()
+	records.Insert(context.Background(), &migrate.Record{ID: "greeter/0001", Scope: "greeter", Version: 1})
+	migrator := newMigrator(records, db.NewMemoryRepository[migrate.Lock](), migrations)
+
+	var out bytes.Buffer
+	if err := migrator.DryRun(context.Background(), &out); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := "1 pending migration(s):\n  greeter/0002  backfill createdAt\n"
+	if out.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, out.String())
+	}
+	if len(calls) != 0 {
+		t.Errorf("Expected dry run not to apply migrations, got calls %v", calls)
+	}
+}
+
+func TestMigrator_WithScope(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Up: recordingStep("greeter/1", &calls, &mu)},
+		{Scope: "billing", Version: 1, Up: recordingStep("billing/1", &calls, &mu)},
+	}
+
+	migrator := newMigrator(db.NewMemoryRepository[migrate.Record](), db.NewMemoryRepository[migrate.Lock](), migrations, migrate.WithScope("billing"))
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if strings.Join(calls, ",") != "billing/1" {
+		t.Errorf("Expected only billing migrations, got %v", calls)
+	}
+}
+
+func TestMigrator_WaitsForLock(t *testing.T) {
+	records := db.NewMemoryRepository[migrate.Record]()
+	locks := db.NewMemoryRepository[migrate.Lock]()
+	locks.Insert(context.Background(), &migrate.Lock{ID: "migrations", Owner: "other", LockedUntil: time.Now().Add(time.Hour)})
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Up: func(ctx context.Context, database *mongo.Database) error { return nil }},
+	}
+	migrator := newMigrator(records, locks, migrations, migrate.WithOwner("me"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := migrator.Up(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected to time out waiting for the lock, got '%v'", err)
+	}
+	if lock, _ := locks.FindByID(context.Background(), "migrations"); lock == nil || lock.Owner != "other" {
+		t.Errorf("Expected the other runner to keep the lock, got %+v", lock)
+	}
+
+	// An expired lock is taken over
+	locks.Update(context.Background(), bson.M{"_id": "migrations"}, bson.M{"$set": bson.M{"lockedUntil": time.Now().Add(-time.Minute)}})
+	if applied, err := migrator.Up(context.Background()); err != nil || len(applied) != 1 {
+		t.Fatalf("Expected the abandoned lock to be taken over, got %v, '%v'", applied, err)
+	}
+}
+
+func TestMigrator_ExtendsLockWhileRunning(t *testing.T) {
+	records := db.NewMemoryRepository[migrate.Record]()
+	locks := db.NewMemoryRepository[migrate.Lock]()
+	const lockTTL = 30 * time.Millisecond
+
+	var lock *migrate.Lock
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Up: func(ctx context.Context, database *mongo.Database) error {
+			// Outlive the initial lock TTL several times over
+			time.Sleep(4 * lockTTL)
+			lock, _ = locks.FindByID(ctx, "migrations")
+			return nil
+		}},
+	}
+	migrator := newMigrator(records, locks, migrations, migrate.WithOwner("me"), migrate.WithLockTTL(lockTTL))
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if lock == nil || lock.Owner != "me" || !lock.LockedUntil.After(time.Now()) {
+		t.Errorf("Expected the lock to be extended while the migration ran, got %+v", lock)
+	}
+}
+
+func TestMigrator_StopsWhenLockIsLost(t *testing.T) {
+	records := db.NewMemoryRepository[migrate.Record]()
+	locks := db.NewMemoryRepository[migrate.Lock]()
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Up: func(ctx context.Context, database *mongo.Database) error {
+			// Another runner takes over the lock
+			locks.Update(ctx, bson.M{"_id": "migrations"}, bson.M{"$set": bson.M{"owner": "other"}})
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+	migrator := newMigrator(records, locks, migrations, migrate.WithOwner("me"), migrate.WithLockTTL(30*time.Millisecond))
+
+	_, err := migrator.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "migration lock lost") {
+		t.Errorf("Expected the migration to stop when the lock is lost, got '%v'", err)
+	}
+	if lock, _ := locks.FindByID(context.Background(), "migrations"); lock == nil || lock.Owner != "other" {
+		t.Errorf("Expected the other runner to keep the lock, got %+v", lock)
+	}
+}
+
+func TestMigrator_ConcurrentRunnersApplyOnce(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+
+	migrations := []migrate.Migration{
+		{Scope: "greeter", Version: 1, Up: recordingStep("greeter/1", &calls, &mu)},
+		{Scope: "greeter", Version: 2, Up: recordingStep("greeter/2", &calls, &mu)},
+	}
+
+	records := db.NewMemoryRepository[migrate.Record]()
+	locks := db.NewMemoryRepository[migrate.Lock]()
+
+	const numRunners = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, numRunners)
+
+	wg.Add(numRunners)
+	for i := range numRunners {
+		go func() {
+			defer wg.Done()
+			migrator := newMigrator(records, locks, migrations, migrate.WithOwner(string(rune('a'+i))))
+			_, err := migrator.Up(context.Background())
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+	if strings.Join(calls, ",") != "greeter/1,greeter/2" {
+		t.Errorf("Expected each migration to run exactly once, got %v", calls)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	noop := func(ctx context.Context, database *mongo.Database) error { return nil }
+
+	migrate.Register(migrate.Migration{Scope: "registry-test", Version: 1, Up: noop})
+
+	found := false
+	for _, m := range migrate.Registered() {
+		if m.ID() == "registry-test/0001" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected registered migration to be listed")
+	}
+
+	tests := []struct {
+		name      string
+		migration migrate.Migration
+	}{
+		{"duplicate", migrate.Migration{Scope: "registry-test", Version: 1, Up: noop}},
+		{"missing scope", migrate.Migration{Version: 1, Up: noop}},
+		{"invalid version", migrate.Migration{Scope: "registry-test", Version: 0, Up: noop}},
+		{"missing up", migrate.Migration{Scope: "registry-test", Version: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected Register to panic")
+				}
+			}()
+			migrate.Register(tt.migration)
+		})
+	}
+}
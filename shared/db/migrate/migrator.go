@@ -0,0 +1,345 @@
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	// RecordsCollection is the collection that tracks applied migrations.
+	RecordsCollection = "_migrations"
+	// LockCollection is the collection that holds the distributed lock.
+	LockCollection = "_migrations_lock"
+
+	lockID = "migrations"
+)
+
+// Record is the document stored in RecordsCollection for each applied migration.
+type Record struct {
+	ID          string    `bson:"_id"`
+	Scope       string    `bson:"scope"`
+	Version     int       `bson:"version"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// Lock is the document stored in LockCollection while a runner applies migrations.
+// A lock whose LockedUntil has passed is considered abandoned and can be taken over.
+type Lock struct {
+	ID          string    `bson:"_id"`
+	Owner       string    `bson:"owner"`
+	LockedUntil time.Time `bson:"lockedUntil"`
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	database     *mongo.Database
+	records      db.Repository[Record]
+	locks        db.Repository[Lock]
+	migrations   []Migration
+	owner        string
+	lockTTL      time.Duration
+	pollInterval time.Duration
+	logger       *slog.Logger
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithMigrations sets the migrations to apply instead of the registered ones.
+func WithMigrations(migrations ...Migration) Option {
+	return func(m *Migrator) {
+		m.migrations = sortMigrations(migrations)
+	}
+}
+
+// WithScope restricts the migrator to migrations of the given scopes.
+func WithScope(scopes ...string) Option {
+	return func(m *Migrator) {
+		var filtered []Migration
+		for _, migration := range m.migrations {
+			for _, scope := range scopes {
+				if migration.Scope == scope {
+					filtered = append(filtered, migration)
+				}
+			}
+		}
+		m.migrations = filtered
+	}
+}
+
+// WithRepositories replaces the MongoDB-backed records and lock repositories,
+// e.g. with db.MemoryRepository in tests.
+func WithRepositories(records db.Repository[Record], locks db.Repository[Lock]) Option {
+	return func(m *Migrator) {
+		m.records = records
+		m.locks = locks
+	}
+}
+
+// WithOwner sets the lock owner name. It defaults to a random token, since hostnames
+// and process IDs repeat across Lambda sandboxes.
+func WithOwner(owner string) Option {
+	return func(m *Migrator) {
+		m.owner = owner
+	}
+}
+
+// WithLockTTL sets how long the lock is held before it is considered abandoned.
+// While migrations run, the lock is extended every third of the TTL, so the TTL only
+// bounds how long a crashed runner blocks the others.
+func WithLockTTL(ttl time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTTL = ttl
+	}
+}
+
+// WithPollInterval sets how often a waiting runner retries to acquire the lock.
+func WithPollInterval(interval time.Duration) Option {
+	return func(m *Migrator) {
+		m.pollInterval = interval
+	}
+}
+
+// WithLogger sets the logger. It defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Migrator) {
+		m.logger = logger
+	}
+}
+
+// New returns a Migrator for database using the registered migrations.
+// Options are applied in order, so WithScope should follow WithMigrations.
+func New(database *mongo.Database, opts ...Option) *Migrator {
+	m := &Migrator{
+		database:     database,
+		migrations:   Registered(),
+		owner:        rand.Text(),
+		lockTTL:      5 * time.Minute,
+		pollInterval: 250 * time.Millisecond,
+		logger:       slog.Default(),
+	}
+
+	if database != nil {
+		m.records = db.NewMongoRepository[Record](database.Collection(RecordsCollection))
+		m.locks = db.NewMongoRepository[Lock](database.Collection(LockCollection))
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Run applies the registered migrations to the database of the default provider (see
+// db.Provider.Database). It is intended for Lambda cold starts and returns the migrations
+// it applied. Connecting to MongoDB counts against ctx as well.
+func Run(ctx context.Context, opts ...Option) ([]Migration, error) {
+	database, err := db.DefaultProvider().Database(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(database, opts...).Up(ctx)
+}
+
+// Pending returns the migrations that have not been applied yet, in order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	records, err := m.records.List(ctx, bson.M{}, db.Page{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	applied := make(map[string]bool, len(records))
+	for _, record := range records {
+		applied[record.ID] = true
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.ID()] {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// DryRun writes the pending migrations to w without applying them.
+func (m *Migrator) DryRun(ctx context.Context, w io.Writer) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		_, err := fmt.Fprintln(w, "No pending migrations.")
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%d pending migration(s):\n", len(pending)); err != nil {
+		return err
+	}
+	for _, migration := range pending {
+		if _, err := fmt.Fprintf(w, "  %s  %s\n", migration.ID(), migration.Description); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Up applies the pending migrations in order while holding the distributed lock and
+// returns the migrations it applied. If another runner holds the lock, Up waits until
+// it is released or ctx is done. Up stops at the first failing migration, or when the
+// lock is lost to another runner.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	ctx, stop := m.keepLock(ctx)
+	defer stop()
+
+	// Re-read under the lock, so migrations applied by the previous holder are skipped
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range pending {
+		m.logger.InfoContext(ctx, "Applying migration",
+			slog.String("id", migration.ID()),
+			slog.String("description", migration.Description),
+		)
+
+		if err := migration.Up(ctx, m.database); err != nil {
+			if errors.Is(context.Cause(ctx), errLockLost) {
+				err = fmt.Errorf("%w: %w", errLockLost, err)
+			}
+			return applied, fmt.Errorf("migration %s failed: %w", migration.ID(), err)
+		}
+
+		_, err := m.records.Insert(ctx, &Record{
+			ID:          migration.ID(),
+			Scope:       migration.Scope,
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		if err != nil && !apperrors.IsConflict(err) {
+			return applied, fmt.Errorf("failed to record migration %s: %w", migration.ID(), err)
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// lock acquires the distributed lock, waiting while another runner holds it.
+func (m *Migrator) lock(ctx context.Context) error {
+	for {
+		now := time.Now()
+		_, err := m.locks.FindOneAndUpdate(ctx,
+			bson.M{
+				"_id": lockID,
+				"$or": bson.A{
+					bson.M{"lockedUntil": bson.M{"$lte": now}},
+					bson.M{"owner": m.owner},
+				},
+			},
+			bson.M{"$set": bson.M{
+				"owner":       m.owner,
+				"lockedUntil": now.Add(m.lockTTL),
+			}},
+			true,
+		)
+		if err == nil {
+			return nil
+		}
+		if !apperrors.IsConflict(err) {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		// The lock document exists and belongs to another runner
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for migration lock: %w", ctx.Err())
+		case <-time.After(m.pollInterval):
+		}
+	}
+}
+
+// errLockLost cancels the migrations when another runner took over the lock.
+var errLockLost = errors.New("migration lock lost to another runner")
+
+// keepLock extends the lock every third of the lock TTL until stop is called, so slow
+// migrations do not outlive it. The returned context is cancelled with errLockLost if the
+// lock was taken over in the meantime.
+func (m *Migrator) keepLock(ctx context.Context) (_ context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(max(m.lockTTL/3, time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			_, err := m.locks.FindOneAndUpdate(ctx,
+				bson.M{"_id": lockID, "owner": m.owner},
+				bson.M{"$set": bson.M{"lockedUntil": time.Now().Add(m.lockTTL)}},
+				false,
+			)
+			if apperrors.IsNotFound(err) {
+				cancel(errLockLost)
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				// The lock stays valid until it expires; try again on the next tick
+				m.logger.WarnContext(ctx, "Failed to extend migration lock", slog.Any("error", err))
+			}
+		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		<-stopped
+		cancel(nil)
+	}
+}
+
+// unlock releases the lock if it is still held by this runner.
+func (m *Migrator) unlock() {
+	// Use a fresh context so the lock is released even if ctx was cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := m.locks.Delete(ctx, bson.M{"_id": lockID, "owner": m.owner}); err != nil {
+		m.logger.WarnContext(ctx, "Failed to release migration lock", slog.Any("error", err))
+	}
+}
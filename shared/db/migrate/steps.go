@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xarunoba/mlgmr/shared/db"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CreateIndex returns a step that creates index on collection.
+// Creating an index that already exists with the same options is a no-op.
+func CreateIndex(collection string, index db.Index) Func {
+	return func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    index.Keys,
+			Options: options.Index().SetUnique(index.Unique),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create index on %s: %w", collection, err)
+		}
+		return nil
+	}
+}
+
+// BackfillField returns a step that sets field to value on every document of
+// collection that does not have the field yet.
+func BackfillField(collection, field string, value any) Func {
+	return func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(collection).UpdateMany(ctx,
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field: value}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to backfill %s.%s: %w", collection, field, err)
+		}
+		return nil
+	}
+}
+
+// RenameCollection returns a step that renames collection from to to.
+// It is a no-op if from no longer exists, so a re-run after a crash succeeds.
+func RenameCollection(from, to string) Func {
+	return func(ctx context.Context, database *mongo.Database) error {
+		names, err := database.ListCollectionNames(ctx, bson.M{"name": from})
+		if err != nil {
+			return fmt.Errorf("failed to list collections: %w", err)
+		}
+		if len(names) == 0 {
+			return nil
+		}
+
+		err = database.Client().Database("admin").RunCommand(ctx, bson.D{
+			{Key: "renameCollection", Value: database.Name() + "." + from},
+			{Key: "to", Value: database.Name() + "." + to},
+		}).Err()
+		if err != nil {
+			return fmt.Errorf("failed to rename collection %s to %s: %w", from, to, err)
+		}
+		return nil
+	}
+}
+
+// Steps returns a step that runs the given steps in order.
+func Steps(steps ...Func) Func {
+	return func(ctx context.Context, database *mongo.Database) error {
+		for _, step := range steps {
+			if err := step(ctx, database); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
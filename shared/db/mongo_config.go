@@ -0,0 +1,230 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver/connstring"
+)
+
+// Default MongoDB client settings, used for unset MONGODB_* variables. Idle connections
+// are closed after a minute because the container may be frozen between invocations.
+const (
+	DefaultMongoMaxPoolSize            uint64 = 5
+	DefaultMongoMinPoolSize            uint64 = 0
+	DefaultMongoMaxConnIdleTime               = 60 * time.Second
+	DefaultMongoServerSelectionTimeout        = 5 * time.Second
+	DefaultMongoConnectTimeout                = 5 * time.Second
+	DefaultMongoPingTimeout                   = 5 * time.Second
+	DefaultMongoHealthCheckInterval           = 30 * time.Second
+)
+
+// MongoConfig holds the MongoDB client configuration.
+type MongoConfig struct {
+	// URI is the connection string (MONGODB_URI). Options set in the URI take
+	// precedence over the fields below.
+	URI string
+	// Database is the database functions use (MONGODB_DATABASE). Empty uses the URI path.
+	Database string
+	// MaxPoolSize is the maximum number of connections (MONGODB_MAX_POOL_SIZE).
+	MaxPoolSize uint64
+	// MinPoolSize is the minimum number of idle connections kept open (MONGODB_MIN_POOL_SIZE).
+	MinPoolSize uint64
+	// MaxConnIdleTime is how long a connection may stay idle before it is closed (MONGODB_MAX_CONN_IDLE_TIME).
+	MaxConnIdleTime time.Duration
+	// ServerSelectionTimeout bounds how long an operation waits for a suitable server (MONGODB_SERVER_SELECTION_TIMEOUT).
+	ServerSelectionTimeout time.Duration
+	// ConnectTimeout bounds how long opening a connection may take (MONGODB_CONNECT_TIMEOUT).
+	ConnectTimeout time.Duration
+	// PingTimeout bounds the health check ping (MONGODB_PING_TIMEOUT). Zero uses DefaultMongoPingTimeout.
+	PingTimeout time.Duration
+	// HealthCheckInterval is how long a successful health check is trusted before the
+	// client is pinged again (MONGODB_HEALTH_CHECK_INTERVAL). Zero pings on every use.
+	HealthCheckInterval time.Duration
+	// AppName is reported to the server (MONGODB_APP_NAME). It defaults to the Lambda function name.
+	AppName string
+	// ReadPreference is one of primary, primaryPreferred, secondary, secondaryPreferred
+	// or nearest (MONGODB_READ_PREFERENCE). Empty uses the driver default.
+	ReadPreference string
+	// WriteConcern is "majority" or a number of nodes (MONGODB_WRITE_CONCERN). Empty uses the driver default.
+	WriteConcern string
+	// RetryWrites enables retryable writes (MONGODB_RETRY_WRITES).
+	RetryWrites bool
+	// Compressors lists the wire compressors to negotiate: snappy, zlib, zstd (MONGODB_COMPRESSORS, comma-separated).
+	Compressors []string
+}
+
+// DefaultMongoConfig returns the Lambda-friendly defaults for the given URI.
+func DefaultMongoConfig(uri string) MongoConfig {
+	return MongoConfig{
+		URI:                    uri,
+		MaxPoolSize:            DefaultMongoMaxPoolSize,
+		MinPoolSize:            DefaultMongoMinPoolSize,
+		MaxConnIdleTime:        DefaultMongoMaxConnIdleTime,
+		ServerSelectionTimeout: DefaultMongoServerSelectionTimeout,
+		ConnectTimeout:         DefaultMongoConnectTimeout,
+		PingTimeout:            DefaultMongoPingTimeout,
+		HealthCheckInterval:    DefaultMongoHealthCheckInterval,
+		AppName:                os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		RetryWrites:            true,
+	}
+}
+
+// LoadMongoConfig reads the MongoDB configuration from the environment, applying
+// DefaultMongoConfig for variables that are not set. All invalid values are
+// reported together in the returned error.
+func LoadMongoConfig() (MongoConfig, error) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		return MongoConfig{}, fmt.Errorf("MONGODB_URI environment variable not set")
+	}
+
+	cfg := DefaultMongoConfig(uri)
+	var errs []error
+
+	parseEnv(&errs, "MONGODB_MAX_POOL_SIZE", &cfg.MaxPoolSize, parseUint)
+	parseEnv(&errs, "MONGODB_MIN_POOL_SIZE", &cfg.MinPoolSize, parseUint)
+	parseEnv(&errs, "MONGODB_MAX_CONN_IDLE_TIME", &cfg.MaxConnIdleTime, time.ParseDuration)
+	parseEnv(&errs, "MONGODB_SERVER_SELECTION_TIMEOUT", &cfg.ServerSelectionTimeout, time.ParseDuration)
+	parseEnv(&errs, "MONGODB_CONNECT_TIMEOUT", &cfg.ConnectTimeout, time.ParseDuration)
+	parseEnv(&errs, "MONGODB_PING_TIMEOUT", &cfg.PingTimeout, time.ParseDuration)
+	parseEnv(&errs, "MONGODB_HEALTH_CHECK_INTERVAL", &cfg.HealthCheckInterval, time.ParseDuration)
+	parseEnv(&errs, "MONGODB_RETRY_WRITES", &cfg.RetryWrites, strconv.ParseBool)
+
+	cfg.Database = os.Getenv("MONGODB_DATABASE")
+	if appName := os.Getenv("MONGODB_APP_NAME"); appName != "" {
+		cfg.AppName = appName
+	}
+	cfg.ReadPreference = os.Getenv("MONGODB_READ_PREFERENCE")
+	cfg.WriteConcern = os.Getenv("MONGODB_WRITE_CONCERN")
+	if compressors := os.Getenv("MONGODB_COMPRESSORS"); compressors != "" {
+		for compressor := range strings.SplitSeq(compressors, ",") {
+			cfg.Compressors = append(cfg.Compressors, strings.TrimSpace(compressor))
+		}
+	}
+
+	if len(errs) > 0 {
+		return MongoConfig{}, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return MongoConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that the configuration values are consistent.
+func (c MongoConfig) Validate() error {
+	var errs []error
+
+	if c.URI == "" {
+		errs = append(errs, fmt.Errorf("MONGODB_URI must not be empty"))
+	}
+	if c.MaxPoolSize > 0 && c.MinPoolSize > c.MaxPoolSize {
+		errs = append(errs, fmt.Errorf("MONGODB_MIN_POOL_SIZE (%d) must not exceed MONGODB_MAX_POOL_SIZE (%d)", c.MinPoolSize, c.MaxPoolSize))
+	}
+	for name, d := range map[string]time.Duration{
+		"MONGODB_MAX_CONN_IDLE_TIME":       c.MaxConnIdleTime,
+		"MONGODB_SERVER_SELECTION_TIMEOUT": c.ServerSelectionTimeout,
+		"MONGODB_CONNECT_TIMEOUT":          c.ConnectTimeout,
+		"MONGODB_PING_TIMEOUT":             c.PingTimeout,
+		"MONGODB_HEALTH_CHECK_INTERVAL":    c.HealthCheckInterval,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+	if c.ReadPreference != "" {
+		if _, err := readpref.ModeFromString(c.ReadPreference); err != nil {
+			errs = append(errs, fmt.Errorf("invalid MONGODB_READ_PREFERENCE %q", c.ReadPreference))
+		}
+	}
+	if c.WriteConcern != "" {
+		if _, err := parseWriteConcern(c.WriteConcern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, compressor := range c.Compressors {
+		if !slices.Contains([]string{"snappy", "zlib", "zstd"}, compressor) {
+			errs = append(errs, fmt.Errorf("invalid MONGODB_COMPRESSORS entry %q: must be snappy, zlib or zstd", compressor))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DatabaseName returns Database, or the database in the path of the URI
+// (e.g. mongodb://host:27017/mlgmr) if Database is empty.
+func (c MongoConfig) DatabaseName() (string, error) {
+	if c.Database != "" {
+		return c.Database, nil
+	}
+
+	if c.URI == "" {
+		return "", fmt.Errorf("MONGODB_URI environment variable not set")
+	}
+
+	cs, err := connstring.Parse(c.URI)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse MONGODB_URI: %w", err)
+	}
+
+	if cs.Database == "" {
+		return "", fmt.Errorf("no database in MONGODB_URI path and MONGODB_DATABASE environment variable not set")
+	}
+
+	return cs.Database, nil
+}
+
+// ClientOptions converts the configuration into driver client options.
+// The configuration must be valid.
+func (c MongoConfig) ClientOptions() *options.ClientOptions {
+	opts := options.Client().
+		SetMaxPoolSize(c.MaxPoolSize).
+		SetMinPoolSize(c.MinPoolSize).
+		SetMaxConnIdleTime(c.MaxConnIdleTime).
+		SetServerSelectionTimeout(c.ServerSelectionTimeout).
+		SetConnectTimeout(c.ConnectTimeout).
+		SetRetryWrites(c.RetryWrites)
+
+	if c.AppName != "" {
+		opts.SetAppName(c.AppName)
+	}
+	if c.ReadPreference != "" {
+		mode, _ := readpref.ModeFromString(c.ReadPreference)
+		rp, _ := readpref.New(mode)
+		opts.SetReadPreference(rp)
+	}
+	if c.WriteConcern != "" {
+		wc, _ := parseWriteConcern(c.WriteConcern)
+		opts.SetWriteConcern(wc)
+	}
+	if len(c.Compressors) > 0 {
+		opts.SetCompressors(c.Compressors)
+	}
+
+	// Options in the URI take precedence over the configuration above
+	return opts.ApplyURI(c.URI)
+}
+
+// parseWriteConcern parses "majority" or a number of acknowledging nodes.
+func parseWriteConcern(s string) (*writeconcern.WriteConcern, error) {
+	if s == "majority" {
+		return writeconcern.Majority(), nil
+	}
+
+	w, err := strconv.Atoi(s)
+	if err != nil || w < 0 {
+		return nil, fmt.Errorf("invalid MONGODB_WRITE_CONCERN %q: must be \"majority\" or a non-negative number", s)
+	}
+	return &writeconcern.WriteConcern{W: w}, nil
+}
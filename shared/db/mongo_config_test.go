@@ -0,0 +1,177 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/db"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+)
+
+// mongoConfigEnv lists every variable read by LoadMongoConfig.
+var mongoConfigEnv = []string{
+	"MONGODB_URI",
+	"MONGODB_MAX_POOL_SIZE",
+	"MONGODB_MIN_POOL_SIZE",
+	"MONGODB_MAX_CONN_IDLE_TIME",
+	"MONGODB_SERVER_SELECTION_TIMEOUT",
+	"MONGODB_CONNECT_TIMEOUT",
+	"MONGODB_PING_TIMEOUT",
+	"MONGODB_HEALTH_CHECK_INTERVAL",
+	"MONGODB_APP_NAME",
+	"MONGODB_READ_PREFERENCE",
+	"MONGODB_WRITE_CONCERN",
+	"MONGODB_RETRY_WRITES",
+	"MONGODB_COMPRESSORS",
+	"AWS_LAMBDA_FUNCTION_NAME",
+}
+
+func setMongoConfigEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for _, name := range mongoConfigEnv {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestLoadMongoConfig_Defaults(t *testing.T) {
+	setMongoConfigEnv(t, map[string]string{
+		"MONGODB_URI":              "mongodb://localhost:27017/test",
+		"AWS_LAMBDA_FUNCTION_NAME": "greeter",
+	})
+
+	cfg, err := db.LoadMongoConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := db.DefaultMongoConfig("mongodb://localhost:27017/test")
+	if cfg.MaxPoolSize != expected.MaxPoolSize ||
+		cfg.MinPoolSize != expected.MinPoolSize ||
+		cfg.MaxConnIdleTime != expected.MaxConnIdleTime ||
+		cfg.ServerSelectionTimeout != expected.ServerSelectionTimeout ||
+		cfg.ConnectTimeout != expected.ConnectTimeout ||
+		cfg.PingTimeout != expected.PingTimeout ||
+		cfg.HealthCheckInterval != expected.HealthCheckInterval ||
+		!cfg.RetryWrites {
+		t.Errorf("Expected defaults %+v, got %+v", expected, cfg)
+	}
+	if cfg.AppName != "greeter" {
+		t.Errorf("Expected app name to default to the function name, got '%s'", cfg.AppName)
+	}
+}
+
+func TestLoadMongoConfig_FromEnvironment(t *testing.T) {
+	setMongoConfigEnv(t, map[string]string{
+		"MONGODB_URI":                      "mongodb://localhost:27017/test",
+		"MONGODB_MAX_POOL_SIZE":            "20",
+		"MONGODB_MIN_POOL_SIZE":            "2",
+		"MONGODB_MAX_CONN_IDLE_TIME":       "15s",
+		"MONGODB_SERVER_SELECTION_TIMEOUT": "2s",
+		"MONGODB_CONNECT_TIMEOUT":          "1500ms",
+		"MONGODB_PING_TIMEOUT":             "1s",
+		"MONGODB_HEALTH_CHECK_INTERVAL":    "10s",
+		"MONGODB_APP_NAME":                 "mlgmr",
+		"MONGODB_READ_PREFERENCE":          "secondaryPreferred",
+		"MONGODB_WRITE_CONCERN":            "majority",
+		"MONGODB_RETRY_WRITES":             "false",
+		"MONGODB_COMPRESSORS":              "zstd, snappy",
+	})
+
+	cfg, err := db.LoadMongoConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	if cfg.MaxPoolSize != 20 || cfg.MinPoolSize != 2 {
+		t.Errorf("Unexpected pool sizes %d/%d", cfg.MinPoolSize, cfg.MaxPoolSize)
+	}
+	if cfg.MaxConnIdleTime != 15*time.Second || cfg.ServerSelectionTimeout != 2*time.Second ||
+		cfg.ConnectTimeout != 1500*time.Millisecond || cfg.PingTimeout != time.Second ||
+		cfg.HealthCheckInterval != 10*time.Second {
+		t.Errorf("Unexpected timeouts %+v", cfg)
+	}
+	if cfg.AppName != "mlgmr" || cfg.RetryWrites {
+		t.Errorf("Unexpected app name or retry writes %+v", cfg)
+	}
+	if strings.Join(cfg.Compressors, ",") != "zstd,snappy" {
+		t.Errorf("Expected compressors [zstd snappy], got %v", cfg.Compressors)
+	}
+
+	opts := cfg.ClientOptions()
+	if *opts.MaxPoolSize != 20 || *opts.MinPoolSize != 2 || *opts.AppName != "mlgmr" {
+		t.Errorf("Expected client options to reflect the configuration")
+	}
+	if opts.ReadPreference.Mode() != readpref.SecondaryPreferredMode {
+		t.Errorf("Expected secondaryPreferred read preference, got %s", opts.ReadPreference.Mode())
+	}
+	if opts.WriteConcern.W != "majority" {
+		t.Errorf("Expected majority write concern, got %v", opts.WriteConcern.W)
+	}
+	if *opts.RetryWrites {
+		t.Error("Expected retryable writes to be disabled")
+	}
+}
+
+func TestLoadMongoConfig_URIOptionsTakePrecedence(t *testing.T) {
+	setMongoConfigEnv(t, map[string]string{
+		"MONGODB_URI":           "mongodb://localhost:27017/test?maxPoolSize=50",
+		"MONGODB_MAX_POOL_SIZE": "20",
+	})
+
+	cfg, err := db.LoadMongoConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if opts := cfg.ClientOptions(); *opts.MaxPoolSize != 50 {
+		t.Errorf("Expected the URI pool size to win, got %d", *opts.MaxPoolSize)
+	}
+}
+
+func TestLoadMongoConfig_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		contains []string
+	}{
+		{"missing URI", map[string]string{}, []string{"MONGODB_URI environment variable not set"}},
+		{"bad pool size", map[string]string{"MONGODB_MAX_POOL_SIZE": "many"}, []string{"invalid MONGODB_MAX_POOL_SIZE"}},
+		{"negative pool size", map[string]string{"MONGODB_MIN_POOL_SIZE": "-1"}, []string{"invalid MONGODB_MIN_POOL_SIZE"}},
+		{"bad duration", map[string]string{"MONGODB_CONNECT_TIMEOUT": "5"}, []string{"invalid MONGODB_CONNECT_TIMEOUT"}},
+		{"negative duration", map[string]string{"MONGODB_PING_TIMEOUT": "-1s"}, []string{"MONGODB_PING_TIMEOUT must not be negative"}},
+		{"min above max", map[string]string{"MONGODB_MIN_POOL_SIZE": "10", "MONGODB_MAX_POOL_SIZE": "5"}, []string{"must not exceed"}},
+		{"bad read preference", map[string]string{"MONGODB_READ_PREFERENCE": "fastest"}, []string{"invalid MONGODB_READ_PREFERENCE"}},
+		{"bad write concern", map[string]string{"MONGODB_WRITE_CONCERN": "all"}, []string{"invalid MONGODB_WRITE_CONCERN"}},
+		{"bad retry writes", map[string]string{"MONGODB_RETRY_WRITES": "sometimes"}, []string{"invalid MONGODB_RETRY_WRITES"}},
+		{"bad compressor", map[string]string{"MONGODB_COMPRESSORS": "gzip"}, []string{"invalid MONGODB_COMPRESSORS entry \"gzip\""}},
+		{
+			"multiple errors reported together",
+			map[string]string{"MONGODB_MAX_POOL_SIZE": "x", "MONGODB_CONNECT_TIMEOUT": "y"},
+			[]string{"invalid MONGODB_MAX_POOL_SIZE", "invalid MONGODB_CONNECT_TIMEOUT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"MONGODB_URI": "mongodb://localhost:27017/test"}
+			if tt.name == "missing URI" {
+				env = map[string]string{}
+			}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			setMongoConfigEnv(t, env)
+
+			_, err := db.LoadMongoConfig()
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain '%s', got '%s'", want, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -3,63 +3,90 @@ package db
 import (
 	"context"
 	"fmt"
-	"os"
-	"sync"
-	"time"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
-)
-
-var (
-	mongoClient *mongo.Client
-	mongoMutex  sync.Mutex
 )
 
 // GetMongoClient returns a singleton MongoDB client instance optimized for AWS Lambda.
-// It reads the MongoDB URI from the MONGODB_URI environment variable.
+// It reads its configuration from the environment (see LoadMongoConfig).
 // The client persists across Lambda invocations for connection reuse.
 // Automatically handles health checking and reconnection transparently.
+// Every command is traced as a child span of its context (see MongoCommandMonitor).
+// It is a shorthand for DefaultProvider().Mongo(context.Background()).
 func GetMongoClient() (*mongo.Client, error) {
-	mongoMutex.Lock()
-	defer mongoMutex.Unlock()
+	return DefaultProvider().Mongo(context.Background())
+}
+
+// Mongo returns the provider's MongoDB client, connecting on first use.
+// An existing client is pinged when its health check is due and replaced if it is unhealthy.
+func (p *Provider) Mongo(ctx context.Context) (*mongo.Client, error) {
+	if p.memory != nil {
+		return nil, ErrMemoryDatabase
+	}
+
+	client, _, err := p.mongo(ctx)
+	return client, err
+}
+
+// mongo implements Mongo and also returns the configuration of the client.
+func (p *Provider) mongo(ctx context.Context) (*mongo.Client, MongoConfig, error) {
+	if err := acquire(ctx, p.mongoSem); err != nil {
+		return nil, MongoConfig{}, err
+	}
+	defer release(p.mongoSem)
 
 	// If we have a client, check if it's still healthy
-	if mongoClient != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if p.mongoClient != nil {
+		cfg := p.mongoCfg
+		if !p.mongoHealth.due(cfg.HealthCheckInterval) {
+			return p.mongoClient, cfg, nil
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, orDefault(cfg.PingTimeout, DefaultMongoPingTimeout))
 		defer cancel()
 
-		if err := mongoClient.Ping(ctx, nil); err != nil {
-			// Client is unhealthy, disconnect and reset
-			mongoClient.Disconnect(context.Background())
-			mongoClient = nil
-		} else {
+		err := p.mongoHealth.ping(func() error { return p.mongoClient.Ping(pingCtx, nil) })
+		switch {
+		case err == nil:
 			// Client is healthy, return it
-			return mongoClient, nil
+			return p.mongoClient, cfg, nil
+		case ctx.Err() != nil:
+			// The caller gave up; that says nothing about the client's health
+			return nil, cfg, ctx.Err()
+		case p.mongoInjected:
+			return nil, cfg, fmt.Errorf("failed to ping MongoDB: %w", err)
+		default:
+			// Client is unhealthy, disconnect and reset
+			p.mongoClient.Disconnect(context.Background())
+			p.mongoClient = nil
 		}
 	}
 
 	// Need to create new client
-	uri := os.Getenv("MONGODB_URI")
-	if uri == "" {
-		return nil, fmt.Errorf("MONGODB_URI environment variable not set")
+	cfg, err := p.mongoConfig()
+	if err != nil {
+		return nil, cfg, err
 	}
 
-	// Create connection
-	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	opts := cfg.ClientOptions().
+		SetPoolMonitor(p.mongoHealth.mongoPoolMonitor()).
+		SetMonitor(MongoCommandMonitor())
+	client, err := mongo.Connect(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, cfg, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 
 	// Verify connection with ping
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	pingCtx, cancel := context.WithTimeout(ctx, orDefault(cfg.PingTimeout, DefaultMongoPingTimeout))
 	defer cancel()
 
-	if err := client.Ping(ctx, nil); err != nil {
+	if err := p.mongoHealth.ping(func() error { return client.Ping(pingCtx, nil) }); err != nil {
 		client.Disconnect(context.Background())
-		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+		return nil, cfg, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	mongoClient = client
-	return mongoClient, nil
+	p.mongoClient = client
+	p.mongoCfg = withDatabaseName(cfg)
+	p.mongoHealth.connected()
+	return p.mongoClient, cfg, nil
 }
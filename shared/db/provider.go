@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Clients gives access to the database clients of a function.
+// Provider implements it; handlers depend on it so tests can inject fakes.
+type Clients interface {
+	// Mongo returns a healthy MongoDB client.
+	Mongo(ctx context.Context) (*mongo.Client, error)
+	// Database returns the configured MongoDB database.
+	Database(ctx context.Context) (*mongo.Database, error)
+	// Redis returns a healthy Redis client.
+	Redis(ctx context.Context) (redis.UniversalClient, error)
+}
+
+// Compile-time check to ensure Provider implements Clients
+var _ Clients = (*Provider)(nil)
+
+// Provider owns a MongoDB and a Redis client and hands them out to handlers.
+// Clients are created lazily on first use and recreated if they become unhealthy.
+// A client is pinged at most once per health check interval (see
+// MongoConfig.HealthCheckInterval and RedisConfig.HealthCheckInterval) unless a
+// driver error marked it unhealthy in the meantime. Every method honors context cancellation,
+// including while waiting for another goroutine to finish connecting.
+// It is safe for concurrent use by multiple goroutines.
+type Provider struct {
+	mongoConfig func() (MongoConfig, error)
+	redisConfig func() (RedisConfig, error)
+
+	// The configuration of a client is resolved when it is created (or, for injected
+	// clients, with the provider) and kept with it, so requests do not re-read it.
+	mongoSem      chan struct{}
+	mongoClient   *mongo.Client
+	mongoCfg      MongoConfig
+	mongoInjected bool
+	mongoHealth   health
+
+	redisSem      chan struct{}
+	redisClient   redis.UniversalClient
+	redisCfg      RedisConfig
+	redisInjected bool
+	redisHealth   health
+
+	memory *MemoryDatabase
+}
+
+// ErrMemoryDatabase is returned for the MongoDB client and database of a Provider
+// configured with WithMemoryDatabase; use Collection or CollectionFrom instead.
+var ErrMemoryDatabase = errors.New("MongoDB is replaced by an in-memory database")
+
+// ProviderOption configures a Provider.
+type ProviderOption func(*Provider)
+
+// WithMongoConfig uses cfg instead of reading the configuration from the environment.
+func WithMongoConfig(cfg MongoConfig) ProviderOption {
+	return func(p *Provider) {
+		p.mongoConfig = func() (MongoConfig, error) {
+			return cfg, cfg.Validate()
+		}
+	}
+}
+
+// WithRedisConfig uses cfg instead of reading the configuration from the environment.
+func WithRedisConfig(cfg RedisConfig) ProviderOption {
+	return func(p *Provider) {
+		p.redisConfig = func() (RedisConfig, error) {
+			return cfg, cfg.Validate()
+		}
+	}
+}
+
+// WithMongoClient makes the provider hand out client instead of connecting itself.
+// The client is pinged on every use unless a configuration sets a health check
+// interval, and it is never replaced.
+func WithMongoClient(client *mongo.Client) ProviderOption {
+	return func(p *Provider) {
+		p.mongoClient = client
+		p.mongoInjected = true
+	}
+}
+
+// WithRedisClient makes the provider hand out client instead of connecting itself.
+// The client is pinged on every use unless a configuration sets a health check
+// interval, and it is never replaced.
+func WithRedisClient(client redis.UniversalClient) ProviderOption {
+	return func(p *Provider) {
+		p.redisClient = client
+		p.redisInjected = true
+	}
+}
+
+// WithMemoryDatabase replaces MongoDB with database: Collection and CollectionFrom return
+// in-memory repositories, while Mongo and Database fail with ErrMemoryDatabase.
+// It is meant for tests (see package dbtest).
+func WithMemoryDatabase(database *MemoryDatabase) ProviderOption {
+	return func(p *Provider) {
+		p.memory = database
+	}
+}
+
+// NewProvider returns a Provider. Without options, it reads its configuration from
+// the environment (see LoadMongoConfig and LoadRedisConfig) whenever it connects.
+// The configuration of injected clients is read once here; it only supplies their
+// health check settings and database name.
+func NewProvider(opts ...ProviderOption) *Provider {
+	p := &Provider{
+		mongoConfig: LoadMongoConfig,
+		redisConfig: LoadRedisConfig,
+		mongoSem:    make(chan struct{}, 1),
+		redisSem:    make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// Errors are ignored: injected clients are usable without a configuration
+	if p.mongoInjected {
+		cfg, _ := p.mongoConfig()
+		p.mongoCfg = withDatabaseName(cfg)
+	}
+	if p.redisInjected {
+		p.redisCfg, _ = p.redisConfig()
+	}
+
+	return p
+}
+
+var defaultProvider atomic.Pointer[Provider]
+
+func init() {
+	defaultProvider.Store(NewProvider())
+}
+
+// DefaultProvider returns the process-wide Provider used by GetMongoClient and GetRedisClient.
+// It persists across Lambda invocations for connection reuse.
+func DefaultProvider() *Provider {
+	return defaultProvider.Load()
+}
+
+// SwapDefaultProvider makes p the default provider and returns a function restoring the
+// previous one. It is a test hook: GetMongoClient, GetRedisClient, GetDatabase, Collection
+// and every component that falls back to DefaultProvider when it is used pick up p.
+// Components that captured the previous provider keep it.
+func SwapDefaultProvider(p *Provider) (restore func()) {
+	previous := defaultProvider.Swap(p)
+	return func() {
+		defaultProvider.Store(previous)
+	}
+}
+
+// MemoryDatabase returns the database set with WithMemoryDatabase, or nil if the
+// provider uses MongoDB.
+func (p *Provider) MemoryDatabase() *MemoryDatabase {
+	return p.memory
+}
+
+// Database returns the configured MongoDB database (see MongoConfig.DatabaseName).
+func (p *Provider) Database(ctx context.Context) (*mongo.Database, error) {
+	if p.memory != nil {
+		return nil, ErrMemoryDatabase
+	}
+
+	client, cfg, err := p.mongo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := cfg.DatabaseName()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Database(name), nil
+}
+
+// withDatabaseName returns cfg with Database resolved from the URI if it is empty, so
+// Database does not parse the URI on every call. Failures are left to DatabaseName.
+func withDatabaseName(cfg MongoConfig) MongoConfig {
+	if name, err := cfg.DatabaseName(); err == nil {
+		cfg.Database = name
+	}
+	return cfg
+}
+
+// Close disconnects the clients created by the provider. Injected clients are left open.
+func (p *Provider) Close(ctx context.Context) error {
+	var errs []error
+
+	if err := acquire(ctx, p.mongoSem); err != nil {
+		return err
+	}
+	if p.mongoClient != nil && !p.mongoInjected {
+		errs = append(errs, p.mongoClient.Disconnect(ctx))
+		p.mongoClient = nil
+	}
+	release(p.mongoSem)
+
+	if err := acquire(ctx, p.redisSem); err != nil {
+		return err
+	}
+	if p.redisClient != nil && !p.redisInjected {
+		errs = append(errs, p.redisClient.Close())
+		p.redisClient = nil
+	}
+	release(p.redisSem)
+
+	return errors.Join(errs...)
+}
+
+// acquire takes the semaphore, giving up when ctx is done.
+func acquire(ctx context.Context, sem chan struct{}) error {
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release gives back a semaphore taken with acquire.
+func release(sem chan struct{}) {
+	<-sem
+}
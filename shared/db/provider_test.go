@@ -0,0 +1,250 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+)
+
+func TestProvider_CanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := db.NewProvider(
+		db.WithMongoConfig(db.DefaultMongoConfig("mongodb://localhost:27017/test")),
+		db.WithRedisConfig(db.DefaultRedisConfig("redis://localhost:6379")),
+	)
+
+	if _, err := p.Mongo(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Mongo, got '%v'", err)
+	}
+	if _, err := p.Redis(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from Redis, got '%v'", err)
+	}
+}
+
+func TestProvider_RedisFromConfig(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	p := db.NewProvider(db.WithRedisConfig(db.DefaultRedisConfig("redis://" + mr.Addr())))
+	defer p.Close(ctx)
+
+	client, err := p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if err := client.Set(ctx, "key", "value", 0).Err(); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if got, _ := mr.Get("key"); got != "value" {
+		t.Errorf("Expected 'value', got '%s'", got)
+	}
+
+	again, err := p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if again != client {
+		t.Error("Expected the same client on subsequent calls")
+	}
+}
+
+func TestProvider_ResolvesConfigOncePerClient(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	t.Setenv("REDIS_URI", "redis://"+mr.Addr())
+	p := db.NewProvider()
+	defer p.Close(ctx)
+
+	client, err := p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	// A healthy client keeps the configuration it was created with
+	t.Setenv("REDIS_URI", "")
+	again, err := p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected the environment not to be read again, got '%v'", err)
+	}
+	if again != client {
+		t.Error("Expected the same client on subsequent calls")
+	}
+}
+
+func TestProvider_InjectedRedisClient(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	injected := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer injected.Close()
+
+	p := db.NewProvider(db.WithRedisClient(injected))
+
+	client, err := p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if client != injected {
+		t.Error("Expected the injected client")
+	}
+
+	// An unhealthy injected client is reported, not replaced
+	mr.Close()
+	if _, err := p.Redis(ctx); err == nil {
+		t.Error("Expected an error when the injected client is unhealthy")
+	}
+
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Failed to restart miniredis: %v", err)
+	}
+	client, err = p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error after restart, got '%v'", err)
+	}
+	if client != injected {
+		t.Error("Expected the injected client after restart")
+	}
+
+	// Close leaves injected clients open
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if err := injected.Ping(ctx).Err(); err != nil {
+		t.Errorf("Expected the injected client to stay open, got '%v'", err)
+	}
+}
+
+// redisConfigFor returns a configuration for mr with the given health check interval.
+func redisConfigFor(mr *miniredis.Miniredis, interval time.Duration) db.RedisConfig {
+	cfg := db.DefaultRedisConfig("redis://" + mr.Addr())
+	cfg.HealthCheckInterval = interval
+	return cfg
+}
+
+func TestProvider_ZeroPingTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	cfg := redisConfigFor(mr, 0)
+	cfg.PingTimeout = 0
+	p := db.NewProvider(db.WithRedisConfig(cfg))
+	defer p.Close(ctx)
+
+	if _, err := p.Redis(ctx); err != nil {
+		t.Errorf("Expected a zero ping timeout to use the default, got '%v'", err)
+	}
+}
+
+func TestProvider_HealthCheckInterval(t *testing.T) {
+	tests := []struct {
+		name          string
+		interval      time.Duration
+		calls         int
+		expectedPings uint64
+	}{
+		{name: "calls within the interval do not ping", interval: time.Hour, calls: 10, expectedPings: 1},
+		{name: "zero interval pings on every call", interval: 0, calls: 10, expectedPings: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			ctx := context.Background()
+
+			p := db.NewProvider(db.WithRedisConfig(redisConfigFor(mr, tt.interval)))
+			defer p.Close(ctx)
+
+			// The first call connects; count the commands sent after the handshake
+			if _, err := p.Redis(ctx); err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			commands := mr.CommandCount()
+
+			for range tt.calls - 1 {
+				if _, err := p.Redis(ctx); err != nil {
+					t.Fatalf("Expected no error, got '%v'", err)
+				}
+			}
+
+			stats := p.Stats().Redis
+			if stats.Pings != tt.expectedPings {
+				t.Errorf("Expected %d pings, got %d", tt.expectedPings, stats.Pings)
+			}
+			if got := uint64(mr.CommandCount() - commands); got != tt.expectedPings-1 {
+				t.Errorf("Expected %d commands to reach Redis after connecting, got %d", tt.expectedPings-1, got)
+			}
+			if stats.Connects != 1 || stats.Reconnects != 0 {
+				t.Errorf("Expected a single connect, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestProvider_MarkRedisUnhealthy(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	p := db.NewProvider(db.WithRedisConfig(redisConfigFor(mr, time.Hour)))
+	defer p.Close(ctx)
+
+	if _, err := p.Redis(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	p.MarkRedisUnhealthy()
+	if _, err := p.Redis(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if _, err := p.Redis(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	if pings := p.Stats().Redis.Pings; pings != 2 {
+		t.Errorf("Expected a single extra ping after marking the client unhealthy, got %d pings", pings)
+	}
+}
+
+func TestProvider_ReconnectsAfterDriverError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	p := db.NewProvider(db.WithRedisConfig(redisConfigFor(mr, time.Hour)))
+	defer p.Close(ctx)
+
+	client, err := p.Redis(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	// A failing command marks the client unhealthy even within the interval
+	mr.Close()
+	if err := client.Get(ctx, "key").Err(); err == nil {
+		t.Fatal("Expected an error while Redis is down")
+	}
+	if _, err := p.Redis(ctx); err == nil {
+		t.Fatal("Expected an error while Redis is down")
+	}
+
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("Failed to restart miniredis: %v", err)
+	}
+	if _, err := p.Redis(ctx); err != nil {
+		t.Fatalf("Expected no error after restart, got '%v'", err)
+	}
+
+	stats := p.Stats().Redis
+	if stats.Connects != 2 || stats.Reconnects != 1 {
+		t.Errorf("Expected 2 connects and 1 reconnect, got %+v", stats)
+	}
+	if stats.PingFailures == 0 {
+		t.Errorf("Expected failed pings to be counted, got %+v", stats)
+	}
+}
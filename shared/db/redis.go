@@ -3,58 +3,122 @@ package db
 import (
 	"context"
 	"fmt"
-	"os"
-	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-var (
-	redisClient *redis.Client
-	redisMutex  sync.Mutex
-)
-
 // GetRedisClient returns a singleton Redis client instance optimized for AWS Lambda.
-// It reads the Redis URI from the REDIS_URI environment variable.
+// It reads its configuration from the environment (see LoadRedisConfig) and supports
+// standalone, Cluster and Sentinel topologies behind the redis.UniversalClient interface.
 // The client persists across Lambda invocations for connection reuse.
 // Automatically handles health checking and reconnection transparently.
-func GetRedisClient() (*redis.Client, error) {
-	redisMutex.Lock()
-	defer redisMutex.Unlock()
+// Every command is traced as a child span of its context (see RedisTracingHook).
+// It is a shorthand for DefaultProvider().Redis(context.Background()).
+func GetRedisClient() (redis.UniversalClient, error) {
+	return DefaultProvider().Redis(context.Background())
+}
+
+// Redis returns the provider's Redis client, connecting on first use.
+// An existing client is pinged when its health check is due and replaced if it is unhealthy.
+func (p *Provider) Redis(ctx context.Context) (redis.UniversalClient, error) {
+	if err := acquire(ctx, p.redisSem); err != nil {
+		return nil, err
+	}
+	defer release(p.redisSem)
 
 	// If we have a client, check if it's still healthy
-	if redisClient != nil {
-		if _, err := redisClient.Ping(context.Background()).Result(); err != nil {
-			// Client is unhealthy, close and reset
-			redisClient.Close()
-			redisClient = nil
-		} else {
+	if p.redisClient != nil {
+		cfg := p.redisCfg
+		if !p.redisHealth.due(cfg.HealthCheckInterval) {
+			return p.redisClient, nil
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, orDefault(cfg.PingTimeout, DefaultRedisPingTimeout))
+		defer cancel()
+
+		err := p.redisHealth.ping(func() error { return p.redisClient.Ping(pingCtx).Err() })
+		switch {
+		case err == nil:
 			// Client is healthy, return it
-			return redisClient, nil
+			return p.redisClient, nil
+		case ctx.Err() != nil:
+			// The caller gave up; that says nothing about the client's health
+			return nil, ctx.Err()
+		case p.redisInjected:
+			return nil, fmt.Errorf("failed to ping Redis: %w", err)
+		default:
+			// Client is unhealthy, close and reset
+			p.redisClient.Close()
+			p.redisClient = nil
 		}
 	}
 
 	// Need to create new client
-	uri := os.Getenv("REDIS_URI")
-	if uri == "" {
-		return nil, fmt.Errorf("REDIS_URI environment variable not set")
+	cfg, err := p.redisConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	opt, err := redis.ParseURL(uri)
+	client, err := cfg.NewClient()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse REDIS_URI: %w", err)
+		return nil, err
 	}
 
-	// Use Redis client defaults for connection timeouts
-
-	client := redis.NewClient(opt)
-
 	// Verify connection with ping
-	if _, err := client.Ping(context.Background()).Result(); err != nil {
+	pingCtx, cancel := context.WithTimeout(ctx, orDefault(cfg.PingTimeout, DefaultRedisPingTimeout))
+	defer cancel()
+
+	if err := p.redisHealth.ping(func() error { return client.Ping(pingCtx).Err() }); err != nil {
 		client.Close()
 		return nil, fmt.Errorf("failed to ping Redis: %w", err)
 	}
 
-	redisClient = client
-	return redisClient, nil
+	client.AddHook(redisHealthHook{health: &p.redisHealth})
+	client.AddHook(RedisTracingHook())
+	p.redisClient = client
+	p.redisCfg = cfg
+	p.redisHealth.connected()
+	return p.redisClient, nil
+}
+
+// compareAndDeleteScript deletes KEYS[1] only if it holds ARGV[1] and returns the number
+// of keys deleted.
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// CompareAndDelete atomically deletes key only if it still holds value, so an owner never
+// deletes a lock or marker that expired and was set again by someone else. It reports
+// whether the key was deleted.
+func CompareAndDelete(ctx context.Context, client redis.Scripter, key, value string) (bool, error) {
+	deleted, err := compareAndDeleteScript.Run(ctx, client, []string{key}, value).Int64()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// compareAndSetScript sets KEYS[1] to ARGV[2] with a TTL of ARGV[3] milliseconds only if
+// it holds ARGV[1], and returns 1 if it did.
+var compareAndSetScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// CompareAndSet atomically replaces the value of key with value only if it still holds
+// old, so an owner never overwrites a lock or marker that expired and was set again by
+// someone else. It reports whether the key was replaced. ttl must be at least 1ms.
+func CompareAndSet(ctx context.Context, client redis.Scripter, key, old, value string, ttl time.Duration) (bool, error) {
+	set, err := compareAndSetScript.Run(ctx, client, []string{key}, old, value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return set == 1, nil
 }
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis topologies supported by RedisConfig.
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeCluster    = "cluster"
+	RedisModeSentinel   = "sentinel"
+)
+
+// Default Redis client settings, used for unset REDIS_* variables.
+const (
+	DefaultRedisPoolSize            = 5
+	DefaultRedisDialTimeout         = 5 * time.Second
+	DefaultRedisReadTimeout         = 3 * time.Second
+	DefaultRedisWriteTimeout        = 3 * time.Second
+	DefaultRedisPingTimeout         = 5 * time.Second
+	DefaultRedisHealthCheckInterval = 30 * time.Second
+)
+
+// RedisConfig holds the Redis client configuration.
+type RedisConfig struct {
+	// URI is the connection string (REDIS_URI). Options set in the URI take
+	// precedence over the fields below. Cluster URIs list extra nodes with the
+	// "addr" query parameter; Sentinel URIs point at the sentinels and set "master_name".
+	URI string
+	// Mode is standalone, cluster or sentinel (REDIS_MODE). Empty detects the
+	// mode from the URI: "master_name" means sentinel, "addr" means cluster.
+	Mode string
+	// PoolSize is the maximum number of connections per node (REDIS_POOL_SIZE).
+	PoolSize int
+	// DialTimeout bounds opening a connection (REDIS_DIAL_TIMEOUT).
+	DialTimeout time.Duration
+	// ReadTimeout bounds socket reads (REDIS_READ_TIMEOUT).
+	ReadTimeout time.Duration
+	// WriteTimeout bounds socket writes (REDIS_WRITE_TIMEOUT).
+	WriteTimeout time.Duration
+	// PingTimeout bounds the health check ping (REDIS_PING_TIMEOUT). Zero uses DefaultRedisPingTimeout.
+	PingTimeout time.Duration
+	// HealthCheckInterval is how long a successful health check is trusted before the
+	// client is pinged again (REDIS_HEALTH_CHECK_INTERVAL). Zero pings on every use.
+	HealthCheckInterval time.Duration
+	// TLS enables TLS even for redis:// URIs (REDIS_TLS). rediss:// URIs always use TLS.
+	TLS bool
+	// TLSServerName overrides the server name used to verify the certificate (REDIS_TLS_SERVER_NAME).
+	TLSServerName string
+	// TLSInsecureSkipVerify disables certificate verification (REDIS_TLS_INSECURE_SKIP_VERIFY).
+	TLSInsecureSkipVerify bool
+}
+
+// DefaultRedisConfig returns the Lambda-friendly defaults for the given URI.
+func DefaultRedisConfig(uri string) RedisConfig {
+	return RedisConfig{
+		URI:                 uri,
+		PoolSize:            DefaultRedisPoolSize,
+		DialTimeout:         DefaultRedisDialTimeout,
+		ReadTimeout:         DefaultRedisReadTimeout,
+		WriteTimeout:        DefaultRedisWriteTimeout,
+		PingTimeout:         DefaultRedisPingTimeout,
+		HealthCheckInterval: DefaultRedisHealthCheckInterval,
+	}
+}
+
+// LoadRedisConfig reads the Redis configuration from the environment, applying
+// DefaultRedisConfig for variables that are not set. All invalid values are
+// reported together in the returned error.
+func LoadRedisConfig() (RedisConfig, error) {
+	uri := os.Getenv("REDIS_URI")
+	if uri == "" {
+		return RedisConfig{}, fmt.Errorf("REDIS_URI environment variable not set")
+	}
+
+	cfg := DefaultRedisConfig(uri)
+	var errs []error
+
+	cfg.Mode = os.Getenv("REDIS_MODE")
+	parseEnv(&errs, "REDIS_POOL_SIZE", &cfg.PoolSize, parseInt)
+	parseEnv(&errs, "REDIS_DIAL_TIMEOUT", &cfg.DialTimeout, time.ParseDuration)
+	parseEnv(&errs, "REDIS_READ_TIMEOUT", &cfg.ReadTimeout, time.ParseDuration)
+	parseEnv(&errs, "REDIS_WRITE_TIMEOUT", &cfg.WriteTimeout, time.ParseDuration)
+	parseEnv(&errs, "REDIS_PING_TIMEOUT", &cfg.PingTimeout, time.ParseDuration)
+	parseEnv(&errs, "REDIS_HEALTH_CHECK_INTERVAL", &cfg.HealthCheckInterval, time.ParseDuration)
+	parseEnv(&errs, "REDIS_TLS", &cfg.TLS, strconv.ParseBool)
+	parseEnv(&errs, "REDIS_TLS_INSECURE_SKIP_VERIFY", &cfg.TLSInsecureSkipVerify, strconv.ParseBool)
+	cfg.TLSServerName = os.Getenv("REDIS_TLS_SERVER_NAME")
+
+	if len(errs) > 0 {
+		return RedisConfig{}, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return RedisConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that the configuration values are consistent.
+func (c RedisConfig) Validate() error {
+	var errs []error
+
+	if c.URI == "" {
+		errs = append(errs, fmt.Errorf("REDIS_URI must not be empty"))
+	}
+	switch c.Mode {
+	case "", RedisModeStandalone, RedisModeCluster, RedisModeSentinel:
+	default:
+		errs = append(errs, fmt.Errorf("invalid REDIS_MODE %q: must be standalone, cluster or sentinel", c.Mode))
+	}
+	if c.PoolSize < 0 {
+		errs = append(errs, fmt.Errorf("REDIS_POOL_SIZE must not be negative"))
+	}
+	for name, d := range map[string]time.Duration{
+		"REDIS_DIAL_TIMEOUT":          c.DialTimeout,
+		"REDIS_READ_TIMEOUT":          c.ReadTimeout,
+		"REDIS_WRITE_TIMEOUT":         c.WriteTimeout,
+		"REDIS_PING_TIMEOUT":          c.PingTimeout,
+		"REDIS_HEALTH_CHECK_INTERVAL": c.HealthCheckInterval,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DetectMode returns the configured mode, or the mode implied by the URI if none is set.
+func (c RedisConfig) DetectMode() string {
+	if c.Mode != "" {
+		return c.Mode
+	}
+
+	u, err := url.Parse(c.URI)
+	if err != nil {
+		return RedisModeStandalone
+	}
+
+	query := u.Query()
+	switch {
+	case query.Has("master_name"):
+		return RedisModeSentinel
+	case query.Has("addr"):
+		return RedisModeCluster
+	default:
+		return RedisModeStandalone
+	}
+}
+
+// NewClient creates a client for the configured topology. It does not connect;
+// go-redis dials lazily on the first command.
+func (c RedisConfig) NewClient() (redis.UniversalClient, error) {
+	switch c.DetectMode() {
+	case RedisModeCluster:
+		opt, err := redis.ParseClusterURL(c.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse REDIS_URI: %w", err)
+		}
+		opt.PoolSize = orDefault(opt.PoolSize, c.PoolSize)
+		opt.DialTimeout = orDefault(opt.DialTimeout, c.DialTimeout)
+		opt.ReadTimeout = orDefault(opt.ReadTimeout, c.ReadTimeout)
+		opt.WriteTimeout = orDefault(opt.WriteTimeout, c.WriteTimeout)
+		opt.TLSConfig = c.tlsConfig(opt.TLSConfig)
+		return redis.NewClusterClient(opt), nil
+
+	case RedisModeSentinel:
+		opt, err := redis.ParseFailoverURL(c.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse REDIS_URI: %w", err)
+		}
+		opt.PoolSize = orDefault(opt.PoolSize, c.PoolSize)
+		opt.DialTimeout = orDefault(opt.DialTimeout, c.DialTimeout)
+		opt.ReadTimeout = orDefault(opt.ReadTimeout, c.ReadTimeout)
+		opt.WriteTimeout = orDefault(opt.WriteTimeout, c.WriteTimeout)
+		opt.TLSConfig = c.tlsConfig(opt.TLSConfig)
+		return redis.NewFailoverClient(opt), nil
+
+	default:
+		opt, err := redis.ParseURL(c.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse REDIS_URI: %w", err)
+		}
+		opt.PoolSize = orDefault(opt.PoolSize, c.PoolSize)
+		opt.DialTimeout = orDefault(opt.DialTimeout, c.DialTimeout)
+		opt.ReadTimeout = orDefault(opt.ReadTimeout, c.ReadTimeout)
+		opt.WriteTimeout = orDefault(opt.WriteTimeout, c.WriteTimeout)
+		opt.TLSConfig = c.tlsConfig(opt.TLSConfig)
+		return redis.NewClient(opt), nil
+	}
+}
+
+// tlsConfig applies the TLS settings to the TLS configuration parsed from the URI.
+func (c RedisConfig) tlsConfig(parsed *tls.Config) *tls.Config {
+	if parsed == nil && !c.TLS {
+		return nil
+	}
+
+	cfg := parsed
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if c.TLSServerName != "" {
+		cfg.ServerName = c.TLSServerName
+	}
+	if c.TLSInsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg
+}
+
+// orDefault returns value unless it is the zero value, in which case it returns fallback.
+func orDefault[T comparable](value, fallback T) T {
+	var zero T
+	if value == zero {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,214 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+)
+
+// redisConfigEnv lists every variable read by LoadRedisConfig.
+var redisConfigEnv = []string{
+	"REDIS_URI",
+	"REDIS_MODE",
+	"REDIS_POOL_SIZE",
+	"REDIS_DIAL_TIMEOUT",
+	"REDIS_READ_TIMEOUT",
+	"REDIS_WRITE_TIMEOUT",
+	"REDIS_PING_TIMEOUT",
+	"REDIS_HEALTH_CHECK_INTERVAL",
+	"REDIS_TLS",
+	"REDIS_TLS_SERVER_NAME",
+	"REDIS_TLS_INSECURE_SKIP_VERIFY",
+}
+
+func setRedisConfigEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for _, name := range redisConfigEnv {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestLoadRedisConfig_Defaults(t *testing.T) {
+	setRedisConfigEnv(t, map[string]string{"REDIS_URI": "redis://localhost:6379"})
+
+	cfg, err := db.LoadRedisConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := db.DefaultRedisConfig("redis://localhost:6379")
+	if cfg != expected {
+		t.Errorf("Expected defaults %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestLoadRedisConfig_FromEnvironment(t *testing.T) {
+	setRedisConfigEnv(t, map[string]string{
+		"REDIS_URI":                      "redis://localhost:6379",
+		"REDIS_MODE":                     "cluster",
+		"REDIS_POOL_SIZE":                "20",
+		"REDIS_DIAL_TIMEOUT":             "1s",
+		"REDIS_READ_TIMEOUT":             "250ms",
+		"REDIS_WRITE_TIMEOUT":            "500ms",
+		"REDIS_PING_TIMEOUT":             "2s",
+		"REDIS_HEALTH_CHECK_INTERVAL":    "10s",
+		"REDIS_TLS":                      "true",
+		"REDIS_TLS_SERVER_NAME":          "cache.internal",
+		"REDIS_TLS_INSECURE_SKIP_VERIFY": "true",
+	})
+
+	cfg, err := db.LoadRedisConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	expected := db.RedisConfig{
+		URI:                   "redis://localhost:6379",
+		Mode:                  db.RedisModeCluster,
+		PoolSize:              20,
+		DialTimeout:           time.Second,
+		ReadTimeout:           250 * time.Millisecond,
+		WriteTimeout:          500 * time.Millisecond,
+		PingTimeout:           2 * time.Second,
+		HealthCheckInterval:   10 * time.Second,
+		TLS:                   true,
+		TLSServerName:         "cache.internal",
+		TLSInsecureSkipVerify: true,
+	}
+	if cfg != expected {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestLoadRedisConfig_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		contains []string
+	}{
+		{"missing URI", map[string]string{"REDIS_URI": ""}, []string{"REDIS_URI environment variable not set"}},
+		{"bad mode", map[string]string{"REDIS_MODE": "replica"}, []string{"invalid REDIS_MODE"}},
+		{"bad pool size", map[string]string{"REDIS_POOL_SIZE": "big"}, []string{"invalid REDIS_POOL_SIZE"}},
+		{"negative pool size", map[string]string{"REDIS_POOL_SIZE": "-1"}, []string{"REDIS_POOL_SIZE must not be negative"}},
+		{"bad timeout", map[string]string{"REDIS_READ_TIMEOUT": "soon"}, []string{"invalid REDIS_READ_TIMEOUT"}},
+		{"negative timeout", map[string]string{"REDIS_DIAL_TIMEOUT": "-1s"}, []string{"REDIS_DIAL_TIMEOUT must not be negative"}},
+		{"bad TLS flag", map[string]string{"REDIS_TLS": "maybe"}, []string{"invalid REDIS_TLS"}},
+		{
+			"multiple errors reported together",
+			map[string]string{"REDIS_POOL_SIZE": "x", "REDIS_WRITE_TIMEOUT": "y"},
+			[]string{"invalid REDIS_POOL_SIZE", "invalid REDIS_WRITE_TIMEOUT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"REDIS_URI": "redis://localhost:6379"}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			setRedisConfigEnv(t, env)
+
+			_, err := db.LoadRedisConfig()
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain '%s', got '%s'", want, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestRedisConfig_DetectMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      db.RedisConfig
+		expected string
+	}{
+		{"single node", db.RedisConfig{URI: "redis://localhost:6379/0"}, db.RedisModeStandalone},
+		{"cluster nodes", db.RedisConfig{URI: "redis://node1:6379?addr=node2:6379&addr=node3:6379"}, db.RedisModeCluster},
+		{"sentinel", db.RedisConfig{URI: "redis://sentinel1:26379?master_name=mymaster&addr=sentinel2:26379"}, db.RedisModeSentinel},
+		{"explicit mode wins", db.RedisConfig{URI: "redis://localhost:6379", Mode: db.RedisModeCluster}, db.RedisModeCluster},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if mode := tt.cfg.DetectMode(); mode != tt.expected {
+				t.Errorf("Expected mode %s, got %s", tt.expected, mode)
+			}
+		})
+	}
+}
+
+func TestRedisConfig_NewClient(t *testing.T) {
+	t.Run("standalone", func(t *testing.T) {
+		cfg := db.DefaultRedisConfig("redis://localhost:6379/2?pool_size=7")
+		cfg.TLS = true
+		cfg.TLSServerName = "cache.internal"
+
+		client, err := cfg.NewClient()
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		defer client.Close()
+
+		single, ok := client.(*redis.Client)
+		if !ok {
+			t.Fatalf("Expected *redis.Client, got %T", client)
+		}
+		opt := single.Options()
+		if opt.DB != 2 || opt.PoolSize != 7 {
+			t.Errorf("Expected URI options to be kept, got DB %d and pool size %d", opt.DB, opt.PoolSize)
+		}
+		if opt.ReadTimeout != db.DefaultRedisReadTimeout || opt.DialTimeout != db.DefaultRedisDialTimeout {
+			t.Errorf("Expected configured timeouts, got read %s and dial %s", opt.ReadTimeout, opt.DialTimeout)
+		}
+		if opt.TLSConfig == nil || opt.TLSConfig.ServerName != "cache.internal" {
+			t.Errorf("Expected TLS with server name cache.internal, got %+v", opt.TLSConfig)
+		}
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client, err := db.DefaultRedisConfig("redis://node1:6379?addr=node2:6379").NewClient()
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		defer client.Close()
+
+		cluster, ok := client.(*redis.ClusterClient)
+		if !ok {
+			t.Fatalf("Expected *redis.ClusterClient, got %T", client)
+		}
+		if addrs := cluster.Options().Addrs; len(addrs) != 2 {
+			t.Errorf("Expected 2 cluster nodes, got %v", addrs)
+		}
+		if cluster.Options().PoolSize != db.DefaultRedisPoolSize {
+			t.Errorf("Expected pool size %d, got %d", db.DefaultRedisPoolSize, cluster.Options().PoolSize)
+		}
+	})
+
+	t.Run("sentinel", func(t *testing.T) {
+		client, err := db.DefaultRedisConfig("redis://sentinel1:26379?master_name=mymaster").NewClient()
+		if err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+		defer client.Close()
+
+		if _, ok := client.(*redis.Client); !ok {
+			t.Fatalf("Expected a failover *redis.Client, got %T", client)
+		}
+	})
+
+	t.Run("invalid URI", func(t *testing.T) {
+		_, err := db.DefaultRedisConfig("not-a-valid-redis-uri").NewClient()
+		if err == nil || !strings.Contains(err.Error(), "failed to parse REDIS_URI") {
+			t.Errorf("Expected parse error, got '%v'", err)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"os"
+
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Page describes which slice of the matching documents List returns.
+// A zero Limit returns every document after Skip.
+type Page struct {
+	Limit int64
+	Skip  int64
+	Sort  bson.D
+}
+
+// Index describes an index on a collection.
+type Index struct {
+	Keys   bson.D
+	Unique bool
+}
+
+// Repository provides typed access to the documents of a single collection.
+// Errors are classified with apperrors, so a missing document is reported as
+// apperrors.CodeNotFound and a duplicate key as apperrors.CodeConflict.
+type Repository[T any] interface {
+	// FindByID returns the document whose _id equals id.
+	FindByID(ctx context.Context, id any) (*T, error)
+	// FindOne returns the first document matching filter.
+	FindOne(ctx context.Context, filter bson.M) (*T, error)
+	// Insert inserts doc and returns its _id.
+	Insert(ctx context.Context, doc *T) (any, error)
+	// Upsert replaces the first document matching filter with doc, or inserts doc if none matches.
+	Upsert(ctx context.Context, filter bson.M, doc *T) error
+	// Update applies the update operators to every document matching filter and returns the number matched.
+	Update(ctx context.Context, filter bson.M, update bson.M) (int64, error)
+	// FindOneAndUpdate atomically applies the update operators to the first document matching
+	// filter and returns the updated document. If upsert is true and no document matches, a new
+	// document is created from the equality conditions of filter and the update, including
+	// $setOnInsert fields.
+	FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, upsert bool) (*T, error)
+	// Delete removes every document matching filter and returns the number deleted.
+	Delete(ctx context.Context, filter bson.M) (int64, error)
+	// List returns the documents matching filter within the given page.
+	List(ctx context.Context, filter bson.M, page Page) ([]T, error)
+	// Count returns the number of documents matching filter.
+	Count(ctx context.Context, filter bson.M) (int64, error)
+	// EnsureIndex creates the index if it does not exist yet.
+	EnsureIndex(ctx context.Context, index Index) error
+}
+
+// Compile-time check to ensure MongoRepository implements Repository
+var _ Repository[bson.M] = (*MongoRepository[bson.M])(nil)
+
+// MongoRepository is a Repository backed by a MongoDB collection.
+type MongoRepository[T any] struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRepository returns a Repository for the given collection.
+func NewMongoRepository[T any](collection *mongo.Collection) *MongoRepository[T] {
+	return &MongoRepository[T]{collection: collection}
+}
+
+// Collection returns a Repository for the named collection in the configured database.
+// It uses the default provider (see DefaultProvider) and MongoConfig.DatabaseName.
+func Collection[T any](name string) (Repository[T], error) {
+	return CollectionFrom[T](context.Background(), DefaultProvider(), name)
+}
+
+// memoryBacked is implemented by Clients that may replace MongoDB with a MemoryDatabase,
+// such as Provider. MemoryDatabase returns nil if they use MongoDB.
+type memoryBacked interface {
+	MemoryDatabase() *MemoryDatabase
+}
+
+// CollectionFrom returns a Repository for the named collection in the database of clients.
+// It is a MemoryRepository if clients use a MemoryDatabase (see WithMemoryDatabase).
+func CollectionFrom[T any](ctx context.Context, clients Clients, name string) (Repository[T], error) {
+	if m, ok := clients.(memoryBacked); ok {
+		if database := m.MemoryDatabase(); database != nil {
+			if err := database.open(); err != nil {
+				return nil, err
+			}
+			return MemoryCollection[T](database, name), nil
+		}
+	}
+
+	database, err := clients.Database(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMongoRepository[T](database.Collection(name)), nil
+}
+
+// GetDatabase returns the configured database on the shared MongoDB client.
+// It is a shorthand for DefaultProvider().Database(context.Background()).
+func GetDatabase() (*mongo.Database, error) {
+	return DefaultProvider().Database(context.Background())
+}
+
+// DatabaseName returns the name of the database functions should use.
+// The MONGODB_DATABASE environment variable takes precedence over the
+// database in the path of MONGODB_URI (e.g. mongodb://host:27017/mlgmr).
+func DatabaseName() (string, error) {
+	return MongoConfig{
+		URI:      os.Getenv("MONGODB_URI"),
+		Database: os.Getenv("MONGODB_DATABASE"),
+	}.DatabaseName()
+}
+
+// Collection returns the underlying MongoDB collection.
+func (r *MongoRepository[T]) Collection() *mongo.Collection {
+	return r.collection
+}
+
+// FindByID returns the document whose _id equals id.
+func (r *MongoRepository[T]) FindByID(ctx context.Context, id any) (*T, error) {
+	return r.FindOne(ctx, bson.M{"_id": id})
+}
+
+// FindOne returns the first document matching filter.
+func (r *MongoRepository[T]) FindOne(ctx context.Context, filter bson.M) (*T, error) {
+	var doc T
+	if err := r.collection.FindOne(ctx, filter).Decode(&doc); err != nil {
+		return nil, apperrors.FromMongo(err)
+	}
+	return &doc, nil
+}
+
+// Insert inserts doc and returns its _id.
+func (r *MongoRepository[T]) Insert(ctx context.Context, doc *T) (any, error) {
+	result, err := r.collection.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, apperrors.FromMongo(err)
+	}
+	return result.InsertedID, nil
+}
+
+// Upsert replaces the first document matching filter with doc, or inserts doc if none matches.
+func (r *MongoRepository[T]) Upsert(ctx context.Context, filter bson.M, doc *T) error {
+	_, err := r.collection.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	return apperrors.FromMongo(err)
+}
+
+// Update applies the update operators to every document matching filter and returns the number matched.
+func (r *MongoRepository[T]) Update(ctx context.Context, filter bson.M, update bson.M) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, apperrors.FromMongo(err)
+	}
+	return result.MatchedCount, nil
+}
+
+// FindOneAndUpdate atomically applies the update to the first document matching filter
+// and returns the document after the update, optionally inserting it if none matches.
+func (r *MongoRepository[T]) FindOneAndUpdate(ctx context.Context, filter bson.M, update bson.M, upsert bool) (*T, error) {
+	opts := options.FindOneAndUpdate().
+		SetUpsert(upsert).
+		SetReturnDocument(options.After)
+
+	var doc T
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc); err != nil {
+		return nil, apperrors.FromMongo(err)
+	}
+	return &doc, nil
+}
+
+// Delete removes every document matching filter and returns the number deleted.
+func (r *MongoRepository[T]) Delete(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, apperrors.FromMongo(err)
+	}
+	return result.DeletedCount, nil
+}
+
+// List returns the documents matching filter within the given page.
+func (r *MongoRepository[T]) List(ctx context.Context, filter bson.M, page Page) ([]T, error) {
+	opts := options.Find()
+	if page.Limit > 0 {
+		opts.SetLimit(page.Limit)
+	}
+	if page.Skip > 0 {
+		opts.SetSkip(page.Skip)
+	}
+	if len(page.Sort) > 0 {
+		opts.SetSort(page.Sort)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, apperrors.FromMongo(err)
+	}
+
+	docs := []T{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, apperrors.FromMongo(err)
+	}
+	return docs, nil
+}
+
+// Count returns the number of documents matching filter.
+func (r *MongoRepository[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, apperrors.FromMongo(err)
+	}
+	return count, nil
+}
+
+// EnsureIndex creates the index if it does not exist yet.
+func (r *MongoRepository[T]) EnsureIndex(ctx context.Context, index Index) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    index.Keys,
+		Options: options.Index().SetUnique(index.Unique),
+	})
+	return apperrors.FromMongo(err)
+}
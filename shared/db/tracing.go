@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/tracing"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// mongoSpanKey identifies an in-flight command; request IDs are unique per connection.
+type mongoSpanKey struct {
+	connectionID string
+	requestID    int64
+}
+
+// MongoCommandMonitor returns a command monitor recording a client span for every
+// MongoDB command, as a child of the span in the command's context (see tracing.Tracer).
+// Clients created by a Provider use it; add it to injected clients with
+// options.Client().SetMonitor. Command bodies are not recorded, since they may contain
+// personal data.
+func MongoCommandMonitor() *event.CommandMonitor {
+	var spans sync.Map
+
+	end := func(finished event.CommandFinishedEvent, failure error) {
+		value, ok := spans.LoadAndDelete(mongoSpanKey{finished.ConnectionID, finished.RequestID})
+		if !ok {
+			return
+		}
+
+		span := value.(trace.Span)
+		if failure != nil {
+			span.RecordError(failure)
+			span.SetStatus(codes.Error, failure.Error())
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemNameMongoDB,
+				semconv.DBOperationName(e.CommandName),
+				semconv.DBNamespace(e.DatabaseName),
+			}
+
+			name := e.CommandName
+			// The value of the command field is the collection for CRUD commands
+			if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				attrs = append(attrs, semconv.DBCollectionName(collection))
+				name += " " + collection
+			}
+			if host, _, ok := strings.Cut(e.ConnectionID, "["); ok {
+				attrs = append(attrs, semconv.ServerAddress(host))
+			}
+
+			_, span := tracing.Tracer().Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			spans.Store(mongoSpanKey{e.ConnectionID, e.RequestID}, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.CommandFinishedEvent, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.CommandFinishedEvent, e.Failure)
+		},
+	}
+}
+
+// redisTracingHook records a client span for every Redis command and pipeline.
+type redisTracingHook struct{}
+
+// Compile-time check to ensure redisTracingHook implements redis.Hook
+var _ redis.Hook = redisTracingHook{}
+
+// RedisTracingHook returns a hook recording a client span for every Redis command and
+// pipeline, as a child of the span in the command's context (see tracing.Tracer).
+// Clients created by a Provider use it; add it to injected clients with AddHook.
+// Arguments are not recorded, since they may contain personal data.
+func RedisTracingHook() redis.Hook {
+	return redisTracingHook{}
+}
+
+func (redisTracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (redisTracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Tracer().Start(ctx, cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemNameRedis,
+				semconv.DBOperationName(cmd.Name()),
+			),
+		)
+		defer span.End()
+
+		err := next(ctx, cmd)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+func (redisTracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := tracing.Tracer().Start(ctx, "pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemNameRedis,
+				semconv.DBOperationName("pipeline"),
+				semconv.DBOperationBatchSize(len(cmds)),
+			),
+		)
+		defer span.End()
+
+		err := next(ctx, cmds)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+// recordRedisError marks the span as failed. redis.Nil only means the key is missing.
+func recordRedisError(span trace.Span, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
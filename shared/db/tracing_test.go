@@ -0,0 +1,138 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/tracing"
+	"github.com/xarunoba/mlgmr/shared/tracing/tracingtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// inMemoryTracing records the spans of the global tracer provider for the test.
+func inMemoryTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	return tracingtest.Install(t)
+}
+
+// spanAttr returns the value of the named span attribute.
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestRedisTracingHook(t *testing.T) {
+	exporter := inMemoryTracing(t)
+	mr := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	client.AddHook(db.RedisTracingHook())
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "handler")
+
+	client.Set(ctx, "greeting", "hello", 0)
+	if err := client.Get(ctx, "missing").Err(); !errors.Is(err, redis.Nil) {
+		t.Fatalf("Expected redis.Nil, got '%v'", err)
+	}
+	client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, "counter")
+		pipe.Incr(ctx, "counter")
+		return nil
+	})
+	client.Do(ctx, "NOSUCHCOMMAND")
+	parent.End()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	for _, name := range []string{"set", "get", "pipeline", "nosuchcommand"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("Expected a span named '%s', got %v", name, spans)
+		}
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected span '%s' to be a child of the handler span", name)
+		}
+		if system := spanAttr(span, "db.system.name").AsString(); system != "redis" {
+			t.Errorf("Expected db.system.name 'redis' on span '%s', got '%s'", name, system)
+		}
+	}
+
+	if spans["get"].Status.Code == codes.Error {
+		t.Error("Expected a missing key not to mark the span as failed")
+	}
+	if spans["nosuchcommand"].Status.Code != codes.Error {
+		t.Error("Expected a command error to mark the span as failed")
+	}
+	if size := spanAttr(spans["pipeline"], "db.operation.batch.size").AsInt64(); size != 2 {
+		t.Errorf("Expected pipeline batch size 2, got %d", size)
+	}
+}
+
+func TestMongoCommandMonitor(t *testing.T) {
+	exporter := inMemoryTracing(t)
+	monitor := db.MongoCommandMonitor()
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "handler")
+
+	command, err := bson.Marshal(bson.D{{Key: "find", Value: "name"}, {Key: "filter", Value: bson.D{{Key: "name", Value: "Ada"}}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	finished := event.CommandFinishedEvent{CommandName: "find", DatabaseName: "mlgmr", RequestID: 1, ConnectionID: "localhost:27017[-1]"}
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: command, CommandName: "find", DatabaseName: "mlgmr", RequestID: 1, ConnectionID: "localhost:27017[-1]"})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+
+	failed := finished
+	failed.RequestID = 2
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: command, CommandName: "find", DatabaseName: "mlgmr", RequestID: 2, ConnectionID: "localhost:27017[-1]"})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: failed, Failure: errors.New("connection reset")})
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+
+	succeeded, failedSpan := spans[0], spans[1]
+	if succeeded.Name != "find name" {
+		t.Errorf("Expected span name 'find name', got '%s'", succeeded.Name)
+	}
+	if succeeded.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Expected the command span to be a child of the handler span")
+	}
+
+	expected := map[attribute.Key]string{
+		"db.system.name":     "mongodb",
+		"db.operation.name":  "find",
+		"db.namespace":       "mlgmr",
+		"db.collection.name": "name",
+		"server.address":     "localhost:27017",
+	}
+	for key, value := range expected {
+		if got := spanAttr(succeeded, key).AsString(); got != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, got)
+		}
+	}
+
+	if failedSpan.Status.Code != codes.Error || failedSpan.Status.Description != "connection reset" {
+		t.Errorf("Expected the failed command to mark the span as failed, got %v", failedSpan.Status)
+	}
+}
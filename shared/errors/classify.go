@@ -0,0 +1,73 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Classify converts any error into an *Error.
+// Typed errors are returned as-is; known MongoDB, Redis, network and context
+// errors are mapped to their matching code (deadlines to CodeTimeout, connection
+// failures to CodeUnavailable); anything else becomes CodeInternal.
+// The original error is kept as the cause. Classify returns nil for a nil error.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	if appErr, ok := As(err); ok {
+		return appErr
+	}
+
+	switch {
+	case stderrors.Is(err, mongo.ErrNoDocuments):
+		return Wrap(err, CodeNotFound, "document not found")
+	case stderrors.Is(err, redis.Nil):
+		return Wrap(err, CodeNotFound, "key not found")
+	case mongo.IsDuplicateKeyError(err):
+		return Wrap(err, CodeConflict, "document already exists")
+	case stderrors.Is(err, context.DeadlineExceeded), mongo.IsTimeout(err):
+		// The caller's time ran out, which is not evidence that the dependency is down
+		return Wrap(err, CodeTimeout, "request timed out")
+	case stderrors.Is(err, context.Canceled),
+		mongo.IsNetworkError(err),
+		stderrors.Is(err, mongo.ErrClientDisconnected),
+		stderrors.Is(err, redis.ErrClosed),
+		stderrors.Is(err, redis.ErrPoolTimeout),
+		stderrors.Is(err, redis.ErrPoolExhausted),
+		isNetError(err):
+		return Wrap(err, CodeUnavailable, "service temporarily unavailable")
+	}
+
+	return Wrap(err, CodeInternal, "internal error")
+}
+
+// FromMongo classifies an error returned by the MongoDB driver.
+// mongo.ErrNoDocuments becomes CodeNotFound and duplicate key errors become CodeConflict.
+// It returns nil for a nil error, so it can wrap driver calls directly.
+func FromMongo(err error) error {
+	if err == nil {
+		return nil
+	}
+	return Classify(err)
+}
+
+// FromRedis classifies an error returned by the Redis client.
+// redis.Nil becomes CodeNotFound and connection failures become CodeUnavailable.
+// It returns nil for a nil error, so it can wrap client calls directly.
+func FromRedis(err error) error {
+	if err == nil {
+		return nil
+	}
+	return Classify(err)
+}
+
+// isNetError reports whether err is a network-level error.
+func isNetError(err error) bool {
+	var netErr net.Error
+	return stderrors.As(err, &netErr)
+}
@@ -0,0 +1,47 @@
+package errors
+
+import "encoding/json"
+
+// Envelope is the JSON body returned to API clients for failed requests.
+type Envelope struct {
+	Error EnvelopeError `json:"error"`
+}
+
+// EnvelopeError describes the error inside an Envelope.
+type EnvelopeError struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// ToEnvelope classifies err and returns the HTTP status code and envelope for it.
+// Messages of untyped internal errors are replaced with a generic message so
+// driver details never leak to clients.
+func ToEnvelope(err error) (int, Envelope) {
+	appErr := Classify(err)
+	if appErr == nil {
+		appErr = Internal("internal error")
+	}
+
+	return appErr.Status(), Envelope{
+		Error: EnvelopeError{
+			Code:    appErr.Code,
+			Message: appErr.Message,
+			Details: appErr.Details,
+		},
+	}
+}
+
+// MarshalEnvelope classifies err and returns the HTTP status code and the JSON-encoded envelope.
+func MarshalEnvelope(err error) (int, []byte) {
+	status, envelope := ToEnvelope(err)
+
+	body, marshalErr := json.Marshal(envelope)
+	if marshalErr != nil {
+		// Details could not be encoded; fall back to the envelope without them
+		envelope.Error.Details = nil
+		body, _ = json.Marshal(envelope)
+	}
+
+	return status, body
+}
@@ -0,0 +1,219 @@
+// Package errors defines the typed application errors shared by all functions.
+// Every error carries a Code that maps to an HTTP status, a client-safe message
+// and an optional cause, so handlers can return meaningful failures instead of
+// opaque driver errors.
+//
+// The package name shadows the standard library, so import it with an alias:
+//
+//	import apperrors "github.com/xarunoba/mlgmr/shared/errors"
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Code identifies the category of an application error.
+type Code string
+
+const (
+	// CodeNotFound means the requested resource does not exist.
+	CodeNotFound Code = "NOT_FOUND"
+	// CodeValidation means the input was rejected.
+	CodeValidation Code = "VALIDATION"
+	// CodeConflict means the request conflicts with the current state of a resource.
+	CodeConflict Code = "CONFLICT"
+	// CodeTooManyRequests means the caller exceeded a rate limit and should retry later.
+	CodeTooManyRequests Code = "TOO_MANY_REQUESTS"
+	// CodeUnavailable means a dependency (database, cache, network) is temporarily unavailable.
+	CodeUnavailable Code = "UNAVAILABLE"
+	// CodeTimeout means the request ran out of time, e.g. its deadline passed while waiting
+	// for a dependency.
+	CodeTimeout Code = "TIMEOUT"
+	// CodeInternal means an unexpected failure. It is the default for untyped errors.
+	CodeInternal Code = "INTERNAL"
+)
+
+// Status returns the HTTP status code associated with the error code.
+func (c Code) Status() int {
+	switch c {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeTooManyRequests:
+		return http.StatusTooManyRequests
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is a typed application error.
+// Message is safe to show to clients; Cause holds the underlying error for logs
+// and errors.Is/errors.As matching; Details holds optional structured data
+// (e.g. per-field validation failures) that is included in error responses.
+type Error struct {
+	Code    Code
+	Message string
+	Cause   error
+	Details any
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Status returns the HTTP status code for the error.
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// WithCause sets the underlying cause and returns the error for chaining.
+func (e *Error) WithCause(cause error) *Error {
+	e.Cause = cause
+	return e
+}
+
+// WithDetails sets the structured details and returns the error for chaining.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// New creates an application error with the given code and message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap creates an application error with the given code and message around cause.
+func Wrap(cause error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Cause: cause}
+}
+
+// NotFound creates a CodeNotFound error.
+func NotFound(message string) *Error {
+	return New(CodeNotFound, message)
+}
+
+// Validation creates a CodeValidation error.
+func Validation(message string) *Error {
+	return New(CodeValidation, message)
+}
+
+// Conflict creates a CodeConflict error.
+func Conflict(message string) *Error {
+	return New(CodeConflict, message)
+}
+
+// TooManyRequests creates a CodeTooManyRequests error telling the caller to retry after
+// the given duration. The delay is included in the details as RetryDetails.
+func TooManyRequests(message string, retryAfter time.Duration) *Error {
+	return New(CodeTooManyRequests, message).WithDetails(RetryDetails{
+		RetryAfterSeconds: int64(math.Ceil(retryAfter.Seconds())),
+	})
+}
+
+// Unavailable creates a CodeUnavailable error.
+func Unavailable(message string) *Error {
+	return New(CodeUnavailable, message)
+}
+
+// Timeout creates a CodeTimeout error.
+func Timeout(message string) *Error {
+	return New(CodeTimeout, message)
+}
+
+// Internal creates a CodeInternal error.
+func Internal(message string) *Error {
+	return New(CodeInternal, message)
+}
+
+// As returns the first *Error in err's chain, if any.
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if stderrors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// CodeOf returns the code of the first *Error in err's chain.
+// It returns CodeInternal for untyped errors and an empty code for nil.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	if appErr, ok := As(err); ok {
+		return appErr.Code
+	}
+	return CodeInternal
+}
+
+// IsNotFound reports whether err is, or classifies as, a CodeNotFound error.
+func IsNotFound(err error) bool {
+	return err != nil && Classify(err).Code == CodeNotFound
+}
+
+// IsValidation reports whether err is, or classifies as, a CodeValidation error.
+func IsValidation(err error) bool {
+	return err != nil && Classify(err).Code == CodeValidation
+}
+
+// IsConflict reports whether err is, or classifies as, a CodeConflict error.
+func IsConflict(err error) bool {
+	return err != nil && Classify(err).Code == CodeConflict
+}
+
+// IsTooManyRequests reports whether err is a CodeTooManyRequests error.
+func IsTooManyRequests(err error) bool {
+	return err != nil && Classify(err).Code == CodeTooManyRequests
+}
+
+// IsUnavailable reports whether err is, or classifies as, a CodeUnavailable error.
+func IsUnavailable(err error) bool {
+	return err != nil && Classify(err).Code == CodeUnavailable
+}
+
+// IsTimeout reports whether err is, or classifies as, a CodeTimeout error.
+func IsTimeout(err error) bool {
+	return err != nil && Classify(err).Code == CodeTimeout
+}
+
+// RetryDetails tells clients when they may retry a rejected request.
+type RetryDetails struct {
+	RetryAfterSeconds int64 `json:"retryAfterSeconds"`
+}
+
+// RetryAfter returns the retry delay carried by err's RetryDetails, if any.
+// API adapters use it to set the Retry-After header.
+func RetryAfter(err error) (time.Duration, bool) {
+	appErr, ok := As(err)
+	if !ok {
+		return 0, false
+	}
+
+	details, ok := appErr.Details.(RetryDetails)
+	if !ok {
+		return 0, false
+	}
+
+	return time.Duration(details.RetryAfterSeconds) * time.Second, true
+}
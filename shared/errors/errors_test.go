@@ -0,0 +1,211 @@
+package errors_test
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestCode_Status(t *testing.T) {
+	tests := []struct {
+		code   apperrors.Code
+		status int
+	}{
+		{apperrors.CodeNotFound, http.StatusNotFound},
+		{apperrors.CodeValidation, http.StatusBadRequest},
+		{apperrors.CodeConflict, http.StatusConflict},
+		{apperrors.CodeTooManyRequests, http.StatusTooManyRequests},
+		{apperrors.CodeUnavailable, http.StatusServiceUnavailable},
+		{apperrors.CodeTimeout, http.StatusGatewayTimeout},
+		{apperrors.CodeInternal, http.StatusInternalServerError},
+		{apperrors.Code("UNKNOWN"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := tt.code.Status(); got != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, got)
+			}
+		})
+	}
+}
+
+func TestError_MessageAndUnwrap(t *testing.T) {
+	cause := stderrors.New("connection refused")
+	err := apperrors.Unavailable("database unavailable").WithCause(cause)
+
+	if err.Error() != "UNAVAILABLE: database unavailable: connection refused" {
+		t.Errorf("Unexpected error message '%s'", err.Error())
+	}
+	if !stderrors.Is(err, cause) {
+		t.Error("Expected error to unwrap to its cause")
+	}
+
+	plain := apperrors.NotFound("user not found")
+	if plain.Error() != "NOT_FOUND: user not found" {
+		t.Errorf("Unexpected error message '%s'", plain.Error())
+	}
+}
+
+func TestAsAndCodeOf(t *testing.T) {
+	wrapped := fmt.Errorf("loading user: %w", apperrors.Conflict("already exists"))
+
+	appErr, ok := apperrors.As(wrapped)
+	if !ok {
+		t.Fatal("Expected As to find the typed error")
+	}
+	if appErr.Code != apperrors.CodeConflict {
+		t.Errorf("Expected code %s, got %s", apperrors.CodeConflict, appErr.Code)
+	}
+
+	if code := apperrors.CodeOf(wrapped); code != apperrors.CodeConflict {
+		t.Errorf("Expected code %s, got %s", apperrors.CodeConflict, code)
+	}
+	if code := apperrors.CodeOf(stderrors.New("boom")); code != apperrors.CodeInternal {
+		t.Errorf("Expected code %s, got %s", apperrors.CodeInternal, code)
+	}
+	if code := apperrors.CodeOf(nil); code != "" {
+		t.Errorf("Expected empty code for nil, got %s", code)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code apperrors.Code
+	}{
+		{"mongo no documents", mongo.ErrNoDocuments, apperrors.CodeNotFound},
+		{"wrapped mongo no documents", fmt.Errorf("find: %w", mongo.ErrNoDocuments), apperrors.CodeNotFound},
+		{"redis nil", redis.Nil, apperrors.CodeNotFound},
+		{"mongo duplicate key", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}, apperrors.CodeConflict},
+		{"deadline exceeded", context.DeadlineExceeded, apperrors.CodeTimeout},
+		{"canceled", context.Canceled, apperrors.CodeUnavailable},
+		{"mongo client disconnected", mongo.ErrClientDisconnected, apperrors.CodeUnavailable},
+		{"redis closed", redis.ErrClosed, apperrors.CodeUnavailable},
+		{"redis pool timeout", redis.ErrPoolTimeout, apperrors.CodeUnavailable},
+		{"typed error kept", apperrors.Validation("bad input"), apperrors.CodeValidation},
+		{"unknown error", stderrors.New("boom"), apperrors.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := apperrors.Classify(tt.err)
+			if appErr.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, appErr.Code)
+			}
+			if reflect.TypeOf(tt.err).Comparable() && !stderrors.Is(appErr, tt.err) {
+				t.Error("Expected classified error to keep the original error in its chain")
+			}
+		})
+	}
+
+	if apperrors.Classify(nil) != nil {
+		t.Error("Expected Classify(nil) to return nil")
+	}
+}
+
+func TestFromDrivers(t *testing.T) {
+	if apperrors.FromMongo(nil) != nil {
+		t.Error("Expected FromMongo(nil) to return nil")
+	}
+	if apperrors.FromRedis(nil) != nil {
+		t.Error("Expected FromRedis(nil) to return nil")
+	}
+	if !apperrors.IsNotFound(apperrors.FromMongo(mongo.ErrNoDocuments)) {
+		t.Error("Expected mongo.ErrNoDocuments to classify as not found")
+	}
+	if !apperrors.IsNotFound(apperrors.FromRedis(redis.Nil)) {
+		t.Error("Expected redis.Nil to classify as not found")
+	}
+	if !apperrors.IsUnavailable(apperrors.FromRedis(redis.ErrClosed)) {
+		t.Error("Expected redis.ErrClosed to classify as unavailable")
+	}
+	if !apperrors.IsTimeout(apperrors.FromMongo(context.DeadlineExceeded)) {
+		t.Error("Expected context.DeadlineExceeded to classify as a timeout")
+	}
+	if apperrors.IsConflict(nil) {
+		t.Error("Expected nil not to be a conflict")
+	}
+}
+
+func TestTooManyRequests_RetryAfter(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", apperrors.TooManyRequests("slow down", 1500*time.Millisecond))
+
+	if !apperrors.IsTooManyRequests(err) {
+		t.Errorf("Expected a too many requests error, got '%v'", err)
+	}
+
+	retryAfter, ok := apperrors.RetryAfter(err)
+	if !ok {
+		t.Fatal("Expected a retry delay")
+	}
+	if retryAfter != 2*time.Second {
+		t.Errorf("Expected retry delay to round up to 2s, got %v", retryAfter)
+	}
+
+	if _, ok := apperrors.RetryAfter(apperrors.Conflict("exists")); ok {
+		t.Error("Expected no retry delay for errors without RetryDetails")
+	}
+}
+
+func TestMarshalEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    apperrors.Code
+		message string
+	}{
+		{
+			name:    "typed error",
+			err:     apperrors.Validation("name is required").WithDetails(map[string]string{"field": "name"}),
+			status:  http.StatusBadRequest,
+			code:    apperrors.CodeValidation,
+			message: "name is required",
+		},
+		{
+			name:    "driver error",
+			err:     mongo.ErrNoDocuments,
+			status:  http.StatusNotFound,
+			code:    apperrors.CodeNotFound,
+			message: "document not found",
+		},
+		{
+			name:    "untyped error hides details",
+			err:     stderrors.New("secret connection string leaked"),
+			status:  http.StatusInternalServerError,
+			code:    apperrors.CodeInternal,
+			message: "internal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apperrors.MarshalEnvelope(tt.err)
+			if status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, status)
+			}
+
+			var envelope apperrors.Envelope
+			if err := json.Unmarshal(body, &envelope); err != nil {
+				t.Fatalf("Expected valid JSON body, got '%s'", body)
+			}
+			if envelope.Error.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, envelope.Error.Code)
+			}
+			if envelope.Error.Message != tt.message {
+				t.Errorf("Expected message '%s', got '%s'", tt.message, envelope.Error.Message)
+			}
+		})
+	}
+}
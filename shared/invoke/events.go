@@ -0,0 +1,56 @@
+package invoke
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Event is an event fixture.
+type Event struct {
+	// Name is the file name without the .json extension, e.g. "event".
+	Name string
+	// Path is the path of the file.
+	Path    string
+	Payload []byte
+}
+
+// GoldenPath returns the path of the golden file holding the expected payload of the
+// event: the event's path with a .golden extension instead of .json.
+func (e Event) GoldenPath() string {
+	return strings.TrimSuffix(e.Path, ".json") + ".golden"
+}
+
+// LoadEvents loads the event at path or, if path is a directory, every .json file in
+// it, sorted by name.
+func LoadEvents(path string) ([]Event, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+
+	paths := []string{path}
+	if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, fmt.Errorf("failed to load events: %w", err)
+		}
+		slices.Sort(paths)
+	}
+
+	events := make([]Event, 0, len(paths))
+	for _, p := range paths {
+		payload, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load event: %w", err)
+		}
+		events = append(events, Event{
+			Name:    strings.TrimSuffix(filepath.Base(p), ".json"),
+			Path:    p,
+			Payload: payload,
+		})
+	}
+
+	return events, nil
+}
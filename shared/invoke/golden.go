@@ -0,0 +1,47 @@
+package invoke
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CompareGolden compares payload to the golden file at path. JSON payloads are
+// compared and stored indented, so golden files are readable and diff well.
+// If update is true, the golden file is written instead.
+func CompareGolden(path string, payload []byte, update bool) error {
+	got := formatGolden(payload)
+
+	if update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			return fmt.Errorf("failed to write golden file: %w", err)
+		}
+		return nil
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("golden file %s does not exist, rerun with update to create it", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read golden file: %w", err)
+	}
+
+	if !bytes.Equal(formatGolden(want), got) {
+		return fmt.Errorf("payload does not match golden file %s\n--- want\n%s\n--- got\n%s", path, bytes.TrimSpace(want), bytes.TrimSpace(got))
+	}
+	return nil
+}
+
+// formatGolden indents JSON payloads and ends the payload with a newline.
+func formatGolden(payload []byte) []byte {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bytes.TrimSpace(payload), "", "  "); err != nil {
+		indented.Reset()
+		indented.Write(bytes.TrimSpace(payload))
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes()
+}
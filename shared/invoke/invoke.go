@@ -0,0 +1,220 @@
+// Package invoke runs handlers in-process against event payloads, the way the Lambda
+// runtime invokes them: the payload is JSON-decoded into the handler input, the context
+// carries a synthetic Lambda context (request ID, function ARN, X-Ray trace header) and
+// the function's deadline, and the output is JSON-encoded. It lets event fixtures such
+// as functions/greeter/events/*.json run in plain `go test`, with their outputs compared
+// to golden files (see LoadEvents and CompareGolden).
+package invoke
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+// DefaultTimeout is the timeout of invocations, the default of Lambda functions.
+const DefaultTimeout = 3 * time.Second
+
+// DefaultFunctionName is used in the function ARN unless WithFunctionName is given.
+const DefaultFunctionName = "local"
+
+// Result is the outcome of an invocation.
+type Result struct {
+	// Output is the JSON-encoded output of the handler. It is nil if the handler failed.
+	Output json.RawMessage
+	// Err is the error returned by the handler, or a *middleware.PanicError if it panicked.
+	Err error
+	// Logs holds the lines logged through the middleware logger during the invocation.
+	Logs []byte
+	// RequestID is the request ID of the synthetic Lambda context.
+	RequestID string
+	// Duration is the time the handler took.
+	Duration time.Duration
+}
+
+// Payload returns what the Lambda Invoke API would return: the output, or the error
+// document of a failed invocation.
+func (r Result) Payload() []byte {
+	if r.Err == nil {
+		return r.Output
+	}
+
+	message := r.Err.Error()
+	if panicErr, ok := r.Err.(*middleware.PanicError); ok {
+		// The runtime reports the panic value itself, without the "panic: " prefix
+		message = fmt.Sprint(panicErr.Value)
+	}
+
+	payload, _ := json.Marshal(ErrorDocument{Message: message, Type: ErrorType(r.Err)})
+	return payload
+}
+
+// ErrorDocument is the payload of a failed invocation.
+type ErrorDocument struct {
+	Message string `json:"errorMessage"`
+	Type    string `json:"errorType"`
+}
+
+// ErrorType returns the type name of err, which the Lambda runtime reports as errorType.
+func ErrorType(err error) string {
+	if panicErr, ok := err.(*middleware.PanicError); ok {
+		if valueErr, ok := panicErr.Value.(error); ok {
+			return ErrorType(valueErr)
+		}
+		return reflect.TypeOf(panicErr.Value).String()
+	}
+
+	t := reflect.TypeOf(err)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// config holds the settings of an invocation.
+type config struct {
+	requestID    string
+	functionName string
+	traceHeader  string
+	timeout      time.Duration
+}
+
+// Option configures an invocation.
+type Option func(*config)
+
+// WithRequestID sets the request ID. It defaults to a random UUID.
+func WithRequestID(requestID string) Option {
+	return func(c *config) {
+		c.requestID = requestID
+	}
+}
+
+// WithFunctionName sets the function name of the invoked function ARN.
+// It defaults to DefaultFunctionName.
+func WithFunctionName(name string) Option {
+	return func(c *config) {
+		c.functionName = name
+	}
+}
+
+// WithTraceHeader sets the X-Ray trace header. It defaults to a new sampled trace.
+func WithTraceHeader(header string) Option {
+	return func(c *config) {
+		c.traceHeader = header
+	}
+}
+
+// WithTimeout sets the time until the deadline of the invocation.
+// It defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// invokeMu serializes invocations, since capturing logs swaps the global logger.
+var invokeMu sync.Mutex
+
+// Invoke decodes payload into the handler input, calls handler with a synthetic Lambda
+// context and returns the encoded output, error and logs. Panics are recovered and
+// returned as a *middleware.PanicError. Invocations are serialized.
+func Invoke[TIn, TOut any](ctx context.Context, handler shared.HandlerFunc[TIn, TOut], payload []byte, opts ...Option) Result {
+	cfg := config{functionName: DefaultFunctionName, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	invokeMu.Lock()
+	defer invokeMu.Unlock()
+
+	start := time.Now()
+	if cfg.requestID == "" {
+		cfg.requestID = NewRequestID()
+	}
+	if cfg.traceHeader == "" {
+		cfg.traceHeader = NewTraceHeader(start)
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, start.Add(cfg.timeout))
+	defer cancel()
+
+	ctx = lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{
+		AwsRequestID:       cfg.requestID,
+		InvokedFunctionArn: FunctionARN(cfg.functionName),
+	})
+	// The Lambda runtime stores the trace header under this string key
+	ctx = context.WithValue(ctx, "x-amzn-trace-id", cfg.traceHeader)
+
+	logs, restore := captureLogs()
+	output, err := call(ctx, lambda.NewHandler(handler), payload)
+	restore()
+
+	return Result{
+		Output:    output,
+		Err:       err,
+		Logs:      logs.Bytes(),
+		RequestID: cfg.requestID,
+		Duration:  time.Since(start),
+	}
+}
+
+// call invokes handler, recovering panics like the Lambda runtime does.
+func call(ctx context.Context, handler lambda.Handler, payload []byte) (output []byte, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			output, err = nil, &middleware.PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+
+	return handler.Invoke(ctx, payload)
+}
+
+// captureLogs replaces the logger returned by middleware.GetLogger with one writing to
+// the returned buffer, keeping the configured format and level, until restore is called.
+func captureLogs() (logs *bytes.Buffer, restore func()) {
+	previous := middleware.GetLogger()
+
+	// Invalid values were already reported by GetLogger
+	cfg, _ := middleware.LoadLoggerConfig()
+	logs = &bytes.Buffer{}
+	cfg.Writer = logs
+	middleware.SetLogger(middleware.NewLogger(cfg, middleware.LogLevel()))
+
+	return logs, func() { middleware.SetLogger(previous) }
+}
+
+// FunctionARN returns the ARN reported for a function in the synthetic Lambda context.
+func FunctionARN(name string) string {
+	return "arn:aws:lambda:us-east-1:000000000000:function:" + name
+}
+
+// NewRequestID returns a random UUID, the format of Lambda request IDs.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// NewTraceHeader returns a sampled X-Ray trace header for a new trace started at now,
+// like the one Lambda passes to a function invoked by API Gateway.
+func NewTraceHeader(now time.Time) string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return fmt.Sprintf("Root=1-%08x-%x;Parent=%x;Sampled=1", now.Unix(), b[:12], b[12:])
+}
@@ -0,0 +1,164 @@
+package invoke_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared/invoke"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+type reply struct {
+	Message   string `json:"message"`
+	RequestID string `json:"requestId"`
+}
+
+func greet(ctx context.Context, input greeting) (*reply, error) {
+	if input.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if input.Name == "panic" {
+		panic("boom")
+	}
+
+	lc, _ := lambdacontext.FromContext(ctx)
+	middleware.LoggerFrom(ctx).InfoContext(ctx, "Greeting", "name", input.Name)
+	return &reply{Message: "Hello, " + input.Name + "!", RequestID: lc.AwsRequestID}, nil
+}
+
+func TestInvoke_Output(t *testing.T) {
+	result := invoke.Invoke(context.Background(), greet, []byte(`{"name":"World"}`), invoke.WithRequestID("c6af9ac6-7b61-11e6-9a41-93e8deadbeef"))
+	if result.Err != nil {
+		t.Fatalf("Expected no error, got '%v'", result.Err)
+	}
+
+	var output reply
+	if err := json.Unmarshal(result.Output, &output); err != nil {
+		t.Fatalf("Expected JSON output, got '%s'", result.Output)
+	}
+	if output.Message != "Hello, World!" {
+		t.Errorf("Expected message 'Hello, World!', got '%s'", output.Message)
+	}
+	if output.RequestID != "c6af9ac6-7b61-11e6-9a41-93e8deadbeef" {
+		t.Errorf("Expected the request ID in the Lambda context, got '%s'", output.RequestID)
+	}
+	if !strings.Contains(string(result.Logs), `"msg":"Greeting"`) {
+		t.Errorf("Expected the handler's logs to be captured, got '%s'", result.Logs)
+	}
+}
+
+func TestInvoke_Deadline(t *testing.T) {
+	var remaining time.Duration
+	handler := func(ctx context.Context, input greeting) (string, error) {
+		deadline, _ := ctx.Deadline()
+		remaining = time.Until(deadline)
+		return "", nil
+	}
+
+	invoke.Invoke(context.Background(), handler, []byte(`{}`), invoke.WithTimeout(10*time.Second))
+	if remaining <= 9*time.Second || remaining > 10*time.Second {
+		t.Errorf("Expected a deadline in 10s, got %v", remaining)
+	}
+}
+
+func TestInvoke_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		payload       string
+		expectMessage string
+		expectType    string
+	}{
+		{name: "handler error", payload: `{}`, expectMessage: "name is required", expectType: "errorString"},
+		{name: "panic", payload: `{"name":"panic"}`, expectMessage: "boom", expectType: "string"},
+		{name: "invalid payload", payload: `[]`, expectType: "UnmarshalTypeError"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := invoke.Invoke(context.Background(), greet, []byte(tt.payload))
+			if result.Err == nil {
+				t.Fatal("Expected an error")
+			}
+			if result.Output != nil {
+				t.Errorf("Expected no output, got '%s'", result.Output)
+			}
+
+			var doc invoke.ErrorDocument
+			if err := json.Unmarshal(result.Payload(), &doc); err != nil {
+				t.Fatalf("Expected an error document, got '%s'", result.Payload())
+			}
+			if tt.expectMessage != "" && doc.Message != tt.expectMessage {
+				t.Errorf("Expected errorMessage '%s', got '%s'", tt.expectMessage, doc.Message)
+			}
+			if doc.Type != tt.expectType {
+				t.Errorf("Expected errorType '%s', got '%s'", tt.expectType, doc.Type)
+			}
+		})
+	}
+}
+
+func TestLoadEvents(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"b.json":   `{"name":"B"}`,
+		"a.json":   `{"name":"A"}`,
+		"a.golden": `{}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write event: %v", err)
+		}
+	}
+
+	events, err := invoke.LoadEvents(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(events) != 2 || events[0].Name != "a" || events[1].Name != "b" {
+		t.Fatalf("Expected events a and b, got %v", events)
+	}
+	if events[0].GoldenPath() != filepath.Join(dir, "a.golden") {
+		t.Errorf("Expected the golden file next to the event, got '%s'", events[0].GoldenPath())
+	}
+
+	single, err := invoke.LoadEvents(filepath.Join(dir, "b.json"))
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(single) != 1 || string(single[0].Payload) != `{"name":"B"}` {
+		t.Errorf("Expected the single event b, got %v", single)
+	}
+}
+
+func TestCompareGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.golden")
+
+	if err := invoke.CompareGolden(path, []byte(`{"message":"Hello"}`), false); err == nil {
+		t.Error("Expected an error for a missing golden file")
+	}
+	if err := invoke.CompareGolden(path, []byte(`{"message":"Hello"}`), true); err != nil {
+		t.Fatalf("Expected the golden file to be written, got '%v'", err)
+	}
+
+	written, _ := os.ReadFile(path)
+	if string(written) != "{\n  \"message\": \"Hello\"\n}\n" {
+		t.Errorf("Expected indented JSON, got '%s'", written)
+	}
+
+	if err := invoke.CompareGolden(path, []byte(`{"message": "Hello"}`), false); err != nil {
+		t.Errorf("Expected equivalent JSON to match, got '%v'", err)
+	}
+	if err := invoke.CompareGolden(path, []byte(`{"message":"Bye"}`), false); err == nil {
+		t.Error("Expected a different payload not to match")
+	}
+}
@@ -0,0 +1,250 @@
+// Package lock provides a distributed mutual-exclusion lock on Redis, for functions
+// that must not run a critical section concurrently across Lambda invocations.
+//
+// Each lock is a Redis key holding a random value that only its owner knows, so
+// Release and Extend never affect a lock that expired and was acquired by someone else.
+// Every successful acquisition also returns a fencing token that increases
+// monotonically per lock name; pass it to the protected resource to reject writes
+// from an owner whose lock has expired in the meantime.
+package lock
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// Locker defaults.
+const (
+	DefaultPrefix     = "lock"
+	DefaultTTL        = 30 * time.Second
+	DefaultMinBackoff = 10 * time.Millisecond
+	DefaultMaxBackoff = 500 * time.Millisecond
+)
+
+var (
+	// ErrNotAcquired is returned by TryAcquire when the lock is held by another owner.
+	// It is wrapped in a new apperrors.CodeConflict error each time; match it with errors.Is.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrNotHeld is returned by Release and Extend when the lock expired or is now
+	// held by another owner. It is wrapped like ErrNotAcquired.
+	ErrNotHeld = errors.New("lock not held")
+)
+
+// acquireScript sets the lock if it is free and returns a new fencing token, or 0 if
+// the lock is held. KEYS[1] is the lock key, KEYS[2] the fencing counter; ARGV is the
+// owner's value and the TTL in milliseconds.
+var acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// extendScript resets the TTL of the lock only if it still holds the owner's value.
+// KEYS[1] is the lock key; ARGV is the owner's value and the TTL in milliseconds.
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Locker acquires locks. It is safe for concurrent use by multiple goroutines.
+type Locker struct {
+	clients    db.Clients
+	prefix     string
+	ttl        time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures a Locker.
+type Option func(*Locker)
+
+// WithClients sets where the Redis client comes from. It defaults to db.DefaultProvider(),
+// resolved on every acquisition.
+func WithClients(clients db.Clients) Option {
+	return func(l *Locker) {
+		l.clients = clients
+	}
+}
+
+// WithPrefix sets the Redis key prefix. It defaults to DefaultPrefix.
+func WithPrefix(prefix string) Option {
+	return func(l *Locker) {
+		l.prefix = prefix
+	}
+}
+
+// WithTTL sets how long a lock is held unless it is released or extended.
+// It defaults to DefaultTTL; acquisitions fail if it is below 1ms.
+func WithTTL(ttl time.Duration) Option {
+	return func(l *Locker) {
+		l.ttl = ttl
+	}
+}
+
+// WithBackoff sets the delay bounds between attempts of a blocking Acquire.
+// The delay doubles from minBackoff up to maxBackoff, with jitter.
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(l *Locker) {
+		l.minBackoff = minBackoff
+		l.maxBackoff = maxBackoff
+	}
+}
+
+// New returns a Locker.
+func New(opts ...Option) *Locker {
+	l := &Locker{
+		prefix:     DefaultPrefix,
+		ttl:        DefaultTTL,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.maxBackoff < l.minBackoff {
+		l.maxBackoff = l.minBackoff
+	}
+
+	return l
+}
+
+var defaultLocker = New()
+
+// Acquire acquires the named lock with the default Locker, waiting until it is free.
+func Acquire(ctx context.Context, name string) (*Lock, error) {
+	return defaultLocker.Acquire(ctx, name)
+}
+
+// TryAcquire acquires the named lock with the default Locker, or returns ErrNotAcquired.
+func TryAcquire(ctx context.Context, name string) (*Lock, error) {
+	return defaultLocker.TryAcquire(ctx, name)
+}
+
+// Lock is an acquired lock.
+type Lock struct {
+	client redis.UniversalClient
+	key    string
+	value  string
+	token  int64
+}
+
+// TryAcquire acquires the named lock if it is free, or returns ErrNotAcquired.
+func (l *Locker) TryAcquire(ctx context.Context, name string) (*Lock, error) {
+	if err := checkTTL(l.ttl); err != nil {
+		return nil, err
+	}
+
+	clients := l.clients
+	if clients == nil {
+		clients = db.DefaultProvider()
+	}
+
+	client, err := clients.Redis(ctx)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeUnavailable, "lock store unavailable")
+	}
+
+	// The hash tag keeps the lock and its fencing counter in the same Redis Cluster slot
+	key := fmt.Sprintf("%s:{%s}", l.prefix, name)
+	value := crand.Text()
+
+	token, err := acquireScript.Run(ctx, client, []string{key, key + ":fence"}, value, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, apperrors.FromRedis(err)
+	}
+	if token == 0 {
+		return nil, apperrors.Wrap(ErrNotAcquired, apperrors.CodeConflict, "lock is held by another owner")
+	}
+
+	return &Lock{client: client, key: key, value: value, token: token}, nil
+}
+
+// Acquire acquires the named lock, retrying with exponential backoff while it is held.
+// It returns the context's error if ctx is done first.
+func (l *Locker) Acquire(ctx context.Context, name string) (*Lock, error) {
+	backoff := l.minBackoff
+
+	for {
+		lock, err := l.TryAcquire(ctx, name)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+
+		// Full jitter spreads out retries of concurrent waiters
+		timer := time.NewTimer(rand.N(backoff) + 1)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, l.maxBackoff)
+	}
+}
+
+// Token returns the fencing token of the acquisition. Tokens of the same lock name
+// increase with every acquisition.
+func (lk *Lock) Token() int64 {
+	return lk.token
+}
+
+// Key returns the Redis key of the lock.
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+// Release releases the lock. It returns ErrNotHeld if the lock already expired.
+func (lk *Lock) Release(ctx context.Context) error {
+	released, err := db.CompareAndDelete(ctx, lk.client, lk.key, lk.value)
+	if err != nil {
+		return apperrors.FromRedis(err)
+	}
+	if !released {
+		return errNotHeld()
+	}
+	return nil
+}
+
+// Extend resets the remaining time of the lock to ttl. It returns ErrNotHeld if the
+// lock already expired.
+func (lk *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if err := checkTTL(ttl); err != nil {
+		return err
+	}
+
+	extended, err := extendScript.Run(ctx, lk.client, []string{lk.key}, lk.value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return apperrors.FromRedis(err)
+	}
+	if extended == 0 {
+		return errNotHeld()
+	}
+	return nil
+}
+
+// errNotHeld returns a new conflict error wrapping ErrNotHeld.
+func errNotHeld() error {
+	return apperrors.Wrap(ErrNotHeld, apperrors.CodeConflict, "lock expired or is held by another owner")
+}
+
+// checkTTL rejects TTLs below 1ms, which would be sent as PX 0 and refused by Redis.
+func checkTTL(ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return apperrors.Internal(fmt.Sprintf("lock TTL must be at least 1ms, got %v", ttl))
+	}
+	return nil
+}
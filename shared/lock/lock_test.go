@@ -0,0 +1,196 @@
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/db"
+	"github.com/xarunoba/mlgmr/shared/db/dbtest"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/lock"
+)
+
+// newLocker returns a Locker backed by an in-process Redis.
+func newLocker(t *testing.T, opts ...lock.Option) (*lock.Locker, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	opts = append([]lock.Option{lock.WithClients(db.NewProvider(db.WithRedisClient(client)))}, opts...)
+	return lock.New(opts...), mr
+}
+
+func TestLocker_TryAcquireAndRelease(t *testing.T) {
+	ctx := context.Background()
+	locker, _ := newLocker(t)
+
+	first, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	_, err = locker.TryAcquire(ctx, "greeter")
+	if !errors.Is(err, lock.ErrNotAcquired) {
+		t.Errorf("Expected ErrNotAcquired while the lock is held, got '%v'", err)
+	}
+	if !apperrors.IsConflict(err) {
+		t.Errorf("Expected a conflict error, got '%v'", err)
+	}
+
+	// Every failure returns its own error, so callers can annotate it without affecting others
+	appErr, _ := apperrors.As(err)
+	appErr.Message = "changed"
+	if _, err := locker.TryAcquire(ctx, "greeter"); apperrors.Classify(err).Message == "changed" {
+		t.Errorf("Expected a new error for every failed acquisition, got '%v'", err)
+	}
+
+	// Other names are independent
+	other, err := locker.TryAcquire(ctx, "other")
+	if err != nil {
+		t.Fatalf("Expected no error for another lock, got '%v'", err)
+	}
+	defer other.Release(ctx)
+
+	if err := first.Release(ctx); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if err := first.Release(ctx); !errors.Is(err, lock.ErrNotHeld) || !apperrors.IsConflict(err) {
+		t.Errorf("Expected an ErrNotHeld conflict on a second release, got '%v'", err)
+	}
+
+	second, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected the released lock to be free, got '%v'", err)
+	}
+	if second.Token() <= first.Token() {
+		t.Errorf("Expected fencing tokens to increase, got %d after %d", second.Token(), first.Token())
+	}
+}
+
+func TestLock_ExpiredLockIsNotReleasedByStaleOwner(t *testing.T) {
+	ctx := context.Background()
+	locker, mr := newLocker(t, lock.WithTTL(time.Second))
+
+	stale, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	mr.FastForward(2 * time.Second)
+
+	current, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected the expired lock to be free, got '%v'", err)
+	}
+
+	if err := stale.Release(ctx); !errors.Is(err, lock.ErrNotHeld) {
+		t.Errorf("Expected ErrNotHeld for the stale owner, got '%v'", err)
+	}
+	if err := stale.Extend(ctx, time.Minute); !errors.Is(err, lock.ErrNotHeld) {
+		t.Errorf("Expected ErrNotHeld when extending a stale lock, got '%v'", err)
+	}
+	if !mr.Exists(current.Key()) {
+		t.Error("Expected the current owner's lock to survive")
+	}
+}
+
+func TestLock_Extend(t *testing.T) {
+	ctx := context.Background()
+	locker, mr := newLocker(t, lock.WithTTL(time.Second))
+
+	lk, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	if err := lk.Extend(ctx, time.Minute); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if ttl := mr.TTL(lk.Key()); ttl != time.Minute {
+		t.Errorf("Expected TTL of 1m after extending, got %v", ttl)
+	}
+
+	mr.FastForward(2 * time.Second)
+	if err := lk.Release(ctx); err != nil {
+		t.Errorf("Expected the extended lock to still be held, got '%v'", err)
+	}
+}
+
+func TestLocker_AcquireWaitsForRelease(t *testing.T) {
+	ctx := context.Background()
+	locker, _ := newLocker(t, lock.WithBackoff(time.Millisecond, 10*time.Millisecond))
+
+	held, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		held.Release(ctx)
+	}()
+
+	acquired, err := locker.Acquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected the lock once released, got '%v'", err)
+	}
+	if acquired.Token() <= held.Token() {
+		t.Errorf("Expected a newer fencing token, got %d after %d", acquired.Token(), held.Token())
+	}
+}
+
+func TestLocker_AcquireHonorsCancellation(t *testing.T) {
+	locker, _ := newLocker(t)
+
+	if _, err := locker.TryAcquire(context.Background(), "greeter"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := locker.Acquire(ctx, "greeter"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got '%v'", err)
+	}
+}
+
+func TestAcquire_DefaultProvider(t *testing.T) {
+	ctx := context.Background()
+	fakes := dbtest.Install(t)
+
+	lk, err := lock.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if !fakes.Redis.Exists(lk.Key()) {
+		t.Errorf("Expected the lock in the installed Redis, got keys %v", fakes.Redis.Keys())
+	}
+
+	if _, err := lock.TryAcquire(ctx, "greeter"); !apperrors.IsConflict(err) {
+		t.Errorf("Expected a conflict while the lock is held, got '%v'", err)
+	}
+}
+
+func TestLocker_TTLBelowOneMillisecond(t *testing.T) {
+	ctx := context.Background()
+
+	locker, _ := newLocker(t, lock.WithTTL(time.Microsecond))
+	if _, err := locker.TryAcquire(ctx, "greeter"); err == nil {
+		t.Error("Expected an error for a TTL below 1ms")
+	}
+
+	locker, _ = newLocker(t)
+	lk, err := locker.TryAcquire(ctx, "greeter")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if err := lk.Extend(ctx, time.Microsecond); err == nil {
+		t.Error("Expected an error when extending by less than 1ms")
+	}
+}
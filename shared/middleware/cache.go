@@ -0,0 +1,273 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// Compile-time check to ensure Cache implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Cache[any, any](CacheConfig[any]{})
+
+// Cache defaults.
+const (
+	DefaultCachePrefix       = "cache"
+	DefaultCacheTTL          = 5 * time.Minute
+	DefaultCacheLockTTL      = 5 * time.Second
+	DefaultCachePollInterval = 50 * time.Millisecond
+)
+
+// CacheConfig configures Cache.
+type CacheConfig[TIn any] struct {
+	// Key derives the cache key of a request. It defaults to the SHA-256 hash of the
+	// JSON-encoded input. Requests with an empty key are not cached.
+	Key func(ctx context.Context, input TIn) (string, error)
+	// TTL is how long outputs are cached. It defaults to DefaultCacheTTL.
+	TTL time.Duration
+	// NotFoundTTL is how long apperrors.CodeNotFound errors are cached. Zero disables
+	// negative caching.
+	NotFoundTTL time.Duration
+	// Bypass reports whether a request must skip the cache entirely, e.g. NoCacheBypass.
+	Bypass func(ctx context.Context, input TIn) bool
+	// LockTTL bounds how long one invocation may hold the stampede lock while it computes
+	// a missing entry. Concurrent misses wait up to LockTTL for the entry before computing
+	// it themselves. It defaults to DefaultCacheLockTTL.
+	LockTTL time.Duration
+	// PollInterval is how often waiting invocations look for the entry.
+	// It defaults to DefaultCachePollInterval.
+	PollInterval time.Duration
+	// Prefix namespaces the Redis keys. It defaults to DefaultCachePrefix followed by the
+	// function name (see functionPrefix), so functions with identical inputs never share
+	// entries. A custom prefix is used as is.
+	Prefix string
+	// DB provides the Redis client. It defaults to db.DefaultProvider().
+	DB db.Clients
+}
+
+// cacheEntry is the JSON value stored under a cache key.
+type cacheEntry struct {
+	Output   json.RawMessage `json:"output,omitempty"`
+	NotFound string          `json:"notFound,omitempty"`
+}
+
+// Cache is a read-through caching middleware. Outputs are stored in Redis as JSON under
+// a key derived from the input and returned without running the handler until cfg.TTL
+// expires. Not-found errors are cached for cfg.NotFoundTTL; other errors are never cached.
+//
+// On a miss, a short lock makes sure only one invocation computes the entry while
+// concurrent misses for the same key wait for it (stampede protection).
+// The cache never fails a request: if Redis is unavailable the handler runs uncached.
+// TOut must round-trip through encoding/json.
+func Cache[TIn, TOut any](cfg CacheConfig[TIn]) shared.MiddlewareFunc[TIn, TOut] {
+	if cfg.Key == nil {
+		cfg.Key = func(_ context.Context, input TIn) (string, error) {
+			return hashInput(input)
+		}
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultCacheTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = DefaultCacheLockTTL
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultCachePollInterval
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = functionPrefix(DefaultCachePrefix)
+	}
+
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			var zero TOut
+
+			if cfg.Bypass != nil && cfg.Bypass(ctx, input) {
+				return next(ctx, input)
+			}
+
+			key, err := cfg.Key(ctx, input)
+			if err != nil {
+				return zero, err
+			}
+			if key == "" {
+				return next(ctx, input)
+			}
+
+			clients := cfg.DB
+			if clients == nil {
+				clients = db.DefaultProvider()
+			}
+
+			client, err := clients.Redis(ctx)
+			if err != nil {
+				LoggerFrom(ctx).WarnContext(ctx, "Cache unavailable, running handler uncached", slog.Any("error", err))
+				return next(ctx, input)
+			}
+
+			c := &cacheCall[TIn, TOut]{cfg: &cfg, client: client, key: fmt.Sprintf("%s:%s", cfg.Prefix, key)}
+			return c.run(ctx, input, next)
+		}
+	}
+}
+
+// cacheHit is a cached handler outcome: an output or a not-found error.
+type cacheHit[TOut any] struct {
+	output TOut
+	err    error
+}
+
+// cacheCall handles a single cacheable request.
+type cacheCall[TIn, TOut any] struct {
+	cfg    *CacheConfig[TIn]
+	client redis.UniversalClient
+	key    string
+}
+
+// run returns the cached entry or computes and stores it under the stampede lock.
+func (c *cacheCall[TIn, TOut]) run(ctx context.Context, input TIn, next shared.HandlerFunc[TIn, TOut]) (TOut, error) {
+	if hit := c.get(ctx); hit != nil {
+		return hit.output, hit.err
+	}
+
+	lockKey := c.key + ":lock"
+	token := rand.Text()
+
+	locked, err := c.client.SetNX(ctx, lockKey, token, c.cfg.LockTTL).Result()
+	if err != nil {
+		LoggerFrom(ctx).WarnContext(ctx, "Cache lock unavailable, running handler uncached", slog.Any("error", err))
+		return next(ctx, input)
+	}
+
+	if !locked {
+		// Another invocation is computing the entry; wait for it
+		if hit := c.wait(ctx, lockKey); hit != nil {
+			return hit.output, hit.err
+		}
+		if ctx.Err() != nil {
+			var zero TOut
+			return zero, ctx.Err()
+		}
+		return next(ctx, input)
+	}
+
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+
+		if _, err := db.CompareAndDelete(releaseCtx, c.client, lockKey, token); err != nil {
+			LoggerFrom(ctx).WarnContext(ctx, "Failed to release cache lock", slog.String("key", c.key), slog.Any("error", err))
+		}
+	}()
+
+	output, err := next(ctx, input)
+	c.set(ctx, output, err)
+	return output, err
+}
+
+// get returns the cached outcome, or nil on a miss or if the entry cannot be read.
+func (c *cacheCall[TIn, TOut]) get(ctx context.Context) *cacheHit[TOut] {
+	stored, err := c.client.Get(ctx, c.key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			LoggerFrom(ctx).WarnContext(ctx, "Failed to read cache entry", slog.String("key", c.key), slog.Any("error", err))
+		}
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(stored, &entry); err != nil {
+		LoggerFrom(ctx).WarnContext(ctx, "Failed to decode cache entry", slog.String("key", c.key), slog.Any("error", err))
+		return nil
+	}
+
+	if entry.NotFound != "" {
+		return &cacheHit[TOut]{err: apperrors.NotFound(entry.NotFound)}
+	}
+
+	hit := &cacheHit[TOut]{}
+	if err := json.Unmarshal(entry.Output, &hit.output); err != nil {
+		LoggerFrom(ctx).WarnContext(ctx, "Failed to decode cached output", slog.String("key", c.key), slog.Any("error", err))
+		return nil
+	}
+
+	return hit
+}
+
+// wait polls for the entry while another invocation holds the lock. It returns nil if
+// the lock was released or expired without an entry, or if ctx is done.
+func (c *cacheCall[TIn, TOut]) wait(ctx context.Context, lockKey string) *cacheHit[TOut] {
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+
+	timeout := time.NewTimer(c.cfg.LockTTL)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timeout.C:
+			return nil
+		case <-ticker.C:
+		}
+
+		if hit := c.get(ctx); hit != nil {
+			return hit
+		}
+
+		held, err := c.client.Exists(ctx, lockKey).Result()
+		if err != nil || held == 0 {
+			// The holder failed (or its entry could not be cached); compute it ourselves
+			return nil
+		}
+	}
+}
+
+// set stores the outcome of the handler. Only outputs and not-found errors are cached.
+func (c *cacheCall[TIn, TOut]) set(ctx context.Context, output TOut, err error) {
+	var entry cacheEntry
+	ttl := c.cfg.TTL
+
+	switch {
+	case err == nil:
+		encoded, encodeErr := json.Marshal(output)
+		if encodeErr != nil {
+			LoggerFrom(ctx).WarnContext(ctx, "Failed to encode output for caching", slog.String("key", c.key), slog.Any("error", encodeErr))
+			return
+		}
+		entry.Output = encoded
+	case c.cfg.NotFoundTTL > 0 && apperrors.IsNotFound(err):
+		entry.NotFound = apperrors.Classify(err).Message
+		ttl = c.cfg.NotFoundTTL
+	default:
+		return
+	}
+
+	stored, _ := json.Marshal(entry)
+	if setErr := c.client.Set(ctx, c.key, stored, ttl).Err(); setErr != nil {
+		LoggerFrom(ctx).WarnContext(ctx, "Failed to store cache entry", slog.String("key", c.key), slog.Any("error", setErr))
+	}
+}
+
+// NoCacheBypass bypasses the cache for API Gateway requests sent with
+// "Cache-Control: no-cache" or "Cache-Control: no-store".
+func NoCacheBypass[TIn any](ctx context.Context, _ TIn) bool {
+	req, ok := apigw.FromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	cacheControl := strings.ToLower(req.Header("Cache-Control"))
+	return strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store")
+}
@@ -0,0 +1,198 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+func TestCache_ReadThrough(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int64
+
+	handler := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+		TTL: time.Minute,
+		DB:  redisProvider(t, mr),
+	})(countingHandler(&calls))
+
+	first, err := handler(context.Background(), "World")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	cached, err := handler(context.Background(), "World")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if *cached != *first || calls.Load() != 1 {
+		t.Errorf("Expected the cached output %+v after 1 call, got %+v after %d calls", first, cached, calls.Load())
+	}
+
+	mr.FastForward(time.Minute)
+	refreshed, err := handler(context.Background(), "World")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if refreshed.Count != 2 {
+		t.Errorf("Expected the entry to expire after the TTL, got %+v", refreshed)
+	}
+}
+
+func TestCache_ScopedPerFunction(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var greeterCalls, billingCalls atomic.Int64
+
+	setFunctionName(t, "GreeterFunction")
+	greeter := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+		DB: redisProvider(t, mr),
+	})(countingHandler(&greeterCalls))
+
+	setFunctionName(t, "BillingFunction")
+	billing := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+		DB: redisProvider(t, mr),
+	})(countingHandler(&billingCalls))
+
+	for _, handler := range []shared.HandlerFunc[string, *greeting]{greeter, billing, greeter, billing} {
+		if _, err := handler(context.Background(), "World"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	if greeterCalls.Load() != 1 || billingCalls.Load() != 1 {
+		t.Errorf("Expected each function to compute its entry once, ran %d and %d times", greeterCalls.Load(), billingCalls.Load())
+	}
+	if keys := mr.Keys(); !hasKeyPrefix(keys, "cache:GreeterFunction:") || !hasKeyPrefix(keys, "cache:BillingFunction:") {
+		t.Errorf("Expected entries under both function prefixes, got %v", keys)
+	}
+}
+
+func TestCache_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		notFoundTTL   time.Duration
+		expectedCalls int64
+	}{
+		{name: "not found is cached", err: apperrors.NotFound("no such greeting"), notFoundTTL: time.Minute, expectedCalls: 1},
+		{name: "not found without negative caching", err: apperrors.NotFound("no such greeting"), expectedCalls: 3},
+		{name: "other errors are not cached", err: errors.New("boom"), notFoundTTL: time.Minute, expectedCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			var calls atomic.Int64
+
+			handler := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+				NotFoundTTL: tt.notFoundTTL,
+				DB:          redisProvider(t, mr),
+			})(func(ctx context.Context, input string) (*greeting, error) {
+				calls.Add(1)
+				return nil, tt.err
+			})
+
+			for range 3 {
+				_, err := handler(context.Background(), "missing")
+				if apperrors.CodeOf(err) != apperrors.CodeOf(tt.err) {
+					t.Errorf("Expected code %s, got '%v'", apperrors.CodeOf(tt.err), err)
+				}
+			}
+			if calls.Load() != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestCache_Bypass(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int64
+
+	handler := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+		Bypass: middleware.NoCacheBypass[string],
+		DB:     redisProvider(t, mr),
+	})(countingHandler(&calls))
+
+	noCache := apigw.NewContext(context.Background(), &apigw.Request{
+		Headers: map[string]string{"cache-control": "no-cache"},
+	})
+	for range 2 {
+		if _, err := handler(noCache, "World"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected bypassed requests to run the handler, got %d calls", calls.Load())
+	}
+	if len(mr.Keys()) != 0 {
+		t.Errorf("Expected bypassed requests not to be cached, got keys %v", mr.Keys())
+	}
+}
+
+func TestCache_StampedeProtection(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int64
+
+	handler := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+		PollInterval: 5 * time.Millisecond,
+		DB:           redisProvider(t, mr),
+	})(func(ctx context.Context, input string) (*greeting, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return &greeting{Message: "computed"}, nil
+	})
+
+	const numGoroutines = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, numGoroutines)
+
+	wg.Add(numGoroutines)
+	for range numGoroutines {
+		go func() {
+			defer wg.Done()
+			output, err := handler(context.Background(), "World")
+			if err == nil && output.Message != "computed" {
+				err = errors.New("unexpected output " + output.Message)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected no error, got '%v'", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected concurrent misses to compute the entry once, got %d calls", calls.Load())
+	}
+}
+
+func TestCache_RedisUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	provider := redisProvider(t, mr)
+	mr.Close()
+
+	var calls atomic.Int64
+	handler := middleware.Cache[string, *greeting](middleware.CacheConfig[string]{
+		DB: provider,
+	})(countingHandler(&calls))
+
+	if _, err := handler(context.Background(), "World"); err != nil {
+		t.Errorf("Expected the handler to run uncached, got '%v'", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared"
+)
+
+// Compile-time check to ensure Deadline implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Deadline[any, any](DefaultDeadlineMargin)
+
+// DefaultDeadlineMargin is the recommended safety margin between the handler budget
+// and the Lambda deadline. It leaves enough time to log and return the error.
+const DefaultDeadlineMargin = 500 * time.Millisecond
+
+// ErrDeadlineExceeded is returned when the handler did not finish before the
+// Lambda deadline minus the safety margin.
+// Errors returned by Deadline also match context.DeadlineExceeded, so they classify as
+// apperrors.CodeTimeout (504 Gateway Timeout).
+var ErrDeadlineExceeded = errors.New("handler deadline exceeded")
+
+// Deadline is a middleware that gives the handler a budget derived from the Lambda deadline.
+// It reads the deadline from the incoming context and passes a child context that expires
+// margin earlier, so database calls are cancelled before the runtime kills the process.
+// If the budget runs out the middleware returns ErrDeadlineExceeded without waiting for
+// the handler to finish. Contexts without a deadline are passed through unchanged.
+// A handler panic is re-raised on the caller's goroutine as a *PanicError carrying the
+// handler's stack trace, which Recover logs and returns as is.
+func Deadline[TIn, TOut any](margin time.Duration) shared.MiddlewareFunc[TIn, TOut] {
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return next(ctx, input)
+			}
+
+			var zero TOut
+			budget := deadline.Add(-margin)
+			if !time.Now().Before(budget) {
+				return zero, deadlineError(margin)
+			}
+
+			ctx, cancel := context.WithDeadline(ctx, budget)
+			defer cancel()
+
+			type result struct {
+				output TOut
+				err    error
+				panic  *PanicError
+			}
+
+			// Buffered so the handler goroutine never blocks if we stop waiting for it
+			done := make(chan result, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						// Capture the stack here: re-panicking below would point it at Deadline
+						done <- result{panic: &PanicError{Value: r, Stack: debug.Stack()}}
+					}
+				}()
+
+				output, err := next(ctx, input)
+				done <- result{output: output, err: err}
+			}()
+
+			select {
+			case res := <-done:
+				if res.panic != nil {
+					// Re-panic on the caller's goroutine so outer middlewares (e.g. Recover) see
+					// it; the *PanicError keeps the handler's stack trace
+					panic(res.panic)
+				}
+				if res.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return res.output, fmt.Errorf("%w: %w", deadlineError(margin), res.err)
+				}
+				return res.output, res.err
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return zero, deadlineError(margin)
+				}
+				return zero, ctx.Err()
+			}
+		}
+	}
+}
+
+// deadlineError builds the error returned when the handler budget runs out.
+func deadlineError(margin time.Duration) error {
+	return fmt.Errorf("%w (safety margin %s): %w", ErrDeadlineExceeded, margin, context.DeadlineExceeded)
+}
@@ -0,0 +1,153 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+func TestDeadline_NoDeadlinePassesThrough(t *testing.T) {
+	handler := middleware.Deadline[string, string](time.Second)(func(ctx context.Context, input string) (string, error) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("Expected handler context to have no deadline")
+		}
+		return input, nil
+	})
+
+	output, err := handler(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if output != "hello" {
+		t.Errorf("Expected output 'hello', got '%s'", output)
+	}
+}
+
+func TestDeadline_ChildContextHonorsMargin(t *testing.T) {
+	deadline := time.Now().Add(10 * time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	handler := middleware.Deadline[string, string](2 * time.Second)(func(ctx context.Context, input string) (string, error) {
+		got, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("Expected handler context to have a deadline")
+		}
+		if want := deadline.Add(-2 * time.Second); !got.Equal(want) {
+			t.Errorf("Expected deadline %v, got %v", want, got)
+		}
+		return input, nil
+	})
+
+	if _, err := handler(ctx, "hello"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+}
+
+func TestDeadline_SlowHandlerTimesOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	handler := middleware.Deadline[string, string](150 * time.Millisecond)(func(ctx context.Context, input string) (string, error) {
+		// Ignore the context entirely to simulate a stuck call
+		<-release
+		return input, nil
+	})
+
+	start := time.Now()
+	_, err := handler(ctx, "hello")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, middleware.ErrDeadlineExceeded) {
+		t.Fatalf("Expected ErrDeadlineExceeded, got '%v'", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected error to match context.DeadlineExceeded")
+	}
+	if elapsed >= 150*time.Millisecond {
+		t.Errorf("Expected handler to return before the Lambda deadline, took %s", elapsed)
+	}
+}
+
+func TestDeadline_HandlerObservesCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	handler := middleware.Deadline[string, string](50 * time.Millisecond)(func(ctx context.Context, input string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	_, err := handler(ctx, "hello")
+	if !errors.Is(err, middleware.ErrDeadlineExceeded) {
+		t.Errorf("Expected ErrDeadlineExceeded, got '%v'", err)
+	}
+}
+
+func TestDeadline_BudgetAlreadyExhausted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	called := false
+	handler := middleware.Deadline[string, string](time.Second)(func(ctx context.Context, input string) (string, error) {
+		called = true
+		return input, nil
+	})
+
+	_, err := handler(ctx, "hello")
+	if !errors.Is(err, middleware.ErrDeadlineExceeded) {
+		t.Errorf("Expected ErrDeadlineExceeded, got '%v'", err)
+	}
+	if called {
+		t.Error("Expected handler not to be called when the budget is already exhausted")
+	}
+}
+
+func TestDeadline_ParentCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+	handler := middleware.Deadline[string, string](time.Second)(func(ctx context.Context, input string) (string, error) {
+		cancel()
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	_, err := handler(ctx, "hello")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got '%v'", err)
+	}
+	if errors.Is(err, middleware.ErrDeadlineExceeded) {
+		t.Error("Expected cancellation not to be reported as a deadline error")
+	}
+}
+
+func TestDeadline_PanicPropagatesToCaller(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	handler := middleware.Recover(middleware.Deadline[string, string](time.Second)(panickingHandler))
+
+	_, err := handler(ctx, "hello")
+
+	var panicErr *middleware.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected *middleware.PanicError, got '%v'", err)
+	}
+	if panicErr.Value != "boom" {
+		t.Errorf("Expected panic value 'boom', got '%v'", panicErr.Value)
+	}
+	if !strings.Contains(string(panicErr.Stack), "panickingHandler") {
+		t.Errorf("Expected the stack trace to reference the panicking handler, got:\n%s", panicErr.Stack)
+	}
+}
+
+func panickingHandler(ctx context.Context, input string) (string, error) {
+	panic("boom")
+}
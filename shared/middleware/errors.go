@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+)
+
+// Compile-time check to ensure ErrorResponse implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, events.APIGatewayProxyResponse] = ErrorResponse[any]
+
+// ErrorResponse is a middleware for API Gateway handlers that converts returned errors
+// into a JSON error envelope with the matching HTTP status code (see apigw.ErrorProxyResponse).
+// Errors are classified with apperrors.Classify, so typed errors keep their code and
+// driver errors are mapped (e.g. mongo.ErrNoDocuments becomes 404). Errors carrying a
+// retry delay (see apperrors.RetryAfter) also set the Retry-After header.
+// The Lambda invocation itself succeeds, so API Gateway forwards the response as-is.
+func ErrorResponse[TIn any](next shared.HandlerFunc[TIn, events.APIGatewayProxyResponse]) shared.HandlerFunc[TIn, events.APIGatewayProxyResponse] {
+	return func(ctx context.Context, input TIn) (events.APIGatewayProxyResponse, error) {
+		output, err := next(ctx, input)
+		if err == nil {
+			return output, nil
+		}
+
+		return apigw.ErrorProxyResponse(err), nil
+	}
+}
@@ -0,0 +1,85 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestErrorResponse_Success(t *testing.T) {
+	handler := middleware.ErrorResponse(func(ctx context.Context, input string) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: input}, nil
+	})
+
+	resp, err := handler(context.Background(), "ok")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Body != "ok" {
+		t.Errorf("Expected response to pass through unchanged, got %+v", resp)
+	}
+}
+
+func TestErrorResponse_MapsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   apperrors.Code
+	}{
+		{"typed conflict", apperrors.Conflict("already exists"), http.StatusConflict, apperrors.CodeConflict},
+		{"mongo not found", mongo.ErrNoDocuments, http.StatusNotFound, apperrors.CodeNotFound},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, apperrors.CodeTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middleware.ErrorResponse(func(ctx context.Context, input string) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{}, tt.err
+			})
+
+			resp, err := handler(context.Background(), "input")
+			if err != nil {
+				t.Fatalf("Expected error to be converted into a response, got '%v'", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if resp.Headers["Content-Type"] != "application/json" {
+				t.Errorf("Expected JSON content type, got '%s'", resp.Headers["Content-Type"])
+			}
+
+			var envelope apperrors.Envelope
+			if err := json.Unmarshal([]byte(resp.Body), &envelope); err != nil {
+				t.Fatalf("Expected JSON envelope, got '%s'", resp.Body)
+			}
+			if envelope.Error.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, envelope.Error.Code)
+			}
+		})
+	}
+}
+
+func TestErrorResponse_RetryAfterHeader(t *testing.T) {
+	handler := middleware.ErrorResponse(func(ctx context.Context, input string) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, apperrors.TooManyRequests("slow down", 30*time.Second)
+	})
+
+	resp, err := handler(context.Background(), "input")
+	if err != nil {
+		t.Fatalf("Expected error to be converted into a response, got '%v'", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if resp.Headers["Retry-After"] != "30" {
+		t.Errorf("Expected Retry-After '30', got '%s'", resp.Headers["Retry-After"])
+	}
+}
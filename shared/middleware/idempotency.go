@@ -0,0 +1,265 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// Compile-time check to ensure Idempotency implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Idempotency[any, any](IdempotencyConfig[any]{})
+
+// IdempotencyKeyHeader is the request header clients use to supply an idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency defaults.
+const (
+	DefaultIdempotencyPrefix  = "idempotency"
+	DefaultIdempotencyTTL     = 24 * time.Hour
+	DefaultIdempotencyLockTTL = 15 * time.Minute
+)
+
+// IdempotencyConfig configures Idempotency.
+type IdempotencyConfig[TIn any] struct {
+	// Key derives the idempotency key of a request. It defaults to DefaultIdempotencyKey.
+	// Requests with an empty key run normally; a key error is returned as-is.
+	Key func(ctx context.Context, input TIn) (string, error)
+	// TTL is how long a completed result is replayed. It defaults to DefaultIdempotencyTTL.
+	TTL time.Duration
+	// LockTTL bounds how long a request is considered in progress, so a crashed
+	// invocation does not block retries forever. It defaults to DefaultIdempotencyLockTTL.
+	LockTTL time.Duration
+	// Prefix namespaces the Redis keys. It defaults to DefaultIdempotencyPrefix followed
+	// by the function name (see functionPrefix), so functions sharing a Redis instance
+	// never replay each other's results. A custom prefix is used as is.
+	Prefix string
+	// DB provides the Redis client. It defaults to db.DefaultProvider().
+	DB db.Clients
+}
+
+// idempotencyStatus is the state of a request stored under its idempotency key.
+type idempotencyStatus string
+
+const (
+	idempotencyInProgress idempotencyStatus = "in_progress"
+	idempotencyCompleted  idempotencyStatus = "completed"
+)
+
+// idempotencyRecord is the JSON value stored under an idempotency key.
+type idempotencyRecord struct {
+	Status idempotencyStatus `json:"status"`
+	// Token identifies the invocation holding an in-progress marker.
+	Token string `json:"token,omitempty"`
+	// Fingerprint is the SHA-256 of the input, used to detect reused keys.
+	Fingerprint string          `json:"fingerprint"`
+	Output      json.RawMessage `json:"output,omitempty"`
+}
+
+// Idempotency is a middleware that makes retried requests safe. The first request with
+// a given idempotency key runs the handler and its output is stored in Redis as JSON;
+// duplicates within cfg.TTL get the stored output without running the handler again.
+//
+// While the first request runs, duplicates get an apperrors.CodeConflict error instead
+// of running concurrently. If the handler fails, the key is released so the request can
+// be retried. Reusing a key with a different input is rejected with a validation error.
+// TOut must round-trip through encoding/json.
+//
+// Place it inside Deadline: Deadline returns without waiting for a handler that overran
+// its budget, and an Idempotency outside it would release the key while that handler
+// still runs, letting a retry run it a second time.
+func Idempotency[TIn, TOut any](cfg IdempotencyConfig[TIn]) shared.MiddlewareFunc[TIn, TOut] {
+	if cfg.Key == nil {
+		cfg.Key = DefaultIdempotencyKey[TIn]
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultIdempotencyTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = DefaultIdempotencyLockTTL
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = functionPrefix(DefaultIdempotencyPrefix)
+	}
+
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			var zero TOut
+
+			key, err := cfg.Key(ctx, input)
+			if err != nil {
+				return zero, err
+			}
+			if key == "" {
+				return next(ctx, input)
+			}
+
+			fingerprint, err := hashInput(input)
+			if err != nil {
+				return zero, err
+			}
+
+			clients := cfg.DB
+			if clients == nil {
+				clients = db.DefaultProvider()
+			}
+
+			client, err := clients.Redis(ctx)
+			if err != nil {
+				return zero, apperrors.Wrap(err, apperrors.CodeUnavailable, "idempotency store unavailable")
+			}
+
+			redisKey := fmt.Sprintf("%s:%s", cfg.Prefix, key)
+			marker, err := json.Marshal(idempotencyRecord{
+				Status:      idempotencyInProgress,
+				Token:       rand.Text(),
+				Fingerprint: fingerprint,
+			})
+			if err != nil {
+				return zero, apperrors.Wrap(err, apperrors.CodeInternal, "failed to encode idempotency record")
+			}
+
+			// Claim the key, or replay the stored result if another invocation owns it.
+			// The loop retries once if the existing record expired in between.
+			for range 2 {
+				claimed, err := client.SetNX(ctx, redisKey, marker, cfg.LockTTL).Result()
+				if err != nil {
+					return zero, apperrors.FromRedis(err)
+				}
+				if claimed {
+					return runIdempotent(ctx, client, cfg.TTL, redisKey, marker, fingerprint, input, next)
+				}
+
+				output, found, err := replayIdempotent[TOut](ctx, client, redisKey, fingerprint)
+				if found || err != nil {
+					return output, err
+				}
+			}
+
+			return zero, apperrors.Conflict("request with this idempotency key is already in progress")
+		}
+	}
+}
+
+// runIdempotent runs the handler for a claimed key and stores its output.
+// On failure the marker is released so the request can be retried. Both only happen while
+// the key still holds this invocation's marker, so a key claimed by another invocation
+// after the marker expired is left alone.
+func runIdempotent[TIn, TOut any](ctx context.Context, client redis.UniversalClient, ttl time.Duration, key string, marker []byte, fingerprint string, input TIn, next shared.HandlerFunc[TIn, TOut]) (TOut, error) {
+	output, err := next(ctx, input)
+
+	// Use a fresh context: the handler may have returned because ctx expired
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+
+	if err != nil {
+		if _, releaseErr := db.CompareAndDelete(storeCtx, client, key, string(marker)); releaseErr != nil {
+			LoggerFrom(ctx).WarnContext(ctx, "Failed to release idempotency key", slog.String("key", key), slog.Any("error", releaseErr))
+		}
+		return output, err
+	}
+
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		LoggerFrom(ctx).WarnContext(ctx, "Failed to encode idempotent result", slog.String("key", key), slog.Any("error", err))
+		return output, nil
+	}
+
+	record, _ := json.Marshal(idempotencyRecord{
+		Status:      idempotencyCompleted,
+		Fingerprint: fingerprint,
+		Output:      encoded,
+	})
+	stored, err := db.CompareAndSet(storeCtx, client, key, string(marker), string(record), ttl)
+	switch {
+	case err != nil:
+		// The handler succeeded; a retry after the marker expires will run it again
+		LoggerFrom(ctx).WarnContext(ctx, "Failed to store idempotent result", slog.String("key", key), slog.Any("error", err))
+	case !stored:
+		LoggerFrom(ctx).WarnContext(ctx, "Idempotency key expired before the result was stored", slog.String("key", key))
+	}
+
+	return output, nil
+}
+
+// replayIdempotent returns the stored output for key. found is false if the key
+// disappeared after the claim failed, in which case the caller may claim it again.
+func replayIdempotent[TOut any](ctx context.Context, client redis.UniversalClient, key, fingerprint string) (output TOut, found bool, err error) {
+	stored, err := client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return output, false, nil
+	}
+	if err != nil {
+		return output, false, apperrors.FromRedis(err)
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(stored, &record); err != nil {
+		return output, false, apperrors.Wrap(err, apperrors.CodeInternal, "failed to decode idempotency record")
+	}
+
+	if record.Fingerprint != fingerprint {
+		return output, true, apperrors.Validation("idempotency key was already used with a different request")
+	}
+	if record.Status != idempotencyCompleted {
+		return output, true, apperrors.Conflict("request with this idempotency key is already in progress")
+	}
+
+	if err := json.Unmarshal(record.Output, &output); err != nil {
+		return output, true, apperrors.Wrap(err, apperrors.CodeInternal, "failed to decode idempotent result")
+	}
+
+	return output, true, nil
+}
+
+// DefaultIdempotencyKey uses the Idempotency-Key header of the API Gateway request in the
+// context (see apigw.FromContext); API requests without the header run normally. Other
+// invocations, such as async retries, are keyed by the SHA-256 hash of the JSON-encoded input.
+func DefaultIdempotencyKey[TIn any](ctx context.Context, input TIn) (string, error) {
+	if req, ok := apigw.FromContext(ctx); ok {
+		return req.Header(IdempotencyKeyHeader), nil
+	}
+	return hashInput(input)
+}
+
+// HeaderIdempotencyKey uses only the Idempotency-Key header, so requests without it
+// run normally. Use it for handlers where identical inputs are legitimately repeated.
+func HeaderIdempotencyKey[TIn any](ctx context.Context, _ TIn) (string, error) {
+	if req, ok := apigw.FromContext(ctx); ok {
+		return req.Header(IdempotencyKeyHeader), nil
+	}
+	return "", nil
+}
+
+// hashInput returns the hex SHA-256 hash of the JSON-encoded input.
+func hashInput(input any) (string, error) {
+	encoded, err := json.Marshal(input)
+	if err != nil {
+		return "", apperrors.Wrap(err, apperrors.CodeInternal, "failed to encode input for idempotency")
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// functionPrefix scopes a default Redis key prefix to the running function
+// (lambdacontext.FunctionName), e.g. "idempotency:GreeterFunction". Outside Lambda the
+// prefix is returned as is.
+func functionPrefix(prefix string) string {
+	if lambdacontext.FunctionName == "" {
+		return prefix
+	}
+	return prefix + ":" + lambdacontext.FunctionName
+}
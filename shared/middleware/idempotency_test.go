@@ -0,0 +1,281 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+type greeting struct {
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+}
+
+// countingHandler returns a handler that greets with an increasing count.
+func countingHandler(calls *atomic.Int64) shared.HandlerFunc[string, *greeting] {
+	return func(ctx context.Context, input string) (*greeting, error) {
+		n := calls.Add(1)
+		return &greeting{Message: fmt.Sprintf("Hello, %s!", input), Count: n}, nil
+	}
+}
+
+func TestIdempotency_ReplaysCompletedRequests(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int64
+
+	handler := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(countingHandler(&calls))
+
+	first, err := handler(context.Background(), "World")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	replayed, err := handler(context.Background(), "World")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if *replayed != *first {
+		t.Errorf("Expected the stored result %+v, got %+v", first, replayed)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls.Load())
+	}
+
+	if _, err := handler(context.Background(), "Gopher"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected a different input to run the handler, ran %d times", calls.Load())
+	}
+}
+
+// setFunctionName sets lambdacontext.FunctionName, as the Lambda runtime does, until the
+// test ends.
+func setFunctionName(t *testing.T, name string) {
+	t.Helper()
+
+	previous := lambdacontext.FunctionName
+	lambdacontext.FunctionName = name
+	t.Cleanup(func() { lambdacontext.FunctionName = previous })
+}
+
+func TestIdempotency_ScopedPerFunction(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var greeterCalls, billingCalls atomic.Int64
+
+	setFunctionName(t, "GreeterFunction")
+	greeter := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(countingHandler(&greeterCalls))
+
+	setFunctionName(t, "BillingFunction")
+	billing := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(countingHandler(&billingCalls))
+
+	for _, handler := range []shared.HandlerFunc[string, *greeting]{greeter, billing, greeter, billing} {
+		if _, err := handler(context.Background(), "World"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	if greeterCalls.Load() != 1 || billingCalls.Load() != 1 {
+		t.Errorf("Expected each function to run its handler once, ran %d and %d times", greeterCalls.Load(), billingCalls.Load())
+	}
+	for _, prefix := range []string{"idempotency:GreeterFunction:", "idempotency:BillingFunction:"} {
+		if keys := mr.Keys(); !hasKeyPrefix(keys, prefix) {
+			t.Errorf("Expected a key with prefix '%s', got %v", prefix, keys)
+		}
+	}
+}
+
+// hasKeyPrefix reports whether any of keys starts with prefix.
+func hasKeyPrefix(keys []string, prefix string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIdempotency_HeaderKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int64
+
+	handler := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(countingHandler(&calls))
+
+	withKey := func(key string) context.Context {
+		return apigw.NewContext(context.Background(), &apigw.Request{
+			Headers: map[string]string{"idempotency-key": key},
+		})
+	}
+
+	for range 2 {
+		if _, err := handler(withKey("abc"), "World"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once for the same key, ran %d times", calls.Load())
+	}
+
+	// API requests without the header are not deduplicated
+	noKey := apigw.NewContext(context.Background(), &apigw.Request{})
+	for range 2 {
+		if _, err := handler(noKey, "World"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected requests without a key to run, ran %d times", calls.Load())
+	}
+
+	// Reusing a key with a different input is rejected
+	if _, err := handler(withKey("abc"), "Gopher"); !apperrors.IsValidation(err) {
+		t.Errorf("Expected a validation error for a reused key, got '%v'", err)
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicateConflicts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(func(ctx context.Context, input string) (*greeting, error) {
+		close(started)
+		<-release
+		return &greeting{Message: "done"}, nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := handler(context.Background(), "World")
+		done <- err
+	}()
+
+	<-started
+	if _, err := handler(context.Background(), "World"); !apperrors.IsConflict(err) {
+		t.Errorf("Expected a conflict error for a concurrent duplicate, got '%v'", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the first request to succeed, got '%v'", err)
+	}
+
+	output, err := handler(context.Background(), "World")
+	if err != nil || output.Message != "done" {
+		t.Errorf("Expected the stored result after completion, got %+v, '%v'", output, err)
+	}
+}
+
+func TestIdempotency_FailureReleasesKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int64
+
+	handler := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(func(ctx context.Context, input string) (*greeting, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("temporary failure")
+		}
+		return &greeting{Message: "ok"}, nil
+	})
+
+	if _, err := handler(context.Background(), "World"); err == nil {
+		t.Fatal("Expected the first attempt to fail")
+	}
+
+	output, err := handler(context.Background(), "World")
+	if err != nil {
+		t.Fatalf("Expected the retry to run, got '%v'", err)
+	}
+	if output.Message != "ok" || calls.Load() != 2 {
+		t.Errorf("Expected the retry to run the handler again, got %+v after %d calls", output, calls.Load())
+	}
+}
+
+func TestIdempotency_KeepsKeyClaimedPastDeadline(t *testing.T) {
+	mr := miniredis.RunT(t)
+	release := make(chan struct{})
+	var calls atomic.Int64
+
+	handler := shared.Compose(func(ctx context.Context, input string) (*greeting, error) {
+		calls.Add(1)
+		<-release
+		return &greeting{Message: "done"}, nil
+	},
+		middleware.Deadline[string, *greeting](10*time.Millisecond),
+		middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+			DB: redisProvider(t, mr),
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := handler(ctx, "World"); !errors.Is(err, middleware.ErrDeadlineExceeded) {
+		t.Fatalf("Expected the first attempt to run out of time, got '%v'", err)
+	}
+
+	// The abandoned handler still runs, so a retry must not run it again
+	if _, err := handler(context.Background(), "World"); !apperrors.IsConflict(err) {
+		t.Errorf("Expected a conflict error while the first handler runs, got '%v'", err)
+	}
+
+	close(release)
+	var output *greeting
+	for range 100 {
+		var err error
+		if output, err = handler(context.Background(), "World"); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if output == nil || output.Message != "done" || calls.Load() != 1 {
+		t.Errorf("Expected the stored result of the single run, got %+v after %d calls", output, calls.Load())
+	}
+}
+
+func TestIdempotency_DoesNotOverwriteAnotherClaim(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	handler := middleware.Idempotency[string, *greeting](middleware.IdempotencyConfig[string]{
+		DB: redisProvider(t, mr),
+	})(func(ctx context.Context, input string) (*greeting, error) {
+		// The marker expires and another invocation claims the key meanwhile
+		for _, key := range mr.Keys() {
+			mr.Set(key, "other")
+		}
+		return &greeting{Message: "done"}, nil
+	})
+
+	if _, err := handler(context.Background(), "World"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	keys := mr.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("Expected one idempotency key, got %v", keys)
+	}
+	if value, _ := mr.Get(keys[0]); value != "other" {
+		t.Errorf("Expected the other invocation's record to be kept, got '%s'", value)
+	}
+}
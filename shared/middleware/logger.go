@@ -4,13 +4,19 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/xarunoba/mlgmr/shared"
 )
 
-// Compile-time check to ensure Logger implements MiddlewareFunc
-var _ shared.MiddlewareFunc[any, any] = Logger[any, any]
+// Compile-time checks to ensure Logger and LoggerWithRedactor implement MiddlewareFunc
+var (
+	_ shared.MiddlewareFunc[any, any] = Logger[any, any]
+	_ shared.MiddlewareFunc[any, any] = LoggerWithRedactor[any, any](DefaultRedactor)
+)
 
 var (
 	loggerInstance *slog.Logger
@@ -19,61 +25,171 @@ var (
 )
 
 // GetLogger returns a singleton slog.Logger instance.
-// It reads the LOG_LEVEL environment variable to set the log level.
+// It is configured from the LOG_LEVEL, LOG_FORMAT, LOG_SOURCE and LOG_OUTPUT
+// environment variables (see LoadLoggerConfig); invalid values are reported once,
+// when the logger is created, and fall back to their defaults.
+// The level can be changed at runtime with SetLogLevel.
 // The logger is safe for concurrent use by multiple goroutines.
+// Handlers should prefer LoggerFrom, which adds the fields of the current request.
 func GetLogger() *slog.Logger {
 	loggerOnce.Do(func() {
-		level := slog.LevelDebug
-
-		if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
-			switch logLevel {
-			case "DEBUG":
-				level = slog.LevelDebug
-			case "WARN":
-				level = slog.LevelWarn
-			case "ERROR":
-				level = slog.LevelError
-			}
+		cfg, err := LoadLoggerConfig()
+		logLevel.Set(cfg.Level)
+
+		if err != nil {
+			// Report regardless of the configured level, so the mistake is noticed
+			NewLogger(cfg, slog.LevelDebug).Warn("Invalid logger configuration, using defaults for invalid values", slog.Any("error", err))
 		}
 
-		loggerInstance = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: level,
-		}))
+		loggerInstance = NewLogger(cfg, &logLevel)
 	})
 
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
 	return loggerInstance
 }
 
+// SetLogger replaces the logger returned by GetLogger, e.g. to capture logs in tests.
+func SetLogger(logger *slog.Logger) {
+	loggerOnce.Do(func() {})
+
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	loggerInstance = logger
+}
+
+type loggerContextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger, which LoggerFrom returns.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFrom returns the request-scoped logger stored in ctx by the Logger middleware,
+// so every log line of a request shares its request ID, function and trace fields.
+// It falls back to GetLogger for contexts without one.
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return GetLogger()
+}
+
+// coldStart is true until the first invocation of this Lambda container starts.
+var coldStart atomic.Bool
+
+func init() {
+	coldStart.Store(true)
+}
+
+type coldStartContextKey struct{}
+
+// invocationColdStart reports whether the current invocation is the first one of this
+// Lambda container. The first middleware to ask records the answer in the returned
+// context, so Logger and Metrics agree on it regardless of their order.
+func invocationColdStart(ctx context.Context) (context.Context, bool) {
+	if cold, ok := ctx.Value(coldStartContextKey{}).(bool); ok {
+		return ctx, cold
+	}
+
+	cold := coldStart.Swap(false)
+	return context.WithValue(ctx, coldStartContextKey{}, cold), cold
+}
+
+// requestAttrs returns the log fields describing the current invocation:
+// the AWS request ID and invoked ARN from lambdacontext, the function name and
+// version, whether this is a cold start, and the X-Ray trace ID.
+func requestAttrs(ctx context.Context, cold bool) []any {
+	attrs := []any{
+		slog.Bool("coldStart", cold),
+	}
+
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		attrs = append(attrs,
+			slog.String("requestId", lc.AwsRequestID),
+			slog.String("invokedFunctionArn", lc.InvokedFunctionArn),
+		)
+	}
+	if lambdacontext.FunctionName != "" {
+		attrs = append(attrs,
+			slog.String("functionName", lambdacontext.FunctionName),
+			slog.String("functionVersion", lambdacontext.FunctionVersion),
+		)
+	}
+	if traceID := XRayTraceID(TraceHeader(ctx)); traceID != "" {
+		attrs = append(attrs, slog.String("traceId", traceID))
+	}
+
+	return attrs
+}
+
+// TraceHeader returns the X-Ray trace header of the current invocation
+// (e.g. "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1").
+// The Lambda runtime stores it in the invocation context and the _X_AMZN_TRACE_ID
+// environment variable.
+func TraceHeader(ctx context.Context) string {
+	if header, ok := ctx.Value("x-amzn-trace-id").(string); ok && header != "" {
+		return header
+	}
+	return os.Getenv("_X_AMZN_TRACE_ID")
+}
+
+// XRayTraceID returns the Root field of an X-Ray trace header, or an empty string.
+func XRayTraceID(header string) string {
+	for field := range strings.SplitSeq(header, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(field), "Root="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
 // Logger is a middleware that logs the input and output of the shared.
 // It wraps a any and returns a new any
 // that logs the input before calling the original handler and logs the output
 // after the handler has been called.
+//
+// It also attaches a request-scoped logger to the context (see LoggerFrom) carrying the
+// request ID, invoked function ARN, function name and version, cold start flag and
+// X-Ray trace ID, so log lines of the handler and inner middlewares share those fields.
+// Inputs, outputs and errors are passed through DefaultRedactor before they are logged.
 func Logger[TIn, TOut any](next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
-	logger := GetLogger()
-
-	return func(ctx context.Context, input TIn) (TOut, error) {
-		// Log the input with structured logging
-		logger.DebugContext(ctx, "Lambda invocation started",
-			slog.Any("input", input),
-		)
+	return LoggerWithRedactor[TIn, TOut](DefaultRedactor)(next)
+}
 
-		// Call the next handler
-		output, err := next(ctx, input)
+// LoggerWithRedactor is like Logger, but redacts inputs, outputs and errors with redactor.
+func LoggerWithRedactor[TIn, TOut any](redactor *Redactor) shared.MiddlewareFunc[TIn, TOut] {
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			ctx, cold := invocationColdStart(ctx)
+			logger := GetLogger().With(requestAttrs(ctx, cold)...)
+			ctx = WithLogger(ctx, logger)
 
-		// Log the output and error (if any) with structured logging
-		if err != nil {
-			logger.ErrorContext(ctx, "Lambda invocation failed",
-				slog.Any("input", input),
-				slog.Any("output", output),
-				slog.Any("error", err),
+			// Log the input with structured logging
+			logger.DebugContext(ctx, "Lambda invocation started",
+				slog.Any("input", redactor.Redact(input)),
 			)
-		} else {
-			logger.DebugContext(ctx, "Lambda invocation completed",
-				slog.Any("input", input),
-				slog.Any("output", output),
-			)
-		}
 
-		return output, err
+			// Call the next handler
+			output, err := next(ctx, input)
+
+			// Log the output and error (if any) with structured logging
+			if err != nil {
+				logger.ErrorContext(ctx, "Lambda invocation failed",
+					slog.Any("input", redactor.Redact(input)),
+					slog.Any("output", redactor.Redact(output)),
+					slog.Any("error", redactor.RedactError(err)),
+				)
+			} else {
+				logger.DebugContext(ctx, "Lambda invocation completed",
+					slog.Any("input", redactor.Redact(input)),
+					slog.Any("output", redactor.Redact(output)),
+				)
+			}
+
+			return output, err
+		}
 	}
 }
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Log formats accepted by LOG_FORMAT.
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+	// LogFormatLogfmt is an alias of LogFormatText, whose key=value output is logfmt.
+	LogFormatLogfmt = "logfmt"
+)
+
+// DefaultLogLevel is used when LOG_LEVEL is not set or invalid.
+const DefaultLogLevel = slog.LevelDebug
+
+// LoggerConfig holds the configuration of the logger returned by GetLogger.
+type LoggerConfig struct {
+	// Level is the minimum level (LOG_LEVEL). See ParseLevel for the accepted values.
+	Level slog.Level
+	// Format is json, text or logfmt (LOG_FORMAT). Empty means json.
+	Format string
+	// AddSource includes the source file and line of each log call (LOG_SOURCE).
+	AddSource bool
+	// Writer receives the log lines (LOG_OUTPUT: stdout or stderr). Nil means os.Stdout.
+	Writer io.Writer
+}
+
+// DefaultLoggerConfig returns the configuration used when no environment variable is set.
+func DefaultLoggerConfig() LoggerConfig {
+	return LoggerConfig{
+		Level:  DefaultLogLevel,
+		Format: LogFormatJSON,
+		Writer: os.Stdout,
+	}
+}
+
+// LoadLoggerConfig reads the logger configuration from the environment, applying
+// DefaultLoggerConfig for variables that are not set. Invalid values keep their
+// default and are reported together in the returned error, so the caller can
+// still log with a usable configuration.
+func LoadLoggerConfig() (LoggerConfig, error) {
+	cfg := DefaultLoggerConfig()
+	var errs []error
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		level, err := ParseLevel(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid LOG_LEVEL: %w", err))
+		} else {
+			cfg.Level = level
+		}
+	}
+
+	if value := os.Getenv("LOG_FORMAT"); value != "" {
+		format := strings.ToLower(strings.TrimSpace(value))
+		switch format {
+		case LogFormatJSON, LogFormatText, LogFormatLogfmt:
+			cfg.Format = format
+		default:
+			errs = append(errs, fmt.Errorf("invalid LOG_FORMAT %q: must be json, text or logfmt", value))
+		}
+	}
+
+	if value := os.Getenv("LOG_SOURCE"); value != "" {
+		addSource, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid LOG_SOURCE %q: must be a boolean", value))
+		} else {
+			cfg.AddSource = addSource
+		}
+	}
+
+	if value := os.Getenv("LOG_OUTPUT"); value != "" {
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "stdout":
+			cfg.Writer = os.Stdout
+		case "stderr":
+			cfg.Writer = os.Stderr
+		default:
+			errs = append(errs, fmt.Errorf("invalid LOG_OUTPUT %q: must be stdout or stderr", value))
+		}
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// ParseLevel parses a log level. Names are case-insensitive and may carry an
+// offset, as in slog.Level.UnmarshalText: "debug", "INFO", "warn", "warning",
+// "error", "ERROR+2". Plain integers are custom numeric levels (e.g. "-8" or "12").
+func ParseLevel(value string) (slog.Level, error) {
+	value = strings.TrimSpace(value)
+
+	if n, err := strconv.Atoi(value); err == nil {
+		return slog.Level(n), nil
+	}
+
+	// Accept "warning" as a synonym of "warn", keeping any offset
+	if rest, ok := cutPrefixFold(value, "warning"); ok {
+		value = "WARN" + rest
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown level %q: must be debug, info, warn, error, a name with an offset such as error+2, or an integer", value)
+	}
+
+	return level, nil
+}
+
+// cutPrefixFold is strings.CutPrefix with case-insensitive matching.
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// NewLogger creates a logger from cfg. The level is read through leveler, so it can
+// be changed at runtime when leveler is a *slog.LevelVar; a nil leveler uses cfg.Level.
+func NewLogger(cfg LoggerConfig, leveler slog.Leveler) *slog.Logger {
+	if leveler == nil {
+		leveler = cfg.Level
+	}
+
+	writer := cfg.Writer
+	if writer == nil {
+		writer = os.Stdout
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:     leveler,
+		AddSource: cfg.AddSource,
+	}
+
+	switch cfg.Format {
+	case LogFormatText, LogFormatLogfmt:
+		return slog.New(slog.NewTextHandler(writer, opts))
+	default:
+		return slog.New(slog.NewJSONHandler(writer, opts))
+	}
+}
+
+// logLevel is the level of the logger returned by GetLogger.
+var logLevel slog.LevelVar
+
+// SetLogLevel changes the level of the logger returned by GetLogger at runtime.
+func SetLogLevel(level slog.Level) {
+	logLevel.Set(level)
+}
+
+// LogLevel returns the current level of the logger returned by GetLogger.
+func LogLevel() slog.Level {
+	return logLevel.Level()
+}
@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+// loggerConfigEnv lists every variable read by LoadLoggerConfig.
+var loggerConfigEnv = []string{"LOG_LEVEL", "LOG_FORMAT", "LOG_SOURCE", "LOG_OUTPUT"}
+
+func setLoggerConfigEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for _, name := range loggerConfigEnv {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{value: "debug", expected: slog.LevelDebug},
+		{value: "INFO", expected: slog.LevelInfo},
+		{value: "Warn", expected: slog.LevelWarn},
+		{value: "warning", expected: slog.LevelWarn},
+		{value: "error", expected: slog.LevelError},
+		{value: " error ", expected: slog.LevelError},
+		{value: "error+2", expected: slog.LevelError + 2},
+		{value: "debug-4", expected: slog.LevelDebug - 4},
+		{value: "12", expected: slog.Level(12)},
+		{value: "-8", expected: slog.Level(-8)},
+		{value: "verbose", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			level, err := middleware.ParseLevel(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for '%s', got level %v", tt.value, level)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			if level != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, level)
+			}
+		})
+	}
+}
+
+func TestLoadLoggerConfig(t *testing.T) {
+	setLoggerConfigEnv(t, map[string]string{
+		"LOG_LEVEL":  "info",
+		"LOG_FORMAT": "LOGFMT",
+		"LOG_SOURCE": "true",
+		"LOG_OUTPUT": "stderr",
+	})
+
+	cfg, err := middleware.LoadLoggerConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if cfg.Level != slog.LevelInfo || cfg.Format != middleware.LogFormatLogfmt || !cfg.AddSource || cfg.Writer != os.Stderr {
+		t.Errorf("Unexpected configuration %+v", cfg)
+	}
+}
+
+func TestLoadLoggerConfig_InvalidValues(t *testing.T) {
+	setLoggerConfigEnv(t, map[string]string{
+		"LOG_LEVEL":  "verbose",
+		"LOG_FORMAT": "xml",
+		"LOG_SOURCE": "maybe",
+		"LOG_OUTPUT": "file",
+	})
+
+	cfg, err := middleware.LoadLoggerConfig()
+	if err == nil {
+		t.Fatal("Expected an error for invalid values")
+	}
+	for _, name := range loggerConfigEnv {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected the error to mention %s, got '%v'", name, err)
+		}
+	}
+
+	defaults := middleware.DefaultLoggerConfig()
+	if cfg.Level != defaults.Level || cfg.Format != defaults.Format || cfg.AddSource || cfg.Writer != defaults.Writer {
+		t.Errorf("Expected invalid values to keep their defaults, got %+v", cfg)
+	}
+}
+
+func TestNewLogger_FormatsAndLevelVar(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: middleware.LogFormatJSON, expected: `"msg":"hello"`},
+		{format: middleware.LogFormatText, expected: "msg=hello"},
+		{format: middleware.LogFormatLogfmt, expected: "msg=hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			var level slog.LevelVar
+			level.Set(slog.LevelWarn)
+
+			logger := middleware.NewLogger(middleware.LoggerConfig{Format: tt.format, Writer: &buf}, &level)
+
+			logger.Info("hidden")
+			if buf.Len() != 0 {
+				t.Errorf("Expected info to be filtered at warn level, got '%s'", buf.String())
+			}
+
+			level.Set(slog.LevelInfo)
+			logger.Info("hello")
+			if !strings.Contains(buf.String(), tt.expected) {
+				t.Errorf("Expected output to contain '%s', got '%s'", tt.expected, buf.String())
+			}
+		})
+	}
+}
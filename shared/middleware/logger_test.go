@@ -0,0 +1,118 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+// captureLogs makes GetLogger write JSON debug logs to the returned buffer for the
+// duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	previous := middleware.GetLogger()
+	t.Cleanup(func() { middleware.SetLogger(previous) })
+
+	var buf bytes.Buffer
+	middleware.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	return &buf
+}
+
+// decodeLogs decodes one JSON object per log line.
+func decodeLogs(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for line := range strings.SplitSeq(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected JSON log line, got '%s'", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestLogger_RequestScopedFields(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID:       "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+		InvokedFunctionArn: "arn:aws:lambda:us-east-1:123456789012:function:greeter",
+	})
+	ctx = context.WithValue(ctx, "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+
+	handler := middleware.Logger(func(ctx context.Context, input string) (string, error) {
+		middleware.LoggerFrom(ctx).InfoContext(ctx, "Inside handler")
+		return "ok", nil
+	})
+
+	for range 2 {
+		if _, err := handler(ctx, "input"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	lines := decodeLogs(t, buf)
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 log lines, got %d", len(lines))
+	}
+
+	for _, line := range lines {
+		if line["requestId"] != "c6af9ac6-7b61-11e6-9a41-93e8deadbeef" {
+			t.Errorf("Expected request ID on every line, got %v", line)
+		}
+		if line["invokedFunctionArn"] != "arn:aws:lambda:us-east-1:123456789012:function:greeter" {
+			t.Errorf("Expected invoked ARN on every line, got %v", line)
+		}
+		if line["traceId"] != "1-5759e988-bd862e3fe1be46a994272793" {
+			t.Errorf("Expected trace ID on every line, got %v", line)
+		}
+	}
+
+	if lines[1]["msg"] != "Inside handler" {
+		t.Errorf("Expected the handler's log line to use the request logger, got %v", lines[1])
+	}
+	if lines[3]["coldStart"] != false {
+		t.Errorf("Expected coldStart false after the first invocation, got %v", lines[3]["coldStart"])
+	}
+}
+
+func TestLoggerFrom_FallsBackToGetLogger(t *testing.T) {
+	if middleware.LoggerFrom(context.Background()) != middleware.GetLogger() {
+		t.Error("Expected LoggerFrom to return GetLogger without a request logger")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	ctx := middleware.WithLogger(context.Background(), logger)
+	if middleware.LoggerFrom(ctx) != logger {
+		t.Error("Expected LoggerFrom to return the logger stored in the context")
+	}
+}
+
+func TestXRayTraceID(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1", "1-5759e988-bd862e3fe1be46a994272793"},
+		{"Parent=53995c3f42cd8ad8; Root=1-abc;Sampled=0", "1-abc"},
+		{"Parent=53995c3f42cd8ad8", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := middleware.XRayTraceID(tt.header); got != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
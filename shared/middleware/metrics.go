@@ -0,0 +1,366 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// Compile-time check to ensure Metrics implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Metrics[any, any](MetricsConfig{})
+
+// DefaultMetricsNamespace is the CloudWatch namespace used when neither
+// MetricsConfig.Namespace nor the METRICS_NAMESPACE environment variable is set.
+const DefaultMetricsNamespace = "mlgmr"
+
+// Names of the metrics recorded by the Metrics middleware for every invocation.
+const (
+	// MetricInvocations is 1 for every invocation.
+	MetricInvocations = "Invocations"
+	// MetricSuccesses is 1 if the handler succeeded and 0 otherwise.
+	MetricSuccesses = "Successes"
+	// MetricErrors is 1 if the handler failed with a server error (an apperrors code
+	// with a 5xx status: CodeInternal, CodeUnavailable or CodeTimeout) and 0 otherwise.
+	MetricErrors = "Errors"
+	// MetricClientErrors is 1 if the handler rejected the request with any other error
+	// code, such as a validation error or a rate limit, and 0 otherwise.
+	MetricClientErrors = "ClientErrors"
+	// MetricColdStart is 1 and only recorded for the first invocation of a container.
+	MetricColdStart = "ColdStart"
+	// MetricDuration is the handler duration in milliseconds.
+	MetricDuration = "Duration"
+)
+
+// MetricUnit is the CloudWatch unit of a metric.
+type MetricUnit string
+
+// Common CloudWatch metric units.
+const (
+	UnitNone           MetricUnit = "None"
+	UnitCount          MetricUnit = "Count"
+	UnitCountPerSecond MetricUnit = "Count/Second"
+	UnitPercent        MetricUnit = "Percent"
+	UnitMicroseconds   MetricUnit = "Microseconds"
+	UnitMilliseconds   MetricUnit = "Milliseconds"
+	UnitSeconds        MetricUnit = "Seconds"
+	UnitBytes          MetricUnit = "Bytes"
+	UnitKilobytes      MetricUnit = "Kilobytes"
+	UnitMegabytes      MetricUnit = "Megabytes"
+)
+
+// CloudWatch limits of a single EMF document.
+const (
+	maxEMFMetrics = 100
+	maxEMFValues  = 100
+)
+
+// MetricsConfig configures Metrics.
+type MetricsConfig struct {
+	// Namespace is the CloudWatch namespace of the metrics. It defaults to the
+	// METRICS_NAMESPACE environment variable, then DefaultMetricsNamespace.
+	Namespace string
+	// Dimensions are added to every metric. A nil map defaults to DefaultMetricsDimensions;
+	// use an empty map to publish metrics without dimensions.
+	Dimensions map[string]string
+	// Output receives one EMF JSON document per line. It defaults to os.Stdout, which
+	// Lambda forwards to CloudWatch Logs.
+	Output io.Writer
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// DefaultMetricsDimensions returns the FunctionName and FunctionVersion dimensions of the
+// running Lambda function, or an empty map outside Lambda.
+func DefaultMetricsDimensions() map[string]string {
+	dimensions := map[string]string{}
+	if lambdacontext.FunctionName != "" {
+		dimensions["FunctionName"] = lambdacontext.FunctionName
+	}
+	if lambdacontext.FunctionVersion != "" {
+		dimensions["FunctionVersion"] = lambdacontext.FunctionVersion
+	}
+	return dimensions
+}
+
+// metricsOutputMu serializes writes so documents of concurrent invocations never interleave.
+var metricsOutputMu sync.Mutex
+
+// Metrics is a middleware that records invocation metrics and writes them as CloudWatch
+// Embedded Metric Format (EMF) JSON lines when the invocation ends. CloudWatch extracts
+// the metrics from the function logs, so no API calls are made during the request.
+//
+// Every invocation records MetricInvocations, MetricSuccesses, MetricErrors,
+// MetricClientErrors and MetricDuration, plus MetricColdStart on the first invocation
+// of a container. Errors are classified with apperrors.Classify, so only server-side
+// failures count as MetricErrors and alarms on it ignore rejected requests.
+// Handlers add their own metrics, dimensions and properties through MetricsFrom.
+// The request ID, X-Ray trace ID and error code are attached as properties, so
+// the documents can be correlated with the logs.
+//
+// Place it inside Logger and outside Recover, so panics are counted as errors.
+func Metrics[TIn, TOut any](cfg MetricsConfig) shared.MiddlewareFunc[TIn, TOut] {
+	if cfg.Namespace == "" {
+		cfg.Namespace = os.Getenv("METRICS_NAMESPACE")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultMetricsNamespace
+	}
+	if cfg.Dimensions == nil {
+		cfg.Dimensions = DefaultMetricsDimensions()
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			ctx, cold := invocationColdStart(ctx)
+
+			recorder := newMetricsRecorder(cfg.Dimensions)
+			ctx = WithMetrics(ctx, recorder)
+
+			if lc, ok := lambdacontext.FromContext(ctx); ok {
+				recorder.AddProperty("requestId", lc.AwsRequestID)
+			}
+			if traceID := XRayTraceID(TraceHeader(ctx)); traceID != "" {
+				recorder.AddProperty("traceId", traceID)
+			}
+
+			start := cfg.Now()
+			output, err := next(ctx, input)
+			duration := cfg.Now().Sub(start)
+
+			recorder.Add(MetricInvocations, UnitCount, 1)
+			if err != nil {
+				code := apperrors.Classify(err).Code
+				serverError := code.Status() >= 500
+
+				recorder.Add(MetricSuccesses, UnitCount, 0)
+				recorder.Add(MetricErrors, UnitCount, countIf(serverError))
+				recorder.Add(MetricClientErrors, UnitCount, countIf(!serverError))
+				recorder.AddProperty("errorCode", string(code))
+			} else {
+				recorder.Add(MetricSuccesses, UnitCount, 1)
+				recorder.Add(MetricErrors, UnitCount, 0)
+				recorder.Add(MetricClientErrors, UnitCount, 0)
+			}
+			if cold {
+				recorder.Add(MetricColdStart, UnitCount, 1)
+			}
+			recorder.Add(MetricDuration, UnitMilliseconds, float64(duration.Microseconds())/1000)
+
+			if flushErr := recorder.flush(cfg.Output, cfg.Namespace, start); flushErr != nil {
+				LoggerFrom(ctx).WarnContext(ctx, "Failed to write metrics", slog.Any("error", flushErr))
+			}
+
+			return output, err
+		}
+	}
+}
+
+// countIf returns 1 if ok is true and 0 otherwise.
+func countIf(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+type metricsContextKey struct{}
+
+// WithMetrics returns a copy of ctx carrying recorder, which MetricsFrom returns.
+func WithMetrics(ctx context.Context, recorder *MetricsRecorder) context.Context {
+	return context.WithValue(ctx, metricsContextKey{}, recorder)
+}
+
+// MetricsFrom returns the recorder of the current invocation stored in ctx by the
+// Metrics middleware. It returns nil for contexts without one; a nil recorder
+// discards everything, so handlers can record metrics unconditionally.
+func MetricsFrom(ctx context.Context) *MetricsRecorder {
+	recorder, _ := ctx.Value(metricsContextKey{}).(*MetricsRecorder)
+	return recorder
+}
+
+// recordedMetric holds the values recorded for one metric name.
+type recordedMetric struct {
+	name   string
+	unit   MetricUnit
+	values []float64
+}
+
+// MetricsRecorder collects the metrics of one invocation. It is safe for concurrent use.
+// Metric, dimension and property names share one namespace in the EMF document, so
+// they must not collide.
+type MetricsRecorder struct {
+	mu         sync.Mutex
+	metrics    []*recordedMetric
+	dimensions map[string]string
+	properties map[string]any
+}
+
+func newMetricsRecorder(dimensions map[string]string) *MetricsRecorder {
+	return &MetricsRecorder{
+		dimensions: maps.Clone(dimensions),
+		properties: map[string]any{},
+	}
+}
+
+// Add records a value for the named metric. Recording the same metric several times
+// publishes every value; the unit of the first call is kept.
+func (r *MetricsRecorder) Add(name string, unit MetricUnit, value float64) {
+	if r == nil || name == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.metrics {
+		if m.name == name {
+			m.values = append(m.values, value)
+			return
+		}
+	}
+	r.metrics = append(r.metrics, &recordedMetric{name: name, unit: unit, values: []float64{value}})
+}
+
+// Count records a count of 1 for the named metric.
+func (r *MetricsRecorder) Count(name string) {
+	r.Add(name, UnitCount, 1)
+}
+
+// AddDimension adds a dimension to every metric of the invocation. Each distinct
+// dimension value is a separate CloudWatch metric, so avoid high-cardinality values
+// such as user IDs; record those with AddProperty instead.
+func (r *MetricsRecorder) AddDimension(name, value string) {
+	if r == nil || name == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dimensions[name] = value
+}
+
+// AddProperty attaches a value to the EMF document that is searchable in
+// CloudWatch Logs Insights but is not a metric or dimension.
+func (r *MetricsRecorder) AddProperty(name string, value any) {
+	if r == nil || name == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.properties[name] = value
+}
+
+// emfMetric is a metric definition in the EMF metadata.
+type emfMetric struct {
+	Name string     `json:"Name"`
+	Unit MetricUnit `json:"Unit,omitempty"`
+}
+
+// emfDirective tells CloudWatch which members of the document are metrics.
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+// emfMetadata is the "_aws" member of an EMF document.
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// flush writes the recorded metrics to w, one EMF document per line.
+func (r *MetricsRecorder) flush(w io.Writer, namespace string, timestamp time.Time) error {
+	var lines []byte
+	for _, document := range r.documents(namespace, timestamp) {
+		encoded, err := json.Marshal(document)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, encoded...), '\n')
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	metricsOutputMu.Lock()
+	defer metricsOutputMu.Unlock()
+
+	_, err := w.Write(lines)
+	return err
+}
+
+// documents builds the EMF documents of the recorded metrics. Metrics beyond the
+// CloudWatch limits of 100 metrics per document and 100 values per metric are
+// carried over to additional documents.
+func (r *MetricsRecorder) documents(namespace string, timestamp time.Time) []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dimensionNames := slices.Sorted(maps.Keys(r.dimensions))
+	if dimensionNames == nil {
+		dimensionNames = []string{}
+	}
+
+	pending := r.metrics
+	var documents []map[string]any
+	for len(pending) > 0 {
+		document := make(map[string]any, len(r.properties)+len(r.dimensions)+len(pending)+1)
+		maps.Copy(document, r.properties)
+		for name, value := range r.dimensions {
+			document[name] = value
+		}
+
+		var definitions []emfMetric
+		var rest []*recordedMetric
+		for _, m := range pending {
+			if len(definitions) == maxEMFMetrics {
+				rest = append(rest, m)
+				continue
+			}
+
+			n := min(len(m.values), maxEMFValues)
+			definitions = append(definitions, emfMetric{Name: m.name, Unit: m.unit})
+			if n == 1 {
+				document[m.name] = m.values[0]
+			} else {
+				document[m.name] = m.values[:n]
+			}
+			if n < len(m.values) {
+				rest = append(rest, &recordedMetric{name: m.name, unit: m.unit, values: m.values[n:]})
+			}
+		}
+
+		document["_aws"] = emfMetadata{
+			Timestamp: timestamp.UnixMilli(),
+			CloudWatchMetrics: []emfDirective{{
+				Namespace:  namespace,
+				Dimensions: [][]string{dimensionNames},
+				Metrics:    definitions,
+			}},
+		}
+		documents = append(documents, document)
+		pending = rest
+	}
+
+	return documents
+}
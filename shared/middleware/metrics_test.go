@@ -0,0 +1,282 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+// steppingClock returns start, then advances by step on every call.
+func steppingClock(start time.Time, step time.Duration) func() time.Time {
+	now := start.Add(-step)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// emfDirective returns the single CloudWatchMetrics directive of an EMF document.
+func emfDirective(t *testing.T, document map[string]any) map[string]any {
+	t.Helper()
+
+	metadata, ok := document["_aws"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected _aws metadata, got %v", document)
+	}
+	directives, ok := metadata["CloudWatchMetrics"].([]any)
+	if !ok || len(directives) != 1 {
+		t.Fatalf("Expected one CloudWatchMetrics directive, got %v", metadata["CloudWatchMetrics"])
+	}
+	return directives[0].(map[string]any)
+}
+
+// emfUnits maps the metric names of a directive to their units.
+func emfUnits(directive map[string]any) map[string]string {
+	units := map[string]string{}
+	for _, definition := range directive["Metrics"].([]any) {
+		metric := definition.(map[string]any)
+		units[metric["Name"].(string)] = metric["Unit"].(string)
+	}
+	return units
+}
+
+func TestMetrics_EmitsEMFDocument(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+	})
+	ctx = context.WithValue(ctx, "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+
+	handler := middleware.Metrics[string, string](middleware.MetricsConfig{
+		Namespace:  "Greeter",
+		Dimensions: map[string]string{"FunctionName": "greeter", "FunctionVersion": "$LATEST"},
+		Output:     &out,
+		Now:        steppingClock(start, 25*time.Millisecond),
+	})(func(ctx context.Context, input string) (string, error) {
+		metrics := middleware.MetricsFrom(ctx)
+		metrics.Add("NameLength", middleware.UnitBytes, float64(len(input)))
+		metrics.Count("Greetings")
+		metrics.Count("Greetings")
+		metrics.AddDimension("Route", "greet")
+		metrics.AddProperty("name", input)
+		return "ok", nil
+	})
+
+	if _, err := handler(ctx, "Ada"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	documents := decodeLogs(t, &out)
+	if len(documents) != 1 {
+		t.Fatalf("Expected 1 EMF document, got %d", len(documents))
+	}
+	document := documents[0]
+
+	if timestamp := document["_aws"].(map[string]any)["Timestamp"]; timestamp != float64(start.UnixMilli()) {
+		t.Errorf("Expected timestamp %d, got %v", start.UnixMilli(), timestamp)
+	}
+
+	directive := emfDirective(t, document)
+	if directive["Namespace"] != "Greeter" {
+		t.Errorf("Expected namespace 'Greeter', got '%v'", directive["Namespace"])
+	}
+	if dimensions := fmt.Sprint(directive["Dimensions"]); dimensions != "[[FunctionName FunctionVersion Route]]" {
+		t.Errorf("Expected sorted dimension set, got %s", dimensions)
+	}
+
+	expectedUnits := map[string]string{
+		"NameLength":                  "Bytes",
+		"Greetings":                   "Count",
+		middleware.MetricInvocations:  "Count",
+		middleware.MetricSuccesses:    "Count",
+		middleware.MetricErrors:       "Count",
+		middleware.MetricClientErrors: "Count",
+		middleware.MetricDuration:     "Milliseconds",
+	}
+	units := emfUnits(directive)
+	for name, unit := range expectedUnits {
+		if units[name] != unit {
+			t.Errorf("Expected metric %s with unit %s, got '%s'", name, unit, units[name])
+		}
+	}
+
+	expectedValues := map[string]any{
+		"FunctionName":                "greeter",
+		"FunctionVersion":             "$LATEST",
+		"Route":                       "greet",
+		"name":                        "Ada",
+		"requestId":                   "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+		"traceId":                     "1-5759e988-bd862e3fe1be46a994272793",
+		"NameLength":                  float64(3),
+		"Greetings":                   []any{float64(1), float64(1)},
+		middleware.MetricInvocations:  float64(1),
+		middleware.MetricSuccesses:    float64(1),
+		middleware.MetricErrors:       float64(0),
+		middleware.MetricClientErrors: float64(0),
+		middleware.MetricDuration:     float64(25),
+	}
+	for key, expected := range expectedValues {
+		if fmt.Sprint(document[key]) != fmt.Sprint(expected) {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, document[key])
+		}
+	}
+}
+
+func TestMetrics_RecordsErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		wantCode         apperrors.Code
+		wantErrors       float64
+		wantClientErrors float64
+	}{
+		{"not found", apperrors.NotFound("user not found"), apperrors.CodeNotFound, 0, 1},
+		{"validation", apperrors.Validation("name is required"), apperrors.CodeValidation, 0, 1},
+		{"rate limited", apperrors.TooManyRequests("slow down", time.Second), apperrors.CodeTooManyRequests, 0, 1},
+		{"unavailable", apperrors.Unavailable("database unavailable"), apperrors.CodeUnavailable, 1, 0},
+		{"untyped", errors.New("boom"), apperrors.CodeInternal, 1, 0},
+		{"driver timeout", context.DeadlineExceeded, apperrors.CodeTimeout, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			handler := middleware.Metrics[string, string](middleware.MetricsConfig{
+				Dimensions: map[string]string{},
+				Output:     &out,
+			})(func(ctx context.Context, input string) (string, error) {
+				return "", tt.err
+			})
+
+			if _, err := handler(context.Background(), "Ada"); !errors.Is(err, tt.err) {
+				t.Fatalf("Expected the handler error to be returned, got '%v'", err)
+			}
+
+			document := decodeLogs(t, &out)[0]
+			if document[middleware.MetricErrors] != tt.wantErrors {
+				t.Errorf("Expected Errors %v, got %v", tt.wantErrors, document[middleware.MetricErrors])
+			}
+			if document[middleware.MetricClientErrors] != tt.wantClientErrors {
+				t.Errorf("Expected ClientErrors %v, got %v", tt.wantClientErrors, document[middleware.MetricClientErrors])
+			}
+			if document[middleware.MetricSuccesses] != float64(0) {
+				t.Errorf("Expected Successes 0, got %v", document[middleware.MetricSuccesses])
+			}
+			if document["errorCode"] != string(tt.wantCode) {
+				t.Errorf("Expected errorCode property '%s', got '%v'", tt.wantCode, document["errorCode"])
+			}
+
+			directive := emfDirective(t, document)
+			if directive["Namespace"] != middleware.DefaultMetricsNamespace {
+				t.Errorf("Expected default namespace '%s', got '%v'", middleware.DefaultMetricsNamespace, directive["Namespace"])
+			}
+			if dimensions := fmt.Sprint(directive["Dimensions"]); dimensions != "[[]]" {
+				t.Errorf("Expected an empty dimension set, got %s", dimensions)
+			}
+		})
+	}
+}
+
+func TestMetrics_ColdStartMatchesLogger(t *testing.T) {
+	logs := captureLogs(t)
+	var out bytes.Buffer
+
+	handler := middleware.Logger(middleware.Metrics[string, string](middleware.MetricsConfig{
+		Output: &out,
+	})(okHandler))
+
+	for range 2 {
+		if _, err := handler(context.Background(), "input"); err != nil {
+			t.Fatalf("Expected no error, got '%v'", err)
+		}
+	}
+
+	lines := decodeLogs(t, logs)
+	documents := decodeLogs(t, &out)
+	if len(documents) != 2 {
+		t.Fatalf("Expected 2 EMF documents, got %d", len(documents))
+	}
+
+	for i, document := range documents {
+		cold := lines[i*2]["coldStart"] == true
+		_, recorded := emfUnits(emfDirective(t, document))[middleware.MetricColdStart]
+		if recorded != cold {
+			t.Errorf("Expected ColdStart metric %v to match the logged coldStart %v on invocation %d", recorded, cold, i+1)
+		}
+	}
+	if _, ok := documents[1][middleware.MetricColdStart]; ok {
+		t.Error("Expected no ColdStart metric on the second invocation")
+	}
+}
+
+func TestMetrics_SplitsLargeDocuments(t *testing.T) {
+	var out bytes.Buffer
+
+	handler := middleware.Metrics[string, string](middleware.MetricsConfig{
+		Dimensions: map[string]string{"Service": "greeter"},
+		Output:     &out,
+	})(func(ctx context.Context, input string) (string, error) {
+		for i := range 120 {
+			middleware.MetricsFrom(ctx).Count(fmt.Sprintf("Metric%d", i))
+		}
+		for range 150 {
+			middleware.MetricsFrom(ctx).Count("Metric0")
+		}
+		return "ok", nil
+	})
+
+	if _, err := handler(context.Background(), "input"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	documents := decodeLogs(t, &out)
+	if len(documents) != 2 {
+		t.Fatalf("Expected 2 EMF documents, got %d", len(documents))
+	}
+
+	first := emfUnits(emfDirective(t, documents[0]))
+	if len(first) != 100 {
+		t.Errorf("Expected 100 metrics in the first document, got %d", len(first))
+	}
+	if values := documents[0]["Metric0"].([]any); len(values) != 100 {
+		t.Errorf("Expected 100 values of Metric0 in the first document, got %d", len(values))
+	}
+
+	// 20 custom metrics, 5 built-in ones (ColdStart depends on test order) and the
+	// remaining values of Metric0
+	second := emfUnits(emfDirective(t, documents[1]))
+	delete(second, middleware.MetricColdStart)
+	if len(second) != 26 {
+		t.Errorf("Expected 26 metrics in the second document, got %d", len(second))
+	}
+	if values := documents[1]["Metric0"].([]any); len(values) != 51 {
+		t.Errorf("Expected 51 values of Metric0 in the second document, got %d", len(values))
+	}
+	for _, document := range documents {
+		if document["Service"] != "greeter" {
+			t.Errorf("Expected dimensions in every document, got %v", document["Service"])
+		}
+	}
+}
+
+func TestMetricsFrom_WithoutRecorder(t *testing.T) {
+	metrics := middleware.MetricsFrom(context.Background())
+	if metrics != nil {
+		t.Fatalf("Expected no recorder, got %v", metrics)
+	}
+
+	// A nil recorder discards everything
+	metrics.Count("Greetings")
+	metrics.AddDimension("Route", "greet")
+	metrics.AddProperty("name", "Ada")
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// Compile-time check to ensure RateLimit implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = RateLimit[any, any](RateLimitConfig[any]{})
+
+// RateLimitAlgorithm selects how RateLimit counts requests.
+type RateLimitAlgorithm int
+
+const (
+	// SlidingWindow allows at most Limit requests in any Window-long period.
+	// It keeps one sorted set entry per request, so it is exact but uses memory per request.
+	SlidingWindow RateLimitAlgorithm = iota
+	// TokenBucket allows bursts of up to Limit requests and refills Limit tokens per Window.
+	// It keeps two numbers per key.
+	TokenBucket
+)
+
+// DefaultRateLimitPrefix is the Redis key prefix used when RateLimitConfig.Prefix is empty.
+const DefaultRateLimitPrefix = "ratelimit"
+
+// RateLimitConfig configures RateLimit.
+type RateLimitConfig[TIn any] struct {
+	// Algorithm selects the counting algorithm. The default is SlidingWindow.
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window. Values below 1 disable limiting.
+	Limit int64
+	// Window is the period Limit applies to. Zero disables limiting; RateLimit panics if
+	// it is positive but below 1ms, the resolution of the counters.
+	Window time.Duration
+	// Key identifies the caller, e.g. SourceIPKey or a field of the input.
+	// Requests with an empty key are not limited; a key error is returned as-is.
+	Key func(ctx context.Context, input TIn) (string, error)
+	// Prefix namespaces the Redis keys. It defaults to DefaultRateLimitPrefix followed by
+	// the function name (see functionPrefix), so each function has its own limits.
+	// A custom prefix is used as is, e.g. to share limits across functions.
+	Prefix string
+	// FailOpen lets requests through when Redis is unavailable. By default they are
+	// rejected with an apperrors.CodeUnavailable error.
+	FailOpen bool
+	// DB provides the Redis client. It defaults to db.DefaultProvider().
+	DB db.Clients
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// slidingWindowScript keeps one member per request scored by its time in milliseconds.
+// KEYS[1] is the key; ARGV is now, window, limit and a unique member.
+// It returns {allowed, remaining, retry after in milliseconds}.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now}
+`)
+
+// tokenBucketScript stores the token count and the time of the last refill.
+// KEYS[1] is the key; ARGV is now, window and limit (the bucket capacity).
+// It returns {allowed, remaining, retry after in milliseconds}.
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local rate = limit / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// RateLimit is a middleware that throttles callers using counters in Redis.
+// Each caller, identified by cfg.Key, may make cfg.Limit requests per cfg.Window.
+// Rejected requests get an apperrors.CodeTooManyRequests error whose details tell
+// the caller when to retry (see apperrors.RetryAfter). The counting runs in a Lua
+// script, so concurrent invocations share the limit atomically.
+// When Redis is unavailable, requests are rejected unless cfg.FailOpen is set.
+func RateLimit[TIn, TOut any](cfg RateLimitConfig[TIn]) shared.MiddlewareFunc[TIn, TOut] {
+	if cfg.Window > 0 && cfg.Window < time.Millisecond {
+		// The scripts count in milliseconds; a zero window would divide by zero and expire keys at once
+		panic(fmt.Sprintf("middleware: rate limit window must be at least 1ms, got %v", cfg.Window))
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = functionPrefix(DefaultRateLimitPrefix)
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			var zero TOut
+
+			if cfg.Limit < 1 || cfg.Window <= 0 || cfg.Key == nil {
+				return next(ctx, input)
+			}
+
+			key, err := cfg.Key(ctx, input)
+			if err != nil {
+				return zero, err
+			}
+			if key == "" {
+				return next(ctx, input)
+			}
+
+			allowed, retryAfter, err := cfg.allow(ctx, key)
+			if err != nil {
+				if cfg.FailOpen {
+					LoggerFrom(ctx).WarnContext(ctx, "Rate limiter unavailable, allowing request", slog.Any("error", err))
+					return next(ctx, input)
+				}
+				return zero, apperrors.Wrap(err, apperrors.CodeUnavailable, "rate limiter unavailable")
+			}
+
+			if !allowed {
+				return zero, apperrors.TooManyRequests("rate limit exceeded", retryAfter)
+			}
+
+			return next(ctx, input)
+		}
+	}
+}
+
+// allow counts a request for key and reports whether it is within the limit.
+// For rejected requests it also returns how long the caller should wait.
+func (cfg RateLimitConfig[TIn]) allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	clients := cfg.DB
+	if clients == nil {
+		clients = db.DefaultProvider()
+	}
+
+	client, err := clients.Redis(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+
+	now := cfg.Now().UnixMilli()
+	window := cfg.Window.Milliseconds()
+	redisKey := fmt.Sprintf("%s:%s", cfg.Prefix, key)
+
+	var result []int64
+	switch cfg.Algorithm {
+	case TokenBucket:
+		result, err = tokenBucketScript.Run(ctx, client, []string{redisKey}, now, window, cfg.Limit).Int64Slice()
+	default:
+		member := strconv.FormatInt(now, 10) + "-" + strconv.FormatUint(rand.Uint64(), 36)
+		result, err = slidingWindowScript.Run(ctx, client, []string{redisKey}, now, window, cfg.Limit, member).Int64Slice()
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if len(result) != 3 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	return result[0] == 1, time.Duration(result[2]) * time.Millisecond, nil
+}
+
+// SourceIPKey identifies callers by the source IP of the API Gateway request in the
+// context (see apigw.FromContext). Invocations without a request are not limited.
+func SourceIPKey[TIn any](ctx context.Context, _ TIn) (string, error) {
+	if req, ok := apigw.FromContext(ctx); ok {
+		return req.SourceIP, nil
+	}
+	return "", nil
+}
+
+// HeaderKey identifies callers by the value of the named request header, e.g. an API key.
+// Requests without the header are not limited.
+func HeaderKey[TIn any](name string) func(ctx context.Context, input TIn) (string, error) {
+	return func(ctx context.Context, _ TIn) (string, error) {
+		if req, ok := apigw.FromContext(ctx); ok {
+			return req.Header(name), nil
+		}
+		return "", nil
+	}
+}
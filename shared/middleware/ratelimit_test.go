@@ -0,0 +1,198 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	"github.com/xarunoba/mlgmr/shared/db"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+// fakeClock is a manually advanced clock for rate limit tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// redisProvider returns a provider whose Redis client talks to mr.
+func redisProvider(t *testing.T, mr *miniredis.Miniredis) *db.Provider {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	return db.NewProvider(db.WithRedisClient(client))
+}
+
+// inputKey uses the input itself as the rate limit key.
+func inputKey(_ context.Context, input string) (string, error) {
+	return input, nil
+}
+
+func okHandler(ctx context.Context, input string) (string, error) {
+	return "ok", nil
+}
+
+func TestRateLimit_Algorithms(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  middleware.RateLimitAlgorithm
+		limit      int64
+		window     time.Duration
+		wait       time.Duration
+		retryAfter time.Duration
+	}{
+		{name: "sliding window", algorithm: middleware.SlidingWindow, limit: 3, window: time.Minute, wait: time.Minute, retryAfter: time.Minute},
+		{name: "token bucket", algorithm: middleware.TokenBucket, limit: 3, window: 3 * time.Second, wait: time.Second, retryAfter: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			clock := &fakeClock{now: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+
+			handler := middleware.RateLimit[string, string](middleware.RateLimitConfig[string]{
+				Algorithm: tt.algorithm,
+				Limit:     tt.limit,
+				Window:    tt.window,
+				Key:       inputKey,
+				DB:        redisProvider(t, mr),
+				Now:       clock.Now,
+			})(okHandler)
+
+			for i := range tt.limit {
+				if _, err := handler(context.Background(), "caller"); err != nil {
+					t.Fatalf("Expected request %d to be allowed, got '%v'", i+1, err)
+				}
+			}
+
+			_, err := handler(context.Background(), "caller")
+			if !apperrors.IsTooManyRequests(err) {
+				t.Fatalf("Expected a too many requests error, got '%v'", err)
+			}
+			if retryAfter, ok := apperrors.RetryAfter(err); !ok || retryAfter != tt.retryAfter {
+				t.Errorf("Expected retry after %v, got %v", tt.retryAfter, retryAfter)
+			}
+
+			// Other callers have their own limit
+			if _, err := handler(context.Background(), "other"); err != nil {
+				t.Errorf("Expected another caller to be allowed, got '%v'", err)
+			}
+
+			clock.now = clock.now.Add(tt.wait)
+			if _, err := handler(context.Background(), "caller"); err != nil {
+				t.Errorf("Expected request to be allowed after waiting %v, got '%v'", tt.wait, err)
+			}
+		})
+	}
+}
+
+func TestRateLimit_RedisUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOpen bool
+	}{
+		{name: "fail closed", failOpen: false},
+		{name: "fail open", failOpen: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			provider := redisProvider(t, mr)
+			mr.Close()
+
+			called := false
+			handler := middleware.RateLimit[string, string](middleware.RateLimitConfig[string]{
+				Limit:    1,
+				Window:   time.Minute,
+				Key:      inputKey,
+				FailOpen: tt.failOpen,
+				DB:       provider,
+			})(func(ctx context.Context, input string) (string, error) {
+				called = true
+				return "ok", nil
+			})
+
+			_, err := handler(context.Background(), "caller")
+			if tt.failOpen {
+				if err != nil || !called {
+					t.Errorf("Expected the request to be allowed, got '%v'", err)
+				}
+				return
+			}
+			if called {
+				t.Error("Expected the handler not to be called")
+			}
+			if !apperrors.IsUnavailable(err) {
+				t.Errorf("Expected an unavailable error, got '%v'", err)
+			}
+		})
+	}
+}
+
+func TestRateLimit_SourceIPKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	handler := middleware.RateLimit[string, string](middleware.RateLimitConfig[string]{
+		Limit:  1,
+		Window: time.Minute,
+		Key:    middleware.SourceIPKey[string],
+		DB:     redisProvider(t, mr),
+	})(okHandler)
+
+	ctx := apigw.NewContext(context.Background(), &apigw.Request{SourceIP: "203.0.113.7"})
+	if _, err := handler(ctx, "first"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if _, err := handler(ctx, "second"); !apperrors.IsTooManyRequests(err) {
+		t.Errorf("Expected the same source IP to be limited, got '%v'", err)
+	}
+
+	// Invocations outside API Gateway are not limited
+	for range 3 {
+		if _, err := handler(context.Background(), "direct"); err != nil {
+			t.Errorf("Expected no error without a request in the context, got '%v'", err)
+		}
+	}
+}
+
+func TestRateLimit_WindowBelowOneMillisecond(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected RateLimit to panic for a window below 1ms")
+		}
+	}()
+
+	middleware.RateLimit[string, string](middleware.RateLimitConfig[string]{
+		Limit:  1,
+		Window: time.Microsecond,
+		Key:    inputKey,
+	})
+}
+
+func TestRateLimit_ScopedPerFunction(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := middleware.RateLimitConfig[string]{Limit: 1, Window: time.Minute, Key: inputKey, DB: redisProvider(t, mr)}
+
+	setFunctionName(t, "GreeterFunction")
+	greeter := middleware.RateLimit[string, string](cfg)(okHandler)
+	setFunctionName(t, "BillingFunction")
+	billing := middleware.RateLimit[string, string](cfg)(okHandler)
+
+	if _, err := greeter(context.Background(), "caller"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if _, err := billing(context.Background(), "caller"); err != nil {
+		t.Errorf("Expected another function's limit to be separate, got '%v'", err)
+	}
+	if _, err := greeter(context.Background(), "caller"); !apperrors.IsTooManyRequests(err) {
+		t.Errorf("Expected the second request to the same function to be limited, got '%v'", err)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/xarunoba/mlgmr/shared"
+)
+
+// Compile-time check to ensure Recover implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Recover[any, any]
+
+// PanicError is returned by the Recover middleware when the wrapped handler panics.
+// It carries the recovered panic value and the stack trace of the panicking goroutine.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, so errors.Is and errors.As
+// can match against it.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Recover is a middleware that recovers from panics in the wrapped handler.
+// The panic value and stack trace are logged and returned as a *PanicError,
+// so the Lambda runtime reports a failed invocation instead of crashing the process
+// and losing the warm database clients. The logged value is passed through DefaultRedactor.
+func Recover[TIn, TOut any](next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+	return func(ctx context.Context, input TIn) (output TOut, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				// Panics re-raised by Deadline already carry the stack of the handler goroutine
+				panicErr, ok := r.(*PanicError)
+				if !ok {
+					panicErr = &PanicError{
+						Value: r,
+						Stack: debug.Stack(),
+					}
+				}
+
+				LoggerFrom(ctx).ErrorContext(ctx, "Lambda invocation panicked",
+					slog.Any("panic", DefaultRedactor.Redact(panicErr.Value)),
+					slog.String("stack", string(panicErr.Stack)),
+				)
+
+				var zero TOut
+				output, err = zero, panicErr
+			}
+		}()
+
+		return next(ctx, input)
+	}
+}
@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+func TestRecover_NoPanic(t *testing.T) {
+	handler := middleware.Recover(func(ctx context.Context, input string) (string, error) {
+		return "hello, " + input, nil
+	})
+
+	output, err := handler(context.Background(), "world")
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if output != "hello, world" {
+		t.Errorf("Expected output 'hello, world', got '%s'", output)
+	}
+}
+
+func TestRecover_PassesThroughErrors(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	handler := middleware.Recover(func(ctx context.Context, input string) (string, error) {
+		return "", errHandler
+	})
+
+	_, err := handler(context.Background(), "world")
+	if !errors.Is(err, errHandler) {
+		t.Errorf("Expected error '%v', got '%v'", errHandler, err)
+	}
+
+	var panicErr *middleware.PanicError
+	if errors.As(err, &panicErr) {
+		t.Error("Expected a regular error not to be reported as a panic")
+	}
+}
+
+func TestRecover_PanicWithValue(t *testing.T) {
+	handler := middleware.Recover(func(ctx context.Context, input *string) (*string, error) {
+		panic("boom")
+	})
+
+	output, err := handler(context.Background(), nil)
+	if output != nil {
+		t.Errorf("Expected zero output, got '%v'", output)
+	}
+
+	var panicErr *middleware.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected *middleware.PanicError, got '%T'", err)
+	}
+
+	if panicErr.Value != "boom" {
+		t.Errorf("Expected panic value 'boom', got '%v'", panicErr.Value)
+	}
+
+	if err.Error() != "panic: boom" {
+		t.Errorf("Expected error 'panic: boom', got '%s'", err.Error())
+	}
+
+	if !strings.Contains(string(panicErr.Stack), "recover_test.go") {
+		t.Error("Expected stack trace to reference the panicking function")
+	}
+}
+
+func TestRecover_PanicWithError(t *testing.T) {
+	errCause := errors.New("nil dereference")
+
+	handler := middleware.Recover(func(ctx context.Context, input string) (string, error) {
+		panic(errCause)
+	})
+
+	_, err := handler(context.Background(), "world")
+	if !errors.Is(err, errCause) {
+		t.Errorf("Expected error to unwrap to '%v', got '%v'", errCause, err)
+	}
+}
@@ -0,0 +1,418 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RedactedValue replaces the values of redacted fields in logs.
+const RedactedValue = "[REDACTED]"
+
+// DefaultSensitiveKeys are the key names DefaultRedactor redacts.
+var DefaultSensitiveKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"apiKey",
+	"authorization",
+	"cookie",
+	"credential",
+	"privateKey",
+	"creditCard",
+	"cardNumber",
+	"cvv",
+	"ssn",
+}
+
+// Default truncation limits of DefaultRedactor.
+const (
+	DefaultMaxStringLength = 1024
+	DefaultMaxItems        = 100
+)
+
+// DefaultRedactor is the Redactor used by the Logger middleware.
+var DefaultRedactor = &Redactor{
+	SensitiveKeys:   DefaultSensitiveKeys,
+	MaxStringLength: DefaultMaxStringLength,
+	MaxItems:        DefaultMaxItems,
+}
+
+// maxOpaqueLength is the longest scalar encoding of a json.Marshaler or
+// encoding.TextMarshaler, such as a timestamp or an ID, that is logged as is.
+const maxOpaqueLength = 64
+
+// maxRedactDepth bounds the traversal so cyclic values cannot recurse forever.
+const maxRedactDepth = 32
+
+// Redactor prepares values for logging by hiding sensitive fields and truncating
+// large payloads. Struct fields are controlled with the `log` tag:
+//
+//	log:"redact"  the value is replaced with RedactedValue
+//	log:"omit"    the field is left out
+//
+// Struct fields and map entries whose key ends with one of SensitiveKeys are redacted
+// as well. Keys are compared word by word, case-insensitively and ignoring separators,
+// so "apiKey" also matches "x-api-key" and "API_KEY" but "tokenCount" does not match
+// "token". Nested structs, maps, slices and pointers
+// are handled recursively; struct keys follow their `json` tags. JSON documents
+// (json.RawMessage and types encoding to JSON objects or arrays) are decoded and
+// redacted the same way. In strings and error messages, values following a sensitive
+// key, as in `password=...`, `"token":"..."` or `Authorization: Bearer ...`, are redacted.
+type Redactor struct {
+	// SensitiveKeys lists the key names to redact.
+	SensitiveKeys []string
+	// MaxStringLength truncates longer strings. Zero disables string truncation.
+	MaxStringLength int
+	// MaxItems truncates longer slices and arrays. Zero disables item truncation.
+	MaxItems int
+}
+
+// Redact returns a copy of v suitable for logging, made of maps, slices and
+// primitive values, with sensitive fields redacted and large payloads truncated.
+func (r *Redactor) Redact(v any) any {
+	return r.redact(reflect.ValueOf(v), 0)
+}
+
+// RedactError returns the message of err for logging, redacted and truncated like a
+// string value. It returns nil for a nil error.
+func (r *Redactor) RedactError(err error) any {
+	if err == nil {
+		return nil
+	}
+	return r.redactString(err.Error())
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+)
+
+// keyValuePattern matches a key followed by ':' or '=' and its value, which is quoted,
+// an HTTP authorization scheme and credentials, or a bare word.
+var keyValuePattern = regexp.MustCompile(`(?i)(["']?)([a-z0-9_-]+)["']?\s*[:=]\s*("(?:[^"\\]|\\.)*"|'[^']*'|(?:bearer|basic)\s+[^\s,;"']+|[^\s,;&"'}\]]+)`)
+
+func (r *Redactor) redact(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxRedactDepth {
+		return "[MAX DEPTH]"
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	if v.CanInterface() {
+		// Errors are logged by their message
+		if err, ok := v.Interface().(error); ok {
+			return r.RedactError(err)
+		}
+		if v.Type() == rawMessageType {
+			return r.redactJSON(v.Bytes(), depth)
+		}
+		// Types with their own encoding (time.Time, bson.ObjectID, ...) are logged as they
+		// are, unless they encode to documents or long strings
+		if v.Type().Implements(jsonMarshalerType) {
+			return r.redactMarshaler(v, depth)
+		}
+		if v.Type().Implements(textMarshalerType) {
+			text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return r.redactString(fmt.Sprint(v.Interface()))
+			}
+			if len(text) <= maxOpaqueLength {
+				return v.Interface()
+			}
+			return r.redactString(string(text))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return r.redact(v.Elem(), depth+1)
+	case reflect.String:
+		return r.redactString(v.String())
+	case reflect.Struct:
+		fields := make(map[string]any, v.NumField())
+		r.redactStruct(v, fields, depth)
+		return fields
+	case reflect.Map:
+		return r.redactMap(v, depth)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return r.redactString(string(v.Bytes()))
+		}
+		return r.redactSlice(v, depth)
+	case reflect.Array:
+		return r.redactSlice(v, depth)
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Sprintf("[%s]", v.Type())
+	default:
+		if v.CanInterface() {
+			return v.Interface()
+		}
+		return nil
+	}
+}
+
+// redactStruct adds the exported fields of v to fields. Embedded structs without a
+// json name are flattened, like encoding/json does.
+func (r *Redactor) redactStruct(v reflect.Value, fields map[string]any, depth int) {
+	t := v.Type()
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		switch field.Tag.Get("log") {
+		case "omit":
+			continue
+		case "redact":
+			fields[orFieldName(name, field.Name)] = RedactedValue
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		if field.Anonymous && name == "" {
+			for fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					break
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				r.redactStruct(fieldValue, fields, depth+1)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+		}
+
+		name = orFieldName(name, field.Name)
+		if r.isSensitive(name) {
+			fields[name] = RedactedValue
+			continue
+		}
+		fields[name] = r.redact(fieldValue, depth+1)
+	}
+}
+
+func (r *Redactor) redactMap(v reflect.Value, depth int) any {
+	if v.IsNil() {
+		return nil
+	}
+
+	entries := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key := mapKey(iter.Key())
+		if r.isSensitive(key) {
+			entries[key] = RedactedValue
+			continue
+		}
+		entries[key] = r.redact(iter.Value(), depth+1)
+	}
+	return entries
+}
+
+func (r *Redactor) redactSlice(v reflect.Value, depth int) any {
+	n := v.Len()
+	if r.MaxItems > 0 && n > r.MaxItems {
+		n = r.MaxItems
+	}
+
+	items := make([]any, 0, n+1)
+	for i := range n {
+		items = append(items, r.redact(v.Index(i), depth+1))
+	}
+	if n < v.Len() {
+		items = append(items, fmt.Sprintf("...(%d more items)", v.Len()-n))
+	}
+	return items
+}
+
+// redactMarshaler redacts a json.Marshaler: encodings to JSON objects or arrays are
+// decoded and redacted, scalar encodings longer than maxOpaqueLength are redacted like
+// strings and others are kept as is.
+func (r *Redactor) redactMarshaler(v reflect.Value, depth int) any {
+	encoded, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return r.redactString(fmt.Sprint(v.Interface()))
+	}
+
+	encoded = bytes.TrimSpace(encoded)
+	if len(encoded) > 0 && (encoded[0] == '{' || encoded[0] == '[') {
+		return r.redactJSON(encoded, depth)
+	}
+	if len(encoded) > maxOpaqueLength {
+		return r.redactJSON(encoded, depth)
+	}
+	return v.Interface()
+}
+
+// redactJSON decodes a JSON document and redacts it. Invalid JSON is redacted as a string.
+func (r *Redactor) redactJSON(data []byte, depth int) any {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return r.redactString(string(data))
+	}
+	return r.redact(reflect.ValueOf(decoded), depth+1)
+}
+
+// redactString redacts the values of sensitive keys in s and truncates it.
+func (r *Redactor) redactString(s string) string {
+	return r.truncate(r.redactText(s))
+}
+
+// redactText replaces the values following sensitive keys in s (see keyValuePattern)
+// with RedactedValue, keeping their quotes.
+func (r *Redactor) redactText(s string) string {
+	var b strings.Builder
+	last, pos := 0, 0
+	for pos < len(s) {
+		m := keyValuePattern.FindStringSubmatchIndex(s[pos:])
+		if m == nil {
+			break
+		}
+		key := s[pos+m[4] : pos+m[5]]
+		valueStart, valueEnd := pos+m[6], pos+m[7]
+		if !r.isSensitive(key) {
+			// The value may itself be a key, as in "failed: password=..."
+			pos += m[5]
+			continue
+		}
+
+		replacement := RedactedValue
+		if quote := s[valueStart]; quote == '"' || quote == '\'' {
+			replacement = string(quote) + RedactedValue + string(quote)
+		}
+
+		b.WriteString(s[last:valueStart])
+		b.WriteString(replacement)
+		last, pos = valueEnd, valueEnd
+	}
+
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// truncate shortens s to MaxStringLength bytes, keeping it valid UTF-8.
+func (r *Redactor) truncate(s string) string {
+	if r.MaxStringLength <= 0 || len(s) <= r.MaxStringLength {
+		return s
+	}
+
+	cut := r.MaxStringLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(%d more bytes)", s[:cut], len(s)-cut)
+}
+
+// isSensitive reports whether key ends with one of the sensitive key names, comparing
+// whole words (see keyWords) so that "accessToken" matches "token" but "tokenCount",
+// "className" and "secretary" do not match "token", "ssn" and "secret". A trailing "s"
+// is ignored, so "credentials" matches "credential".
+func (r *Redactor) isSensitive(key string) bool {
+	words := keyWords(key)
+	for _, sensitive := range r.SensitiveKeys {
+		name := strings.Join(keyWords(sensitive), "")
+		if name == "" {
+			continue
+		}
+
+		// Compare every run of trailing words, so "apikey" and "X-Api-Key" both match "apiKey"
+		for i := range words {
+			tail := strings.Join(words[i:], "")
+			if tail == name || tail == name+"s" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keyWords splits key into lower-cased words at camelCase boundaries and at any
+// character other than a letter or digit, e.g. "X-APIKey_id" becomes [x api key id].
+func keyWords(key string) []string {
+	runes := []rune(key)
+
+	var words []string
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = -1
+			}
+			continue
+		}
+
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			// "userName" splits before N; "APIKey" splits before K, the start of "Key"
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+
+	return words
+}
+
+// mapKey formats a map key as a string.
+func mapKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	if key.CanInterface() {
+		return fmt.Sprint(key.Interface())
+	}
+	return fmt.Sprintf("[%s]", key.Type())
+}
+
+// orFieldName returns the json name, or the Go field name if it is empty.
+func orFieldName(name, fallback string) string {
+	if name != "" {
+		return name
+	}
+	return fallback
+}
@@ -0,0 +1,232 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type accountInput struct {
+	Email      string            `json:"email" log:"redact"`
+	Internal   string            `json:"internal" log:"omit"`
+	Login      credentials       `json:"login"`
+	Headers    map[string]string `json:"headers"`
+	Tags       []string          `json:"tags"`
+	Bio        string            `json:"bio"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Ignored    string            `json:"-"`
+	unexported string
+}
+
+func TestRedactor_Redact(t *testing.T) {
+	redactor := &middleware.Redactor{
+		SensitiveKeys:   middleware.DefaultSensitiveKeys,
+		MaxStringLength: 8,
+		MaxItems:        2,
+	}
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	got := redactor.Redact(&accountInput{
+		Email:      "gopher@example.com",
+		Internal:   "internal",
+		Login:      credentials{Username: "gopher", Password: "hunter2"},
+		Headers:    map[string]string{"X-Api-Key": "abc", "Accept": "json"},
+		Tags:       []string{"a", "b", "c", "d"},
+		Bio:        "a very long biography",
+		CreatedAt:  createdAt,
+		Ignored:    "ignored",
+		unexported: "unexported",
+	})
+
+	expected := map[string]any{
+		"email": middleware.RedactedValue,
+		"login": map[string]any{
+			"username": "gopher",
+			"password": middleware.RedactedValue,
+		},
+		"headers": map[string]any{
+			"X-Api-Key": middleware.RedactedValue,
+			"Accept":    "json",
+		},
+		"tags":      []any{"a", "b", "...(2 more items)"},
+		"bio":       "a very l...(13 more bytes)",
+		"createdAt": createdAt,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestRedactor_SensitiveKeys(t *testing.T) {
+	tests := []struct {
+		key       string
+		sensitive bool
+	}{
+		{"password", true},
+		{"Password", true},
+		{"accessToken", true},
+		{"refresh_token", true},
+		{"X-Api-Key", true},
+		{"API_KEY", true},
+		{"apikey", true},
+		{"clientSecret", true},
+		{"credentials", true},
+		{"user_ssn", true},
+		{"creditCardNumber", true},
+		{"className", false},
+		{"tokenCount", false},
+		{"secretary", false},
+		{"passport", false},
+		{"cookieConsent", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got := middleware.DefaultRedactor.Redact(map[string]string{tt.key: "value"})
+			redacted := got.(map[string]any)[tt.key] == middleware.RedactedValue
+			if redacted != tt.sensitive {
+				t.Errorf("Expected key '%s' redacted: %v, got %v", tt.key, tt.sensitive, redacted)
+			}
+		})
+	}
+}
+
+func TestRedactor_RedactScalarsAndNil(t *testing.T) {
+	redactor := &middleware.Redactor{}
+
+	tests := []struct {
+		name     string
+		value    any
+		expected any
+	}{
+		{"nil", nil, nil},
+		{"nil pointer", (*accountInput)(nil), nil},
+		{"string without truncation", strings.Repeat("x", 2000), strings.Repeat("x", 2000)},
+		{"number", 42, 42},
+		{"error", errors.New("boom"), "boom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactor.Redact(tt.value); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLogger_RedactsInputOutputAndError(t *testing.T) {
+	buf := captureLogs(t)
+
+	handler := middleware.Logger(func(ctx context.Context, input credentials) (credentials, error) {
+		return input, errors.New(strings.Repeat("e", 2*middleware.DefaultMaxStringLength))
+	})
+
+	if _, err := handler(context.Background(), credentials{Username: "gopher", Password: "hunter2"}); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	logs := buf.String()
+	if strings.Contains(logs, "hunter2") {
+		t.Errorf("Expected the password to be redacted, got %s", logs)
+	}
+	if !strings.Contains(logs, "more bytes") {
+		t.Errorf("Expected the long error to be truncated, got %s", logs)
+	}
+}
+
+// opaque encodes itself as a JSON document, like many API and SDK types.
+type opaque struct {
+	token string
+}
+
+func (o opaque) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"token": o.token, "kind": "opaque"})
+}
+
+// longText encodes itself as text longer than a timestamp or an ID.
+type longText struct{}
+
+func (longText) MarshalText() ([]byte, error) {
+	return []byte("password=hunter2 " + strings.Repeat("x", 100)), nil
+}
+
+func TestRedactor_RedactEncodedValues(t *testing.T) {
+	redactor := &middleware.Redactor{SensitiveKeys: middleware.DefaultSensitiveKeys, MaxStringLength: 48}
+
+	tests := []struct {
+		name     string
+		value    any
+		expected any
+	}{
+		{
+			name:     "raw message",
+			value:    json.RawMessage(`{"name":"gopher","password":"hunter2"}`),
+			expected: map[string]any{"name": "gopher", "password": middleware.RedactedValue},
+		},
+		{
+			name:     "invalid raw message",
+			value:    json.RawMessage(`password=hunter2`),
+			expected: "password=" + middleware.RedactedValue,
+		},
+		{
+			name:     "json marshaler",
+			value:    opaque{token: "abc"},
+			expected: map[string]any{"token": middleware.RedactedValue, "kind": "opaque"},
+		},
+		{
+			name:     "long text marshaler",
+			value:    longText{},
+			expected: "password=[REDACTED] xxxxxxxxxxxxxxxxxxxxxxxxxxxx...(72 more bytes)",
+		},
+		{
+			name:     "string with credentials",
+			value:    `{"user":"gopher","apiKey":"abc"}`,
+			expected: `{"user":"gopher","apiKey":"[REDACTED]"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactor.Redact(tt.value); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedactor_RedactError(t *testing.T) {
+	redactor := &middleware.Redactor{SensitiveKeys: middleware.DefaultSensitiveKeys}
+
+	err := errors.New(`request failed: Authorization: Bearer abc.def, password='hunter2' token=xyz`)
+	expected := `request failed: Authorization: [REDACTED], password='[REDACTED]' token=[REDACTED]`
+	if got := redactor.RedactError(err); got != expected {
+		t.Errorf("Expected '%s', got '%v'", expected, got)
+	}
+}
+
+func TestRecover_RedactsPanicValue(t *testing.T) {
+	buf := captureLogs(t)
+
+	handler := middleware.Recover(func(ctx context.Context, input string) (string, error) {
+		panic("failed to connect with password=hunter2")
+	})
+
+	if _, err := handler(context.Background(), "World"); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if logs := buf.String(); strings.Contains(logs, "hunter2") {
+		t.Errorf("Expected the panic value to be redacted, got %s", logs)
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Compile-time check to ensure Tracing implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Tracing[any, any](TracingConfig{})
+
+// DefaultTracingFlushTimeout bounds how long Tracing waits for spans to be exported
+// at the end of an invocation.
+const DefaultTracingFlushTimeout = time.Second
+
+// TracingConfig configures Tracing.
+type TracingConfig struct {
+	// TracerProvider creates the spans. It defaults to the global tracer provider
+	// (see tracing.Setup) at the time of each invocation.
+	TracerProvider trace.TracerProvider
+	// Propagator extracts the parent trace from the request headers.
+	// It defaults to tracing.Propagator.
+	Propagator propagation.TextMapPropagator
+	// FlushTimeout bounds the flush at the end of each invocation.
+	// It defaults to DefaultTracingFlushTimeout.
+	FlushTimeout time.Duration
+}
+
+// flusher is implemented by SDK tracer providers.
+type flusher interface {
+	ForceFlush(ctx context.Context) error
+}
+
+// Tracing is a middleware that starts an OpenTelemetry server span per invocation.
+// The span continues the trace of the invocation: the X-Ray trace header set by the
+// Lambda runtime, or the X-Amzn-Trace-Id or W3C traceparent headers of the API Gateway
+// request (see tracing.Propagator). The span is stored in the context, so spans of the
+// handler and of the database clients (see db.MongoCommandMonitor and db.RedisTracingHook)
+// become its children. Handler errors are recorded on the span and, unless they map to
+// a 4xx status (see apperrors.Code.Status), mark it as failed.
+//
+// Lambda freezes the process between invocations, so the tracer provider is flushed
+// before the invocation returns.
+func Tracing[TIn, TOut any](cfg TracingConfig) shared.MiddlewareFunc[TIn, TOut] {
+	if cfg.Propagator == nil {
+		cfg.Propagator = tracing.Propagator()
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = DefaultTracingFlushTimeout
+	}
+
+	return func(next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+		return func(ctx context.Context, input TIn) (TOut, error) {
+			provider := cfg.TracerProvider
+			if provider == nil {
+				provider = otel.GetTracerProvider()
+			}
+
+			ctx, cold := invocationColdStart(ctx)
+			ctx = cfg.Propagator.Extract(ctx, traceCarrier(ctx))
+
+			name := lambdacontext.FunctionName
+			if name == "" {
+				name = "invoke"
+			}
+
+			ctx, span := provider.Tracer(tracing.TracerName).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(invocationAttrs(ctx, cold)...),
+			)
+
+			output, err := next(ctx, input)
+
+			if err != nil {
+				classified := apperrors.Classify(err)
+				span.RecordError(err)
+				span.SetAttributes(semconv.ErrorTypeKey.String(string(classified.Code)))
+				// Client errors are the caller's fault, not a failure of the function
+				if classified.Status() >= 500 {
+					span.SetStatus(codes.Error, err.Error())
+				}
+			}
+			span.End()
+
+			if f, ok := provider.(flusher); ok {
+				flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.FlushTimeout)
+				defer cancel()
+
+				if flushErr := f.ForceFlush(flushCtx); flushErr != nil {
+					LoggerFrom(ctx).WarnContext(ctx, "Failed to flush traces", slog.Any("error", flushErr))
+				}
+			}
+
+			return output, err
+		}
+	}
+}
+
+// traceCarrier returns the headers carrying the parent trace: those of the API Gateway
+// request, with the X-Ray header of the Lambda runtime taking precedence.
+func traceCarrier(ctx context.Context) headerCarrier {
+	carrier := headerCarrier{}
+	if req, ok := apigw.FromContext(ctx); ok {
+		maps.Copy(carrier, req.Headers)
+	}
+	if header := TraceHeader(ctx); header != "" {
+		carrier.Set("X-Amzn-Trace-Id", header)
+	}
+	return carrier
+}
+
+// invocationAttrs describes the invocation following the OpenTelemetry FaaS and HTTP
+// semantic conventions.
+func invocationAttrs(ctx context.Context, cold bool) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.FaaSColdstart(cold),
+	}
+
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		attrs = append(attrs,
+			semconv.FaaSInvocationID(lc.AwsRequestID),
+			semconv.CloudResourceID(lc.InvokedFunctionArn),
+		)
+	}
+
+	if req, ok := apigw.FromContext(ctx); ok {
+		attrs = append(attrs,
+			semconv.FaaSTriggerHTTP,
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLPath(req.Path),
+			semconv.ClientAddress(req.SourceIP),
+		)
+	} else {
+		attrs = append(attrs, semconv.FaaSTriggerOther)
+	}
+
+	return attrs
+}
+
+// headerCarrier adapts API Gateway headers, whose keys are lower-cased, to
+// propagation.TextMapCarrier.
+type headerCarrier map[string]string
+
+// Compile-time check to ensure headerCarrier implements TextMapCarrier
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	return c[strings.ToLower(key)]
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[strings.ToLower(key)] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	return slices.Collect(maps.Keys(c))
+}
@@ -0,0 +1,169 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/xarunoba/mlgmr/shared/apigw"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+	"github.com/xarunoba/mlgmr/shared/tracing/tracingtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedHandler wraps handler with Tracing using an in-memory tracer provider.
+func tracedHandler(t *testing.T, handler func(ctx context.Context, input string) (string, error)) (func(ctx context.Context, input string) (string, error), *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	provider, exporter := tracingtest.New(t)
+
+	traced := middleware.Tracing[string, string](middleware.TracingConfig{TracerProvider: provider})(handler)
+	return traced, exporter
+}
+
+// spanAttrs returns the attributes of span as strings.
+func spanAttrs(span tracetest.SpanStub) map[attribute.Key]string {
+	attrs := map[attribute.Key]string{}
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value.Emit()
+	}
+	return attrs
+}
+
+func TestTracing_ContinuesLambdaTrace(t *testing.T) {
+	handler, exporter := tracedHandler(t, func(ctx context.Context, input string) (string, error) {
+		_, child := trace.SpanFromContext(ctx).TracerProvider().Tracer("test").Start(ctx, "child")
+		child.End()
+		return "ok", nil
+	})
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID:       "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+		InvokedFunctionArn: "arn:aws:lambda:us-east-1:123456789012:function:greeter",
+	})
+	ctx = context.WithValue(ctx, "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+
+	if _, err := handler(ctx, "input"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("Expected a server span, got %v", server.SpanKind)
+	}
+	if traceID := server.SpanContext.TraceID().String(); traceID != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("Expected the X-Ray trace to be continued, got trace ID '%s'", traceID)
+	}
+	if parentID := server.Parent.SpanID().String(); parentID != "53995c3f42cd8ad8" {
+		t.Errorf("Expected the X-Ray parent segment as parent, got '%s'", parentID)
+	}
+	if child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("Expected spans of the handler to be children of the invocation span")
+	}
+
+	attrs := spanAttrs(server)
+	expected := map[attribute.Key]string{
+		"faas.invocation_id": "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+		"cloud.resource_id":  "arn:aws:lambda:us-east-1:123456789012:function:greeter",
+		"faas.trigger":       "other",
+	}
+	for key, value := range expected {
+		if attrs[key] != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, attrs[key])
+		}
+	}
+}
+
+func TestTracing_ContinuesW3CTraceOfRequest(t *testing.T) {
+	handler, exporter := tracedHandler(t, okHandler)
+
+	ctx := apigw.NewContext(context.Background(), &apigw.Request{
+		Method:   "POST",
+		Path:     "/",
+		SourceIP: "203.0.113.7",
+		Headers: map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+	})
+
+	if _, err := handler(ctx, "input"); err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	server := spans[0]
+
+	if traceID := server.SpanContext.TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the W3C trace to be continued, got trace ID '%s'", traceID)
+	}
+	if parentID := server.Parent.SpanID().String(); parentID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the caller's span as parent, got '%s'", parentID)
+	}
+
+	attrs := spanAttrs(server)
+	expected := map[attribute.Key]string{
+		"faas.trigger":        "http",
+		"http.request.method": "POST",
+		"url.path":            "/",
+		"client.address":      "203.0.113.7",
+	}
+	for key, value := range expected {
+		if attrs[key] != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, attrs[key])
+		}
+	}
+}
+
+func TestTracing_RecordsErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectFailed  bool
+		expectErrType string
+	}{
+		{name: "server error", err: apperrors.Unavailable("database unavailable"), expectFailed: true, expectErrType: "UNAVAILABLE"},
+		{name: "unclassified error", err: errors.New("boom"), expectFailed: true, expectErrType: "INTERNAL"},
+		{name: "client error", err: apperrors.NotFound("user not found"), expectFailed: false, expectErrType: "NOT_FOUND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, exporter := tracedHandler(t, func(ctx context.Context, input string) (string, error) {
+				return "", tt.err
+			})
+
+			if _, err := handler(context.Background(), "input"); err != tt.err {
+				t.Fatalf("Expected the handler error to be returned, got '%v'", err)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("Expected 1 span, got %d", len(spans))
+			}
+			span := spans[0]
+
+			if failed := span.Status.Code == codes.Error; failed != tt.expectFailed {
+				t.Errorf("Expected failed status %v, got %v", tt.expectFailed, span.Status)
+			}
+			if errType := spanAttrs(span)["error.type"]; errType != tt.expectErrType {
+				t.Errorf("Expected error.type '%s', got '%s'", tt.expectErrType, errType)
+			}
+			if len(span.Events) != 1 || span.Events[0].Name != "exception" {
+				t.Errorf("Expected the error to be recorded as an exception event, got %v", span.Events)
+			}
+		})
+	}
+}
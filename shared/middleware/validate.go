@@ -0,0 +1,345 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/xarunoba/mlgmr/shared"
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+)
+
+// Compile-time check to ensure Validate implements MiddlewareFunc
+var _ shared.MiddlewareFunc[any, any] = Validate[any, any]
+
+// Validator can be implemented by handler inputs to add custom validation logic.
+// It runs after the struct tag rules have passed. Validate may have a value or a
+// pointer receiver.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a single field that failed validation.
+// A slice of FieldError is attached as the details of the returned validation error.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Validate is a middleware that validates the input before calling the handler.
+// Struct fields are checked against their `validate` tag, a comma-separated list of rules:
+//
+//	required     the value must not be the zero value
+//	min=N        minimum length for strings, slices and maps, or minimum value for numbers
+//	max=N        maximum length for strings, slices and maps, or maximum value for numbers
+//	enum=a|b|c   the value must be one of the listed values
+//	regex=EXPR   strings must match EXPR; must be the last rule since EXPR may contain commas
+//
+// Rules other than required are skipped for zero values, and nested structs are validated
+// recursively; a struct reached again through a pointer cycle is not walked twice. If the
+// input implements Validator, its Validate method runs afterwards, unless the input is a
+// nil pointer. Failures are returned as an apperrors.CodeValidation error with []FieldError details.
+func Validate[TIn, TOut any](next shared.HandlerFunc[TIn, TOut]) shared.HandlerFunc[TIn, TOut] {
+	return func(ctx context.Context, input TIn) (TOut, error) {
+		if err := validateInput(input, inputValidator(&input)); err != nil {
+			var zero TOut
+			return zero, err
+		}
+
+		return next(ctx, input)
+	}
+}
+
+// ValidateInput validates v using its `validate` struct tags and its Validator
+// implementation, if any. It returns nil if v is valid.
+func ValidateInput(v any) error {
+	validator, ok := v.(Validator)
+	if rv := reflect.ValueOf(v); !ok && rv.IsValid() && rv.Kind() != reflect.Pointer {
+		// Validate methods with a pointer receiver are not in the method set of a value
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		validator, _ = ptr.Interface().(Validator)
+	}
+
+	return validateInput(v, validator)
+}
+
+// inputValidator returns the Validator implementation of *input, whether its Validate
+// method has a value or a pointer receiver, or nil if it has none.
+func inputValidator[TIn any](input *TIn) Validator {
+	if validator, ok := any(*input).(Validator); ok {
+		return validator
+	}
+	validator, _ := any(input).(Validator)
+	return validator
+}
+
+// validateInput checks the struct tags of v, then runs validator if it is not nil.
+// Nil pointers have nothing to validate, and calling Validate on them would panic.
+func validateInput(v any, validator Validator) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+
+	var fieldErrors []FieldError
+	if err := validateValue(rv, "", &fieldErrors, map[visitedPointer]bool{}); err != nil {
+		return apperrors.Wrap(err, apperrors.CodeInternal, "invalid validation rules")
+	}
+
+	if len(fieldErrors) > 0 {
+		return apperrors.Validation(fieldErrors[0].Message).WithDetails(fieldErrors)
+	}
+
+	if validator != nil {
+		if err := validator.Validate(); err != nil {
+			if _, ok := apperrors.As(err); ok {
+				return err
+			}
+			return apperrors.Wrap(err, apperrors.CodeValidation, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validationRule is a parsed rule from a `validate` tag.
+type validationRule struct {
+	name   string
+	arg    string
+	number float64
+	values []string
+	regex  *regexp.Regexp
+}
+
+// validationField holds the parsed rules for a single struct field.
+type validationField struct {
+	index int
+	name  string
+	rules []validationRule
+}
+
+// validationCache caches parsed rules per struct type.
+var validationCache sync.Map // map[reflect.Type][]validationField
+
+// visitedPointer identifies a pointer followed by validateValue. The type is part of the
+// key since a struct and its first field share an address.
+type visitedPointer struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// validateValue walks v and appends every failed rule to fieldErrors. visited holds the
+// pointers followed on the way to v, so cyclic structures end the walk instead of
+// recursing forever.
+func validateValue(v reflect.Value, prefix string, fieldErrors *[]FieldError, visited map[visitedPointer]bool) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Pointer {
+			key := visitedPointer{ptr: v.Pointer(), typ: v.Type()}
+			if visited[key] {
+				return nil
+			}
+			visited[key] = true
+			defer delete(visited, key)
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields, err := structRules(v.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		value := v.Field(field.index)
+		path := field.name
+		if prefix != "" {
+			path = prefix + "." + field.name
+		}
+
+		checkRules(value, path, field.rules, fieldErrors)
+
+		if err := validateValue(value, path, fieldErrors, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkRules applies the rules of a single field.
+func checkRules(value reflect.Value, path string, rules []validationRule, fieldErrors *[]FieldError) {
+	if value.IsZero() {
+		for _, rule := range rules {
+			if rule.name == "required" {
+				*fieldErrors = append(*fieldErrors, FieldError{
+					Field:   path,
+					Rule:    rule.name,
+					Message: fmt.Sprintf("%s is required", path),
+				})
+			}
+		}
+		return
+	}
+
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	for _, rule := range rules {
+		var message string
+
+		switch rule.name {
+		case "min":
+			if size, ok := measure(value); ok && size < rule.number {
+				message = fmt.Sprintf("%s must be at least %s", path, describeLimit(value, rule.arg))
+			}
+		case "max":
+			if size, ok := measure(value); ok && size > rule.number {
+				message = fmt.Sprintf("%s must be at most %s", path, describeLimit(value, rule.arg))
+			}
+		case "enum":
+			if !slices.Contains(rule.values, fmt.Sprint(value.Interface())) {
+				message = fmt.Sprintf("%s must be one of [%s]", path, strings.Join(rule.values, ", "))
+			}
+		case "regex":
+			if value.Kind() == reflect.String && !rule.regex.MatchString(value.String()) {
+				message = fmt.Sprintf("%s must match %s", path, rule.arg)
+			}
+		}
+
+		if message != "" {
+			*fieldErrors = append(*fieldErrors, FieldError{
+				Field:   path,
+				Rule:    rule.name,
+				Message: message,
+			})
+		}
+	}
+}
+
+// structRules returns the parsed rules of every exported field of t.
+func structRules(t reflect.Type) ([]validationField, error) {
+	if cached, ok := validationCache.Load(t); ok {
+		return cached.([]validationField), nil
+	}
+
+	var fields []validationField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		rules, err := parseRules(field.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %w", t.Name(), field.Name, err)
+		}
+
+		fields = append(fields, validationField{
+			index: i,
+			name:  fieldName(field),
+			rules: rules,
+		})
+	}
+
+	validationCache.Store(t, fields)
+	return fields, nil
+}
+
+// parseRules parses a `validate` tag into rules.
+func parseRules(tag string) ([]validationRule, error) {
+	var rules []validationRule
+
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			// The regex consumes the rest of the tag so it may contain commas
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		rule := validationRule{name: name, arg: arg}
+
+		switch name {
+		case "":
+			continue
+		case "required":
+		case "min", "max":
+			number, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", name, arg)
+			}
+			rule.number = number
+		case "enum":
+			rule.values = strings.Split(arg, "|")
+		case "regex":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", arg, err)
+			}
+			rule.regex = re
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// measure returns the size used by min/max: the length of strings (in runes), slices
+// and maps, or the numeric value of numbers.
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// describeLimit formats a min/max limit for error messages.
+func describeLimit(v reflect.Value, limit string) string {
+	switch v.Kind() {
+	case reflect.String:
+		return limit + " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return limit + " items"
+	default:
+		return limit
+	}
+}
+
+// fieldName returns the JSON name of a struct field, falling back to the Go name.
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
@@ -0,0 +1,227 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apperrors "github.com/xarunoba/mlgmr/shared/errors"
+	"github.com/xarunoba/mlgmr/shared/middleware"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signupInput struct {
+	Name     string   `json:"name" validate:"required,min=2,max=8"`
+	Age      int      `json:"age" validate:"min=18,max=130"`
+	Plan     string   `json:"plan" validate:"enum=free|pro"`
+	Code     string   `json:"code" validate:"regex=^[A-Z]{2,3}$"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Address  *address `json:"address"`
+	Nickname string
+}
+
+type customInput struct {
+	Password string `json:"password" validate:"required"`
+	Confirm  string `json:"confirm"`
+}
+
+func (c customInput) Validate() error {
+	if c.Password != c.Confirm {
+		return errors.New("passwords do not match")
+	}
+	return nil
+}
+
+type pointerValidatedInput struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (p *pointerValidatedInput) Validate() error {
+	if p.End < p.Start {
+		return errors.New("end must not be before start")
+	}
+	return nil
+}
+
+type badTagInput struct {
+	Name string `validate:"bogus"`
+}
+
+func TestValidateInput(t *testing.T) {
+	valid := signupInput{Name: "Ada", Age: 36, Plan: "pro", Code: "AB", Tags: []string{"a"}, Address: &address{City: "London"}}
+
+	tests := []struct {
+		name   string
+		input  any
+		fields []string
+		rules  []string
+	}{
+		{"valid input", valid, nil, nil},
+		{"optional fields empty", signupInput{Name: "Ada"}, nil, nil},
+		{"required missing", signupInput{}, []string{"name"}, []string{"required"}},
+		{"string too short", signupInput{Name: "A"}, []string{"name"}, []string{"min"}},
+		{"string too long", signupInput{Name: "Adalovelace"}, []string{"name"}, []string{"max"}},
+		{"multibyte length counts runes", signupInput{Name: "日本語"}, nil, nil},
+		{"number too small", signupInput{Name: "Ada", Age: 12}, []string{"age"}, []string{"min"}},
+		{"number too large", signupInput{Name: "Ada", Age: 200}, []string{"age"}, []string{"max"}},
+		{"enum mismatch", signupInput{Name: "Ada", Plan: "enterprise"}, []string{"plan"}, []string{"enum"}},
+		{"regex mismatch", signupInput{Name: "Ada", Code: "abc"}, []string{"code"}, []string{"regex"}},
+		{"slice too long", signupInput{Name: "Ada", Tags: []string{"a", "b", "c"}}, []string{"tags"}, []string{"max"}},
+		{"nested struct", signupInput{Name: "Ada", Address: &address{}}, []string{"address.city"}, []string{"required"}},
+		{
+			"multiple failures",
+			signupInput{Age: 1, Plan: "x"},
+			[]string{"name", "age", "plan"},
+			[]string{"required", "min", "enum"},
+		},
+		{"pointer input", &valid, nil, nil},
+		{"non-struct input", "just a string", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := middleware.ValidateInput(tt.input)
+
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got '%v'", err)
+				}
+				return
+			}
+
+			appErr, ok := apperrors.As(err)
+			if !ok || appErr.Code != apperrors.CodeValidation {
+				t.Fatalf("Expected validation error, got '%v'", err)
+			}
+
+			details, ok := appErr.Details.([]middleware.FieldError)
+			if !ok {
+				t.Fatalf("Expected []middleware.FieldError details, got %T", appErr.Details)
+			}
+			if len(details) != len(tt.fields) {
+				t.Fatalf("Expected %d field errors, got %d: %+v", len(tt.fields), len(details), details)
+			}
+			for i, detail := range details {
+				if detail.Field != tt.fields[i] || detail.Rule != tt.rules[i] {
+					t.Errorf("Expected %s/%s, got %s/%s", tt.fields[i], tt.rules[i], detail.Field, detail.Rule)
+				}
+				if detail.Message == "" {
+					t.Errorf("Expected a message for field %s", detail.Field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateInput_ValidatorMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   customInput
+		wantErr bool
+	}{
+		{"matching passwords", customInput{Password: "secret", Confirm: "secret"}, false},
+		{"mismatched passwords", customInput{Password: "secret", Confirm: "other"}, true},
+		{"tags are checked first", customInput{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := middleware.ValidateInput(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got '%v'", tt.wantErr, err)
+			}
+			if err != nil && !apperrors.IsValidation(err) {
+				t.Errorf("Expected validation error, got '%v'", err)
+			}
+		})
+	}
+}
+
+func TestValidate_PointerReceiverValidator(t *testing.T) {
+	handler := middleware.Validate(func(ctx context.Context, input pointerValidatedInput) (int, error) {
+		return input.End - input.Start, nil
+	})
+
+	if _, err := handler(context.Background(), pointerValidatedInput{Start: 5, End: 1}); !apperrors.IsValidation(err) {
+		t.Errorf("Expected validation error from the middleware, got '%v'", err)
+	}
+	if err := middleware.ValidateInput(pointerValidatedInput{Start: 5, End: 1}); !apperrors.IsValidation(err) {
+		t.Errorf("Expected validation error from ValidateInput, got '%v'", err)
+	}
+
+	if output, err := handler(context.Background(), pointerValidatedInput{Start: 1, End: 5}); err != nil || output != 4 {
+		t.Errorf("Expected output 4, got %d (error %v)", output, err)
+	}
+}
+
+type linkedInput struct {
+	Name string       `json:"name" validate:"required"`
+	Next *linkedInput `json:"next"`
+}
+
+func TestValidateInput_NilPointer(t *testing.T) {
+	if err := middleware.ValidateInput((*customInput)(nil)); err != nil {
+		t.Errorf("Expected no error for a nil value-receiver input, got '%v'", err)
+	}
+	if err := middleware.ValidateInput((*pointerValidatedInput)(nil)); err != nil {
+		t.Errorf("Expected no error for a nil pointer-receiver input, got '%v'", err)
+	}
+
+	handler := middleware.Validate(func(ctx context.Context, input *pointerValidatedInput) (bool, error) {
+		return input == nil, nil
+	})
+	if isNil, err := handler(context.Background(), nil); err != nil || !isNil {
+		t.Errorf("Expected the nil input to reach the handler, got %v (error %v)", isNil, err)
+	}
+}
+
+func TestValidateInput_Cycle(t *testing.T) {
+	first := &linkedInput{Name: "first"}
+	second := &linkedInput{Next: first}
+	first.Next = second
+
+	err := middleware.ValidateInput(first)
+	if !apperrors.IsValidation(err) {
+		t.Fatalf("Expected validation error, got '%v'", err)
+	}
+
+	appErr, _ := apperrors.As(err)
+	fieldErrors := appErr.Details.([]middleware.FieldError)
+	if len(fieldErrors) != 1 || fieldErrors[0].Field != "next.name" {
+		t.Errorf("Expected a single failure for next.name, got %v", fieldErrors)
+	}
+}
+
+func TestValidateInput_InvalidTag(t *testing.T) {
+	err := middleware.ValidateInput(badTagInput{Name: "x"})
+	if apperrors.CodeOf(err) != apperrors.CodeInternal {
+		t.Errorf("Expected internal error for an unknown rule, got '%v'", err)
+	}
+}
+
+func TestValidate_Middleware(t *testing.T) {
+	called := false
+	handler := middleware.Validate(func(ctx context.Context, input signupInput) (string, error) {
+		called = true
+		return "welcome " + input.Name, nil
+	})
+
+	if _, err := handler(context.Background(), signupInput{}); !apperrors.IsValidation(err) {
+		t.Errorf("Expected validation error, got '%v'", err)
+	}
+	if called {
+		t.Error("Expected handler not to be called for invalid input")
+	}
+
+	output, err := handler(context.Background(), signupInput{Name: "Ada"})
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if output != "welcome Ada" {
+		t.Errorf("Expected output 'welcome Ada', got '%s'", output)
+	}
+}
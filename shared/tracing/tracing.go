@@ -0,0 +1,176 @@
+// Package tracing configures OpenTelemetry tracing for Lambda functions.
+//
+// Setup installs the global tracer provider and propagator from the environment.
+// The Tracing middleware then starts a span per invocation, continuing the trace of
+// the X-Ray or W3C trace headers of the request, and the clients created by
+// the db package record a child span for every MongoDB command and Redis call.
+// Without Setup, the global tracer provider is a no-op and spans cost nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the spans created by this module.
+const TracerName = "github.com/xarunoba/mlgmr"
+
+// Values of the OTEL_TRACES_EXPORTER environment variable understood by Setup.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// Tracer returns the tracer of the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Propagator returns the propagator for incoming trace headers: the X-Ray
+// X-Amzn-Trace-Id header, then the W3C traceparent/tracestate and baggage headers.
+// When both trace headers are present, the W3C one wins.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		xray.Propagator{},
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+}
+
+// config collects the options of NewTracerProvider.
+type config struct {
+	processors []sdktrace.SpanProcessor
+	sampler    sdktrace.Sampler
+	resource   *resource.Resource
+}
+
+// Option configures NewTracerProvider.
+type Option func(*config)
+
+// WithExporter exports spans in batches, e.g. to an exporter from NewOTLPExporter.
+// The Tracing middleware flushes pending batches at the end of every invocation.
+func WithExporter(exporter sdktrace.SpanExporter) Option {
+	return func(c *config) {
+		c.processors = append(c.processors, sdktrace.NewBatchSpanProcessor(exporter))
+	}
+}
+
+// WithSyncExporter exports every span as soon as it ends. It is meant for tests and
+// debugging; use WithExporter in production.
+func WithSyncExporter(exporter sdktrace.SpanExporter) Option {
+	return func(c *config) {
+		c.processors = append(c.processors, sdktrace.NewSimpleSpanProcessor(exporter))
+	}
+}
+
+// WithSampler sets the sampler. It defaults to sampling every trace unless the
+// parent span was not sampled.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return func(c *config) {
+		c.sampler = sampler
+	}
+}
+
+// WithResource sets the resource describing the function. It defaults to DefaultResource.
+func WithResource(res *resource.Resource) Option {
+	return func(c *config) {
+		c.resource = res
+	}
+}
+
+// NewTracerProvider returns an SDK tracer provider. Trace IDs are generated in the
+// X-Ray format, so traces can be exported to X-Ray as well as to any OTLP backend.
+func NewTracerProvider(opts ...Option) *sdktrace.TracerProvider {
+	cfg := &config{
+		sampler: sdktrace.ParentBased(sdktrace.AlwaysSample()),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.resource == nil {
+		cfg.resource = DefaultResource()
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(cfg.sampler),
+		sdktrace.WithResource(cfg.resource),
+		sdktrace.WithIDGenerator(xray.NewIDGenerator()),
+	}
+	for _, processor := range cfg.processors {
+		providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(processor))
+	}
+
+	return sdktrace.NewTracerProvider(providerOpts...)
+}
+
+// NewOTLPExporter returns an exporter sending spans over OTLP/HTTP. Without options it
+// is configured by the standard OTEL_EXPORTER_OTLP_* environment variables and sends
+// to http://localhost:4318, where the ADOT Lambda layer's collector listens.
+func NewOTLPExporter(ctx context.Context, opts ...otlptracehttp.Option) (sdktrace.SpanExporter, error) {
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// DefaultResource describes the running function: its service name comes from
+// OTEL_SERVICE_NAME or the Lambda function name, along with the function name and version.
+func DefaultResource() *resource.Resource {
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = lambdacontext.FunctionName
+	}
+
+	attrs := []attribute.KeyValue{semconv.CloudProviderAWS, semconv.CloudPlatformAWSLambda}
+	if serviceName != "" {
+		attrs = append(attrs, semconv.ServiceName(serviceName))
+	}
+	if lambdacontext.FunctionName != "" {
+		attrs = append(attrs,
+			semconv.FaaSName(lambdacontext.FunctionName),
+			semconv.FaaSVersion(lambdacontext.FunctionVersion),
+		)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return resource.Default()
+	}
+	return res
+}
+
+// Setup installs the global propagator (see Propagator) and, depending on the
+// OTEL_TRACES_EXPORTER environment variable, a global tracer provider:
+//
+//	none (default)  spans are not recorded
+//	otlp            spans are exported with NewOTLPExporter
+//
+// Call the returned shutdown function before the process exits to flush pending spans.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(Propagator())
+
+	switch exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		otlp, err := NewOTLPExporter(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+
+		provider := NewTracerProvider(WithExporter(otlp))
+		otel.SetTracerProvider(provider)
+		return provider.Shutdown, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, must be %q or %q", exporter, ExporterNone, ExporterOTLP)
+	}
+}
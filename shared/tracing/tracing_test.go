@@ -0,0 +1,136 @@
+package tracing_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/xarunoba/mlgmr/shared/tracing"
+	"github.com/xarunoba/mlgmr/shared/tracing/tracingtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// restoreGlobals puts back the global tracer provider and propagator after the test.
+func restoreGlobals(t *testing.T) {
+	t.Helper()
+
+	provider := otel.GetTracerProvider()
+	propagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+}
+
+func TestPropagator_Extract(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{
+			name:     "X-Ray header",
+			headers:  map[string]string{"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"},
+			expected: "5759e988bd862e3fe1be46a994272793",
+		},
+		{
+			name:     "W3C traceparent",
+			headers:  map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			expected: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "W3C wins over X-Ray",
+			headers: map[string]string{
+				"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+				"traceparent":     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			expected: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:     "no headers",
+			headers:  map[string]string{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tracing.Propagator().Extract(context.Background(), propagation.MapCarrier(tt.headers))
+
+			spanContext := trace.SpanContextFromContext(ctx)
+			got := ""
+			if spanContext.IsValid() {
+				got = spanContext.TraceID().String()
+			}
+			if got != tt.expected {
+				t.Errorf("Expected trace ID '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewTracerProvider_XRayTraceIDs(t *testing.T) {
+	provider, exporter := tracingtest.New(t)
+
+	before := time.Now().Unix()
+	_, span := provider.Tracer(tracing.TracerName).Start(context.Background(), "operation")
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	// X-Ray trace IDs start with the epoch seconds of the trace
+	epoch, err := strconv.ParseInt(spans[0].SpanContext.TraceID().String()[:8], 16, 64)
+	if err != nil {
+		t.Fatalf("Expected a hex trace ID, got '%v'", err)
+	}
+	if epoch < before || epoch > time.Now().Unix() {
+		t.Errorf("Expected the trace ID to start with the current time, got %d", epoch)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name        string
+		exporter    string
+		expectError bool
+		expectSDK   bool
+	}{
+		{name: "default", exporter: "", expectSDK: false},
+		{name: "none", exporter: tracing.ExporterNone, expectSDK: false},
+		{name: "otlp", exporter: tracing.ExporterOTLP, expectSDK: true},
+		{name: "unsupported", exporter: "zipkin", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreGlobals(t)
+			t.Setenv("OTEL_TRACES_EXPORTER", tt.exporter)
+
+			shutdown, err := tracing.Setup(context.Background())
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got '%v'", err)
+			}
+			t.Cleanup(func() { shutdown(context.Background()) })
+
+			if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok != tt.expectSDK {
+				t.Errorf("Expected SDK tracer provider %v, got %T", tt.expectSDK, otel.GetTracerProvider())
+			}
+			if fields := otel.GetTextMapPropagator().Fields(); len(fields) < 3 {
+				t.Errorf("Expected the X-Ray and W3C propagators to be installed, got fields %v", fields)
+			}
+		})
+	}
+}
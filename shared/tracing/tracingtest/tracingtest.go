@@ -0,0 +1,44 @@
+// Package tracingtest records spans in memory so tests can assert on them.
+//
+//	provider, exporter := tracingtest.New(t)
+//	traced := middleware.Tracing[In, Out](middleware.TracingConfig{TracerProvider: provider})(handler)
+//
+// Install additionally makes the provider the global tracer provider for the rest of the
+// test, which is what the db clients and every component without an explicit provider use.
+package tracingtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xarunoba/mlgmr/shared/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// New returns a tracer provider that records ended spans in the returned exporter, and
+// shuts it down when the test ends. Like tracing.NewTracerProvider, it generates X-Ray
+// trace IDs.
+func New(t testing.TB) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewTracerProvider(tracing.WithSyncExporter(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	return provider, exporter
+}
+
+// Install records spans like New and makes the provider the global tracer provider until
+// the test ends. Tests using it must not run in parallel.
+func Install(t testing.TB) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	previous := otel.GetTracerProvider()
+	provider, exporter := New(t)
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return exporter
+}